@@ -44,7 +44,7 @@ func newComposeService(ctx store.AciContext) aciComposeService {
 	}
 }
 
-func (cs *aciComposeService) Up(ctx context.Context, project *types.Project, detach bool) error {
+func (cs *aciComposeService) Up(ctx context.Context, project *types.Project, options compose.UpOptions) error {
 	logrus.Debugf("Up on project with name %q", project.Name)
 	groupDefinition, err := convert.ToContainerGroup(ctx, cs.ctx, *project, cs.storageLogin)
 	addTag(&groupDefinition, composeContainerTag)
@@ -55,7 +55,7 @@ func (cs *aciComposeService) Up(ctx context.Context, project *types.Project, det
 	return createOrUpdateACIContainers(ctx, cs.ctx, groupDefinition)
 }
 
-func (cs *aciComposeService) Down(ctx context.Context, project string) error {
+func (cs *aciComposeService) Down(ctx context.Context, project string, options compose.DownOptions) error {
 	logrus.Debugf("Down on project with name %q", project)
 
 	cg, err := deleteACIContainerGroup(ctx, cs.ctx, project)
@@ -125,7 +125,7 @@ func (cs *aciComposeService) List(ctx context.Context, project string) ([]compos
 	return stacks, nil
 }
 
-func (cs *aciComposeService) Logs(ctx context.Context, project string, w io.Writer) error {
+func (cs *aciComposeService) Logs(ctx context.Context, project string, w io.Writer, options compose.LogOptions) error {
 	return errdefs.ErrNotImplemented
 }
 
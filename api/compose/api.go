@@ -19,6 +19,7 @@ package compose
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/compose-spec/compose-go/types"
 )
@@ -26,11 +27,11 @@ import (
 // Service manages a compose project
 type Service interface {
 	// Up executes the equivalent to a `compose up`
-	Up(ctx context.Context, project *types.Project, detach bool) error
+	Up(ctx context.Context, project *types.Project, options UpOptions) error
 	// Down executes the equivalent to a `compose down`
-	Down(ctx context.Context, projectName string) error
+	Down(ctx context.Context, projectName string, options DownOptions) error
 	// Logs executes the equivalent to a `compose logs`
-	Logs(ctx context.Context, projectName string, w io.Writer) error
+	Logs(ctx context.Context, projectName string, w io.Writer, options LogOptions) error
 	// Ps executes the equivalent to a `compose ps`
 	Ps(ctx context.Context, projectName string) ([]ServiceStatus, error)
 	// List executes the equivalent to a `docker stack ls`
@@ -39,6 +40,308 @@ type Service interface {
 	Convert(ctx context.Context, project *types.Project, format string) ([]byte, error)
 }
 
+// UpOptions group options of the Up API
+type UpOptions struct {
+	// Detach will not attach to the containers logs
+	Detach bool
+	// Rollback removes the containers created by this invocation if it gets cancelled or fails
+	Rollback bool
+	// RollbackScope sets the changes undone on Rollback, defaults to RollbackCreated
+	RollbackScope string
+	// RecreateUnhealthy recreates running containers reported unhealthy by their healthcheck
+	RecreateUnhealthy bool
+	// RenewAnonVolumes removes anonymous volumes of recreated containers, instead of attaching them to the replacement
+	RenewAnonVolumes bool
+	// Recreate sets the policy to recreate existing containers, defaults to RecreateDiverged
+	Recreate string
+	// RecreateChangedFiles also considers containers diverged when the content of the files defining secrets and
+	// configs they use changed. This reads those files on each convergence
+	RecreateChangedFiles bool
+	// Services restricts up to the named services and their dependencies. All services are considered when empty
+	Services []string
+	// NoDeps neither starts nor waits for the dependencies of the services
+	NoDeps bool
+	// Parallel limits the number of container operations run concurrently for a service, backend sets a default when 0
+	Parallel int
+	// Wait blocks until services with a healthcheck are healthy
+	Wait bool
+	// WaitTimeout is the maximum duration to Wait for services to be healthy, without limit when 0
+	WaitTimeout time.Duration
+	// Timeout is the maximum duration for services to converge, including the Wait for them to be healthy. Operations
+	// in progress are cancelled once it elapses. Without limit when 0
+	Timeout time.Duration
+	// AttachOnly doesn't converge services but checks their containers are running, so that client can attach to them
+	AttachOnly bool
+	// Scale overrides the number of replicas of services, by service name
+	Scale map[string]int
+	// Command overrides the command of services, by service name, without editing the compose file
+	Command map[string][]string
+	// Entrypoint overrides the entrypoint of services, by service name
+	Entrypoint map[string][]string
+	// NameConflict sets the policy applied when a container to be created conflicts by name with an unmanaged
+	// one, defaults to NameConflictFail
+	NameConflict string
+	// StrictEnvironment fails when services have environment variables left unset or empty, instead of warning
+	StrictEnvironment bool
+	// ContinueOnError carries on converging other services when one fails, and reports all failures once done
+	ContinueOnError bool
+	// Adopt keeps running containers created by another tool for a service, rather than recreating them, as long
+	// as they run the service image and publish the service ports
+	Adopt bool
+	// Pull overrides the pull_policy of all services when set, to PullAlways, PullMissing or PullNever
+	Pull string
+	// QuietPull only reports pull progress per service, rather than per image layer
+	QuietPull bool
+	// RecreateDependents sets which services depending on a recreated one get recreated too, defaults to
+	// RecreateDependentsAll
+	RecreateDependents string
+	// Listener, when set, is notified of services state transitions, independently of progress events. It gets called
+	// concurrently for services converging in parallel
+	Listener func(ServiceTransition)
+}
+
+// ServiceTransition is a change of state of a service being converged by Up
+type ServiceTransition struct {
+	Service string
+	State   string
+	// Error is set for ServiceFailed transitions
+	Error error
+}
+
+const (
+	// ServicePending indicates service is selected and awaits convergence
+	ServicePending = "pending"
+	// ServiceCreating indicates service dependencies are satisfied and its containers are being converged
+	ServiceCreating = "creating"
+	// ServiceStarted indicates service containers are converged and running
+	ServiceStarted = "started"
+	// ServiceHealthy indicates service containers passed their healthcheck, only reported when waiting for it
+	ServiceHealthy = "healthy"
+	// ServiceFailed indicates service failed to converge or to get healthy
+	ServiceFailed = "failed"
+)
+
+const (
+	// RecreateDiverged recreates containers which configuration diverged from the service definition
+	RecreateDiverged = "diverged"
+	// RecreateForce recreates all containers, regardless their configuration
+	RecreateForce = "force"
+	// RecreateNever never recreates existing containers, even if their configuration diverged
+	RecreateNever = "never"
+)
+
+const (
+	// RollbackCreated only removes containers created for new replicas, and leaves recreated ones running
+	RollbackCreated = "created"
+	// RollbackAll restores the project in its state before Up, recreated containers being replaced by the previous ones
+	RollbackAll = "all"
+)
+
+const (
+	// PullAlways pulls service images, even if available locally
+	PullAlways = "always"
+	// PullMissing only pulls service images which aren't available locally
+	PullMissing = "missing"
+	// PullNever never pulls service images, images which aren't available locally make container creation fail
+	PullNever = "never"
+)
+
+const (
+	// RecreateDependentsAll recreates all services depending on a recreated service
+	RecreateDependentsAll = "all"
+	// RecreateDependentsConsumers only recreates dependents bound to the recreated service containers, as they link
+	// to them, use their volumes or share their network namespace
+	RecreateDependentsConsumers = "consumers"
+)
+
+const (
+	// NameConflictFail fails to create the container, leaving the conflicting one untouched
+	NameConflictFail = "fail"
+	// NameConflictReplace removes the conflicting container before the new one is created
+	NameConflictReplace = "replace"
+	// NameConflictAdopt uses the conflicting container as is, starting it if required
+	NameConflictAdopt = "adopt"
+)
+
+// DownOptions group options of the Down API
+type DownOptions struct {
+	// Timeout overrides the services stop grace period when set
+	Timeout *time.Duration
+	// Volumes removes the volumes created for the project
+	Volumes bool
+}
+
+// LogOptions group options of the Logs API
+type LogOptions struct {
+	// Timestamps prefixes log lines with the time they were emitted at
+	Timestamps bool
+	// Since only shows logs emitted after a timestamp or a relative duration, e.g. "42m"
+	Since string
+	// Until only shows logs emitted before a timestamp or a relative duration
+	Until string
+	// Services restricts logs to the named services. Logs of all services are shown when empty
+	Services []string
+}
+
+// RestartOptions group options of the Restart API
+type RestartOptions struct {
+	// Services restricts restart to the named services. All services are restarted when empty
+	Services []string
+	// Timeout overrides the services stop grace period when set
+	Timeout *time.Duration
+}
+
+// Restarter is implemented by backends able to restart the containers of a project, without applying configuration changes
+type Restarter interface {
+	// Restart stops then starts running containers, and starts stopped ones
+	Restart(ctx context.Context, projectName string, options RestartOptions) error
+}
+
+// PauseOptions group options of the Pause and Unpause APIs
+type PauseOptions struct {
+	// Services restricts pause to the named services. All services are paused when empty
+	Services []string
+}
+
+// Pauser is implemented by backends able to pause and unpause the containers of a project
+type Pauser interface {
+	// Pause suspends processes of running containers
+	Pause(ctx context.Context, projectName string, options PauseOptions) error
+	// Unpause resumes processes of paused containers
+	Unpause(ctx context.Context, projectName string, options PauseOptions) error
+}
+
+// RunOptions group options of the Run API
+type RunOptions struct {
+	// Service is the name of the service to run a oneoff container for
+	Service string
+	// Command overrides the service command when set
+	Command []string
+	// Detach returns once container is started, rather than streaming its output to Writer until it exits
+	Detach bool
+	// AutoRemove removes the container once it exits
+	AutoRemove bool
+	Writer     io.Writer
+}
+
+// Runner is implemented by backends able to run oneoff containers for a service, which are not service replicas
+type Runner interface {
+	// RunOneOffContainer creates and starts a oneoff container, and returns its name
+	RunOneOffContainer(ctx context.Context, project *types.Project, options RunOptions) (string, error)
+}
+
+// WatchOptions group options of the Watch API
+type WatchOptions struct {
+	// Services restricts watch to the named services. All services declaring paths to watch are watched when empty
+	Services []string
+}
+
+// Watcher is implemented by backends able to recreate services when their source files change
+type Watcher interface {
+	// Watch monitors paths declared by services, and rebuilds and recreates them on change, until context is cancelled
+	Watch(ctx context.Context, project *types.Project, options WatchOptions) error
+}
+
+// EventsOptions group options of the Events API
+type EventsOptions struct {
+	// Services restricts events to the named services. Events of all services are streamed when empty
+	Services []string
+	// Consumer is called for each event, in the order events are received. Streaming stops if it returns an error
+	Consumer func(event Event) error
+}
+
+// Event is a lifecycle event of a project container
+type Event struct {
+	Timestamp time.Time
+	Service   string
+	Container string
+	// Status is the engine action, e.g. create, start, die
+	Status     string
+	Attributes map[string]string
+}
+
+// EventStreamer is implemented by backends able to stream lifecycle events of the containers of a project
+type EventStreamer interface {
+	// Events streams events until context is cancelled
+	Events(ctx context.Context, projectName string, options EventsOptions) error
+}
+
+// TopOptions group options of the Top API
+type TopOptions struct {
+	// Services restricts top to the named services. Processes of all services are listed when empty
+	Services []string
+}
+
+// ContainerProcSummary hold the processes running in a service container
+type ContainerProcSummary struct {
+	ID      string
+	Name    string
+	Service string
+	// Titles are the columns names of Processes, as reported by ps
+	Titles    []string
+	Processes [][]string
+}
+
+// Topper is implemented by backends able to list the processes running in the containers of a project
+type Topper interface {
+	// Top lists processes of running containers, ordered by service and container name
+	Top(ctx context.Context, projectName string, options TopOptions) ([]ContainerProcSummary, error)
+}
+
+// ConfigOptions group options of the Config API
+type ConfigOptions struct {
+	// Format of the normalized project, either "yaml" or "json"
+	Format string
+	// Services only outputs services names
+	Services bool
+	// Hash only outputs services names along with the hash of their configuration, which containers are compared to
+	Hash bool
+}
+
+// Configurer is implemented by backends able to validate a project without running it
+type Configurer interface {
+	// Config validates project, without contacting the engine, and returns it normalized
+	Config(ctx context.Context, project *types.Project, options ConfigOptions) ([]byte, error)
+}
+
+// Pruner is implemented by backends able to remove the resources of a project which are no longer used
+type Pruner interface {
+	// Prune removes stopped containers and networks no container is attached to
+	Prune(ctx context.Context, projectName string) error
+}
+
+// Planner is implemented by backends able to compute the actions Up would apply to a project, without applying them
+type Planner interface {
+	// Plan returns the actions required for each service of the project to converge
+	Plan(ctx context.Context, project *types.Project) ([]ServicePlan, error)
+}
+
+// ServicePlan hold the actions required for a service to converge
+type ServicePlan struct {
+	Name       string
+	Containers []ContainerPlan
+}
+
+// ContainerPlan hold the action required for a service container to converge
+type ContainerPlan struct {
+	// Name is the container name, either existing or to be created
+	Name   string
+	Action string
+}
+
+const (
+	// ActionCreate indicates a missing container would be created
+	ActionCreate = "create"
+	// ActionRecreate indicates a container would be recreated as its configuration diverged
+	ActionRecreate = "recreate"
+	// ActionRestart indicates a stopped container would be restarted
+	ActionRestart = "restart"
+	// ActionRemove indicates a container would be removed to match service scale
+	ActionRemove = "remove"
+	// ActionNone indicates a container is up-to-date
+	ActionNone = "up-to-date"
+)
+
 // PortPublisher hold status about published port
 type PortPublisher struct {
 	URL           string
@@ -55,8 +358,34 @@ type ServiceStatus struct {
 	Desired    int
 	Ports      []string
 	Publishers []PortPublisher
+	Containers []ContainerSummary
 }
 
+// ContainerSummary hold status about a service container
+type ContainerSummary struct {
+	ID     string
+	Name   string
+	State  string
+	Health string
+	// Created and StartedAt are zero when unknown to the backend
+	Created      time.Time
+	StartedAt    time.Time
+	RestartCount int
+	// Publishers are the ports container publishes, URL being the host IP ports are bound to
+	Publishers []PortPublisher
+}
+
+const (
+	// HealthStarting indicates container healthcheck didn't pass yet
+	HealthStarting = "starting"
+	// HealthHealthy indicates container healthcheck passes
+	HealthHealthy = "healthy"
+	// HealthUnhealthy indicates container healthcheck fails
+	HealthUnhealthy = "unhealthy"
+	// HealthNone indicates container has no healthcheck
+	HealthNone = "none"
+)
+
 const (
 	// STARTING indicates that stack is being deployed
 	STARTING string = "Starting"
@@ -0,0 +1,59 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package compose
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GetContainerName returns the name of the container running replica `number` of a service, as
+// `<project>_<service>_<number>`. As both project and service names can contain underscores, external
+// tools should rely on ReplicaTag or ParseContainerName rather than splitting this name.
+func GetContainerName(projectName string, serviceName string, number int) string {
+	return fmt.Sprintf("%s_%s", projectName, GetReplicaKey(serviceName, number))
+}
+
+// GetReplicaKey returns the composite key identifying a service replica within a project, as `<service>_<number>`
+func GetReplicaKey(serviceName string, number int) string {
+	return fmt.Sprintf("%s_%d", serviceName, number)
+}
+
+// ParseContainerName returns the service name and replica number of a container named by GetContainerName
+func ParseContainerName(projectName string, name string) (string, int, error) {
+	prefix := projectName + "_"
+	if !strings.HasPrefix(name, prefix) {
+		return "", 0, errors.Errorf("container %q doesn't belong to project %q", name, projectName)
+	}
+	return ParseReplicaKey(strings.TrimPrefix(name, prefix))
+}
+
+// ParseReplicaKey returns the service name and replica number from a replica key set by GetReplicaKey
+func ParseReplicaKey(key string) (string, int, error) {
+	i := strings.LastIndex(key, "_")
+	if i <= 0 {
+		return "", 0, errors.Errorf("invalid replica key %q", key)
+	}
+	number, err := strconv.Atoi(key[i+1:])
+	if err != nil || number < 1 {
+		return "", 0, errors.Errorf("invalid replica number in %q", key)
+	}
+	return key[:i], number, nil
+}
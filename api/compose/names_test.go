@@ -0,0 +1,51 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package compose
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestContainerNameRoundTrip(t *testing.T) {
+	name := GetContainerName("my_project", "web_app", 3)
+	assert.Equal(t, name, "my_project_web_app_3")
+
+	service, number, err := ParseContainerName("my_project", name)
+	assert.NilError(t, err)
+	assert.Equal(t, service, "web_app")
+	assert.Equal(t, number, 3)
+}
+
+func TestReplicaKeyRoundTrip(t *testing.T) {
+	service, number, err := ParseReplicaKey(GetReplicaKey("db", 12))
+	assert.NilError(t, err)
+	assert.Equal(t, service, "db")
+	assert.Equal(t, number, 12)
+}
+
+func TestParseInvalidContainerName(t *testing.T) {
+	_, _, err := ParseContainerName("myproject", "otherproject_web_1")
+	assert.Error(t, err, `container "otherproject_web_1" doesn't belong to project "myproject"`)
+
+	_, _, err = ParseContainerName("myproject", "myproject_web")
+	assert.Error(t, err, `invalid replica key "web"`)
+
+	_, _, err = ParseContainerName("myproject", "myproject_web_x")
+	assert.Error(t, err, `invalid replica number in "web_x"`)
+}
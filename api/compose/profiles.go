@@ -0,0 +1,89 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package compose
+
+import (
+	"strings"
+
+	"github.com/compose-spec/compose-go/types"
+)
+
+// ProfilesExtension declares the profiles a service is enabled by.
+// compose-go doesn't yet load the compose `profiles` attribute, so we rely on `x-profiles` until it does.
+const ProfilesExtension = "x-profiles"
+
+// ApplyProfiles removes from the project services which are not enabled by the active profiles.
+// A service is enabled if it declares no profile, one of its profiles is active, or an enabled service depends on it.
+func ApplyProfiles(project *types.Project, profiles []string) {
+	enabled := map[string]bool{}
+	for _, service := range project.Services {
+		if isEnabled(service, profiles) {
+			enableWithDependencies(project, service.Name, enabled)
+		}
+	}
+
+	var services types.Services
+	for _, service := range project.Services {
+		if enabled[service.Name] {
+			services = append(services, service)
+		}
+	}
+	project.Services = services
+}
+
+func isEnabled(service types.ServiceConfig, activeProfiles []string) bool {
+	profiles := getProfiles(service)
+	if len(profiles) == 0 {
+		return true
+	}
+	for _, p := range profiles {
+		for _, active := range activeProfiles {
+			if p == active {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func enableWithDependencies(project *types.Project, name string, enabled map[string]bool) {
+	if enabled[name] {
+		return
+	}
+	service, err := project.GetService(name)
+	if err != nil {
+		return
+	}
+	enabled[name] = true
+	for _, dependency := range service.GetDependencies() {
+		// links are declared as `service[:alias]`
+		dependency = strings.SplitN(dependency, ":", 2)[0]
+		enableWithDependencies(project, dependency, enabled)
+	}
+}
+
+func getProfiles(service types.ServiceConfig) []string {
+	var profiles []string
+	if v, ok := service.Extensions[ProfilesExtension].([]interface{}); ok {
+		for _, p := range v {
+			if s, ok := p.(string); ok {
+				profiles = append(profiles, s)
+			}
+		}
+	}
+	return profiles
+}
@@ -0,0 +1,61 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package compose
+
+import (
+	"testing"
+
+	"github.com/compose-spec/compose-go/types"
+	"gotest.tools/v3/assert"
+)
+
+func profilesTestProject() *types.Project {
+	return &types.Project{
+		Services: []types.ServiceConfig{
+			{
+				Name: "web",
+			},
+			{
+				Name: "debugger",
+				DependsOn: types.DependsOnConfig{
+					"tracing": {},
+				},
+				Extensions: map[string]interface{}{
+					ProfilesExtension: []interface{}{"debug"},
+				},
+			},
+			{
+				Name: "tracing",
+				Extensions: map[string]interface{}{
+					ProfilesExtension: []interface{}{"monitoring"},
+				},
+			},
+		},
+	}
+}
+
+func TestApplyProfilesSkipsInactiveProfile(t *testing.T) {
+	project := profilesTestProject()
+	ApplyProfiles(project, nil)
+	assert.DeepEqual(t, project.ServiceNames(), []string{"web"})
+}
+
+func TestApplyProfilesEnablesActiveProfileAndDependencies(t *testing.T) {
+	project := profilesTestProject()
+	ApplyProfiles(project, []string{"debug"})
+	assert.DeepEqual(t, project.ServiceNames(), []string{"debugger", "tracing", "web"})
+}
@@ -25,4 +25,8 @@ const (
 	ServiceTag = "com.docker.compose.service"
 	// VolumeTag allow to track resource related to a compose volume
 	VolumeTag = "com.docker.compose.volume"
+	// ContainerNumberTag allow to track the replica number of a service container
+	ContainerNumberTag = "com.docker.compose.container-number"
+	// ReplicaTag allow to track the service replica a container runs, as a key set by GetReplicaKey
+	ReplicaTag = "com.docker.compose.replica"
 )
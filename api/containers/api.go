@@ -144,10 +144,13 @@ type ExecRequest struct {
 
 // LogsRequest contains configuration about a log request
 type LogsRequest struct {
-	Follow bool
-	Tail   string
-	Width  int
-	Writer io.Writer
+	Follow     bool
+	Tail       string
+	Width      int
+	Writer     io.Writer
+	Timestamps bool
+	Since      string
+	Until      string
 }
 
 // DeleteRequest contains configuration about a delete request
@@ -18,14 +18,20 @@ package compose
 
 import (
 	"context"
+	"os"
+	"strings"
 
 	"github.com/compose-spec/compose-go/cli"
+	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 
 	"github.com/spf13/cobra"
 
 	"github.com/docker/compose-cli/api/client"
+	"github.com/docker/compose-cli/cli/mobycli"
 	"github.com/docker/compose-cli/errdefs"
+	"github.com/docker/compose-cli/formatter"
+	"github.com/docker/compose-cli/progress"
 )
 
 type composeOptions struct {
@@ -34,7 +40,9 @@ type composeOptions struct {
 	WorkingDir  string
 	ConfigPaths []string
 	Environment []string
+	Profiles    []string
 	Format      string
+	NoColor     bool
 	Detach      bool
 	Quiet       bool
 }
@@ -45,6 +53,35 @@ func addComposeCommonFlags(f *pflag.FlagSet, opts *composeOptions) {
 	f.BoolVarP(&opts.Quiet, "quiet", "q", false, "Only display IDs")
 }
 
+func addProgressFormatFlag(f *pflag.FlagSet, opts *composeOptions) {
+	f.StringVar(&opts.Format, "format", "", "Format the progress output. Values: [pretty | json]. (Default: pretty)")
+	f.BoolVar(&opts.NoColor, "no-color", false, "Produce monochrome progress output, as when not writing to a terminal")
+}
+
+func (o *composeOptions) setProgressMode() error {
+	switch o.Format {
+	case "", formatter.PRETTY:
+		progress.Mode = progress.ModeAuto
+	case formatter.JSON:
+		progress.Mode = progress.ModeJSON
+	default:
+		return errors.Wrapf(errdefs.ErrParsingFailed, "unsupported format %q", o.Format)
+	}
+	progress.NoColor = o.NoColor
+	return nil
+}
+
+// activeProfiles returns profiles set by --profile flags, or COMPOSE_PROFILES comma-separated list
+func (o *composeOptions) activeProfiles() []string {
+	if len(o.Profiles) > 0 {
+		return o.Profiles
+	}
+	if env := os.Getenv("COMPOSE_PROFILES"); env != "" {
+		return strings.Split(env, ",")
+	}
+	return nil
+}
+
 func (o *composeOptions) toProjectName() (string, error) {
 	if o.Name != "" {
 		return o.Name, nil
@@ -76,6 +113,8 @@ func Command(contextType string) *cobra.Command {
 		Short: "Docker Compose",
 		Use:   "compose",
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// on delegated context types, compose commands are left to the classic cli unless a backend can run them
+			mobycli.ExecIfDefaultCtxType(cmd.Context(), cmd.Root())
 			return checkComposeSupport(cmd.Context())
 		},
 	}
@@ -83,11 +122,21 @@ func Command(contextType string) *cobra.Command {
 	command.AddCommand(
 		upCommand(contextType),
 		downCommand(),
+		pruneCommand(),
+		restartCommand(),
+		runCommand(),
+		pauseCommand(),
+		unpauseCommand(),
 		psCommand(),
 		listCommand(),
 		logsCommand(),
+		eventsCommand(),
+		topCommand(),
+		watchCommand(),
+		configCommand(),
 		convertCommand(),
 	)
+	mobycli.SetNativeCommand(command)
 
 	return command
 }
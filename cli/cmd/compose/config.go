@@ -0,0 +1,94 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package compose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/compose-spec/compose-go/cli"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/docker/compose-cli/api/client"
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/cli/mobycli"
+	"github.com/docker/compose-cli/context/store"
+	"github.com/docker/compose-cli/errdefs"
+)
+
+type configOptions struct {
+	composeOptions
+	services bool
+	hash     bool
+}
+
+func configCommand() *cobra.Command {
+	opts := configOptions{}
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Validate and view the compose file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.services && opts.hash {
+				return errors.New(`cannot combine "--services" and "--hash" options`)
+			}
+			return runConfig(cmd.Context(), opts)
+		},
+	}
+	configCmd.Flags().StringVarP(&opts.Name, "project-name", "p", "", "Project name")
+	configCmd.Flags().StringVar(&opts.WorkingDir, "workdir", "", "Work dir")
+	configCmd.Flags().StringArrayVarP(&opts.ConfigPaths, "file", "f", []string{}, "Compose configuration files")
+	configCmd.Flags().StringArrayVarP(&opts.Environment, "environment", "e", []string{}, "Environment variables")
+	configCmd.Flags().StringVar(&opts.Format, "format", "yaml", "Format the output. Values: [yaml | json]")
+	configCmd.Flags().BoolVar(&opts.services, "services", false, "Print the service names, one per line")
+	configCmd.Flags().BoolVar(&opts.hash, "hash", false, "Print the service names along with their configuration hash, one per line")
+	mobycli.SetCommandContextTypes(configCmd, store.LocalContextType)
+
+	return configCmd
+}
+
+func runConfig(ctx context.Context, opts configOptions) error {
+	c, err := client.New(ctx)
+	if err != nil {
+		return err
+	}
+	configurer, ok := c.ComposeService().(compose.Configurer)
+	if !ok {
+		return errdefs.ErrNotImplemented
+	}
+
+	options, err := opts.toProjectOptions()
+	if err != nil {
+		return err
+	}
+	project, err := cli.ProjectFromOptions(options)
+	if err != nil {
+		return err
+	}
+
+	config, err := configurer.Config(ctx, project, compose.ConfigOptions{
+		Format:   opts.Format,
+		Services: opts.services,
+		Hash:     opts.hash,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(strings.TrimSuffix(string(config), "\n"))
+	return nil
+}
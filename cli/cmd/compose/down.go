@@ -18,40 +18,65 @@ package compose
 
 import (
 	"context"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/docker/compose-cli/api/client"
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/cli/mobycli"
+	"github.com/docker/compose-cli/context/store"
 	"github.com/docker/compose-cli/progress"
 )
 
+type downOptions struct {
+	composeOptions
+	timeout int
+	volumes bool
+}
+
 func downCommand() *cobra.Command {
-	opts := composeOptions{}
+	opts := downOptions{}
 	downCmd := &cobra.Command{
 		Use: "down",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runDown(cmd.Context(), opts)
+			return runDown(cmd.Context(), opts, cmd.Flags().Changed("timeout"))
 		},
 	}
 	downCmd.Flags().StringVarP(&opts.Name, "project-name", "p", "", "Project name")
 	downCmd.Flags().StringVar(&opts.WorkingDir, "workdir", "", "Work dir")
 	downCmd.Flags().StringArrayVarP(&opts.ConfigPaths, "file", "f", []string{}, "Compose configuration files")
+	addProgressFormatFlag(downCmd.Flags(), &opts.composeOptions)
+	downCmd.Flags().IntVarP(&opts.timeout, "timeout", "t", 0, "Specify a shutdown timeout in seconds, overriding services stop_grace_period")
+	downCmd.Flags().BoolVarP(&opts.volumes, "volumes", "v", false, "Remove the volumes created for the project")
+	mobycli.SetFlagContextTypes(downCmd.Flags(), "timeout", store.LocalContextType, store.EcsLocalSimulationContextType)
+	mobycli.SetFlagContextTypes(downCmd.Flags(), "volumes", store.LocalContextType)
 
 	return downCmd
 }
 
-func runDown(ctx context.Context, opts composeOptions) error {
+func runDown(ctx context.Context, opts downOptions, withTimeout bool) error {
 	c, err := client.New(ctx)
 	if err != nil {
 		return err
 	}
+	err = opts.setProgressMode()
+	if err != nil {
+		return err
+	}
+
+	options := compose.DownOptions{Volumes: opts.volumes}
+	if withTimeout {
+		timeout := time.Duration(opts.timeout) * time.Second
+		options.Timeout = &timeout
+	}
 
 	_, err = progress.Run(ctx, func(ctx context.Context) (string, error) {
 		projectName, err := opts.toProjectName()
 		if err != nil {
 			return "", err
 		}
-		return projectName, c.ComposeService().Down(ctx, projectName)
+		return projectName, c.ComposeService().Down(ctx, projectName, options)
 	})
 	return err
 }
@@ -0,0 +1,102 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/docker/compose-cli/api/client"
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/cli/mobycli"
+	"github.com/docker/compose-cli/context/store"
+	"github.com/docker/compose-cli/errdefs"
+)
+
+type eventsOptions struct {
+	composeOptions
+	json bool
+}
+
+func eventsCommand() *cobra.Command {
+	opts := eventsOptions{}
+	eventsCmd := &cobra.Command{
+		Use:   "events [SERVICE...]",
+		Short: "Receive real time events from containers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runEvents(cmd.Context(), opts, args)
+		},
+	}
+	eventsCmd.Flags().StringVarP(&opts.Name, "project-name", "p", "", "Project name")
+	eventsCmd.Flags().StringVar(&opts.WorkingDir, "workdir", "", "Work dir")
+	eventsCmd.Flags().StringArrayVarP(&opts.ConfigPaths, "file", "f", []string{}, "Compose configuration files")
+	eventsCmd.Flags().BoolVar(&opts.json, "json", false, "Output events as a stream of json objects")
+	mobycli.SetCommandContextTypes(eventsCmd, store.LocalContextType)
+
+	return eventsCmd
+}
+
+func runEvents(ctx context.Context, opts eventsOptions, services []string) error {
+	c, err := client.New(ctx)
+	if err != nil {
+		return err
+	}
+	streamer, ok := c.ComposeService().(compose.EventStreamer)
+	if !ok {
+		return errdefs.ErrNotImplemented
+	}
+
+	projectName, err := opts.toProjectName()
+	if err != nil {
+		return err
+	}
+	return streamer.Events(ctx, projectName, compose.EventsOptions{
+		Services: services,
+		Consumer: func(event compose.Event) error {
+			if opts.json {
+				marshalled, err := json.Marshal(map[string]interface{}{
+					"time":       event.Timestamp,
+					"type":       "container",
+					"service":    event.Service,
+					"id":         event.Container,
+					"action":     event.Status,
+					"attributes": event.Attributes,
+				})
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(os.Stdout, string(marshalled))
+				return err
+			}
+			var attributes []string
+			for k, v := range event.Attributes {
+				attributes = append(attributes, fmt.Sprintf("%s=%s", k, v))
+			}
+			sort.Strings(attributes)
+			attributes = append([]string{fmt.Sprintf("service=%s", event.Service)}, attributes...)
+			_, err := fmt.Fprintf(os.Stdout, "%s container %s %s (%s)\n", event.Timestamp.Format(time.RFC3339Nano), event.Status, event.Container, strings.Join(attributes, ", "))
+			return err
+		},
+	})
+}
@@ -23,10 +23,18 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/docker/compose-cli/api/client"
+	"github.com/docker/compose-cli/api/compose"
 )
 
+type logsOptions struct {
+	composeOptions
+	timestamps bool
+	since      string
+	until      string
+}
+
 func logsCommand() *cobra.Command {
-	opts := composeOptions{}
+	opts := logsOptions{}
 	logsCmd := &cobra.Command{
 		Use: "logs",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -36,11 +44,14 @@ func logsCommand() *cobra.Command {
 	logsCmd.Flags().StringVarP(&opts.Name, "project-name", "p", "", "Project name")
 	logsCmd.Flags().StringVar(&opts.WorkingDir, "workdir", "", "Work dir")
 	logsCmd.Flags().StringArrayVarP(&opts.ConfigPaths, "file", "f", []string{}, "Compose configuration files")
+	logsCmd.Flags().BoolVarP(&opts.timestamps, "timestamps", "t", false, "Show timestamps")
+	logsCmd.Flags().StringVar(&opts.since, "since", "", "Show logs since timestamp (e.g. 2013-01-02T13:23:37) or relative (e.g. 42m for 42 minutes)")
+	logsCmd.Flags().StringVar(&opts.until, "until", "", "Show logs before a timestamp (e.g. 2013-01-02T13:23:37) or relative (e.g. 42m for 42 minutes)")
 
 	return logsCmd
 }
 
-func runLogs(ctx context.Context, opts composeOptions) error {
+func runLogs(ctx context.Context, opts logsOptions) error {
 	c, err := client.New(ctx)
 	if err != nil {
 		return err
@@ -50,5 +61,9 @@ func runLogs(ctx context.Context, opts composeOptions) error {
 	if err != nil {
 		return err
 	}
-	return c.ComposeService().Logs(ctx, projectName, os.Stdout)
+	return c.ComposeService().Logs(ctx, projectName, os.Stdout, compose.LogOptions{
+		Timestamps: opts.timestamps,
+		Since:      opts.since,
+		Until:      opts.until,
+	})
 }
@@ -0,0 +1,94 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package compose
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/docker/compose-cli/api/client"
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/cli/mobycli"
+	"github.com/docker/compose-cli/context/store"
+	"github.com/docker/compose-cli/errdefs"
+	"github.com/docker/compose-cli/progress"
+)
+
+func pauseCommand() *cobra.Command {
+	opts := composeOptions{}
+	pauseCmd := &cobra.Command{
+		Use:   "pause [SERVICE...]",
+		Short: "Pause services",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPause(cmd.Context(), opts, args, false)
+		},
+	}
+	addPauseFlags(pauseCmd, &opts)
+	return pauseCmd
+}
+
+func unpauseCommand() *cobra.Command {
+	opts := composeOptions{}
+	unpauseCmd := &cobra.Command{
+		Use:   "unpause [SERVICE...]",
+		Short: "Unpause services",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPause(cmd.Context(), opts, args, true)
+		},
+	}
+	addPauseFlags(unpauseCmd, &opts)
+	return unpauseCmd
+}
+
+func addPauseFlags(cmd *cobra.Command, opts *composeOptions) {
+	cmd.Flags().StringVarP(&opts.Name, "project-name", "p", "", "Project name")
+	cmd.Flags().StringVar(&opts.WorkingDir, "workdir", "", "Work dir")
+	cmd.Flags().StringArrayVarP(&opts.ConfigPaths, "file", "f", []string{}, "Compose configuration files")
+	addProgressFormatFlag(cmd.Flags(), opts)
+	mobycli.SetCommandContextTypes(cmd, store.LocalContextType)
+}
+
+func runPause(ctx context.Context, opts composeOptions, services []string, unpause bool) error {
+	c, err := client.New(ctx)
+	if err != nil {
+		return err
+	}
+	pauser, ok := c.ComposeService().(compose.Pauser)
+	if !ok {
+		return errdefs.ErrNotImplemented
+	}
+	err = opts.setProgressMode()
+	if err != nil {
+		return err
+	}
+
+	options := compose.PauseOptions{
+		Services: services,
+	}
+	_, err = progress.Run(ctx, func(ctx context.Context) (string, error) {
+		projectName, err := opts.toProjectName()
+		if err != nil {
+			return "", err
+		}
+		if unpause {
+			return projectName, pauser.Unpause(ctx, projectName, options)
+		}
+		return projectName, pauser.Pause(ctx, projectName, options)
+	})
+	return err
+}
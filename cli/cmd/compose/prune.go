@@ -0,0 +1,72 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package compose
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/docker/compose-cli/api/client"
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/cli/mobycli"
+	"github.com/docker/compose-cli/context/store"
+	"github.com/docker/compose-cli/errdefs"
+	"github.com/docker/compose-cli/progress"
+)
+
+func pruneCommand() *cobra.Command {
+	opts := composeOptions{}
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove stopped containers and unused networks of the project",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPrune(cmd.Context(), opts)
+		},
+	}
+	pruneCmd.Flags().StringVarP(&opts.Name, "project-name", "p", "", "Project name")
+	pruneCmd.Flags().StringVar(&opts.WorkingDir, "workdir", "", "Work dir")
+	pruneCmd.Flags().StringArrayVarP(&opts.ConfigPaths, "file", "f", []string{}, "Compose configuration files")
+	addProgressFormatFlag(pruneCmd.Flags(), &opts)
+	mobycli.SetCommandContextTypes(pruneCmd, store.LocalContextType)
+
+	return pruneCmd
+}
+
+func runPrune(ctx context.Context, opts composeOptions) error {
+	c, err := client.New(ctx)
+	if err != nil {
+		return err
+	}
+	pruner, ok := c.ComposeService().(compose.Pruner)
+	if !ok {
+		return errdefs.ErrNotImplemented
+	}
+	err = opts.setProgressMode()
+	if err != nil {
+		return err
+	}
+
+	_, err = progress.Run(ctx, func(ctx context.Context) (string, error) {
+		projectName, err := opts.toProjectName()
+		if err != nil {
+			return "", err
+		}
+		return projectName, pruner.Prune(ctx, projectName)
+	})
+	return err
+}
@@ -75,22 +75,24 @@ func runPs(ctx context.Context, opts composeOptions) error {
 }
 
 type serviceStatusView struct {
-	ID       string
-	Name     string
-	Replicas int
-	Desired  int
-	Ports    []string
+	ID         string
+	Name       string
+	Replicas   int
+	Desired    int
+	Ports      []string
+	Containers []compose.ContainerSummary `json:",omitempty"`
 }
 
 func viewFromServiceStatusList(serviceStatusList []compose.ServiceStatus) []serviceStatusView {
 	retList := make([]serviceStatusView, len(serviceStatusList))
 	for i, s := range serviceStatusList {
 		retList[i] = serviceStatusView{
-			ID:       s.ID,
-			Name:     s.Name,
-			Replicas: s.Replicas,
-			Desired:  s.Desired,
-			Ports:    s.Ports,
+			ID:         s.ID,
+			Name:       s.Name,
+			Replicas:   s.Replicas,
+			Desired:    s.Desired,
+			Ports:      s.Ports,
+			Containers: s.Containers,
 		}
 	}
 	return retList
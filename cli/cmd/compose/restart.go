@@ -0,0 +1,87 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package compose
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/docker/compose-cli/api/client"
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/cli/mobycli"
+	"github.com/docker/compose-cli/context/store"
+	"github.com/docker/compose-cli/errdefs"
+	"github.com/docker/compose-cli/progress"
+)
+
+type restartOptions struct {
+	composeOptions
+	timeout int
+}
+
+func restartCommand() *cobra.Command {
+	opts := restartOptions{}
+	restartCmd := &cobra.Command{
+		Use:   "restart [SERVICE...]",
+		Short: "Restart containers, without applying configuration changes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRestart(cmd.Context(), opts, args, cmd.Flags().Changed("timeout"))
+		},
+	}
+	restartCmd.Flags().StringVarP(&opts.Name, "project-name", "p", "", "Project name")
+	restartCmd.Flags().StringVar(&opts.WorkingDir, "workdir", "", "Work dir")
+	restartCmd.Flags().StringArrayVarP(&opts.ConfigPaths, "file", "f", []string{}, "Compose configuration files")
+	addProgressFormatFlag(restartCmd.Flags(), &opts.composeOptions)
+	restartCmd.Flags().IntVarP(&opts.timeout, "timeout", "t", 0, "Specify a shutdown timeout in seconds, overriding services stop_grace_period")
+	mobycli.SetCommandContextTypes(restartCmd, store.LocalContextType)
+
+	return restartCmd
+}
+
+func runRestart(ctx context.Context, opts restartOptions, services []string, withTimeout bool) error {
+	c, err := client.New(ctx)
+	if err != nil {
+		return err
+	}
+	restarter, ok := c.ComposeService().(compose.Restarter)
+	if !ok {
+		return errdefs.ErrNotImplemented
+	}
+	err = opts.setProgressMode()
+	if err != nil {
+		return err
+	}
+
+	options := compose.RestartOptions{
+		Services: services,
+	}
+	if withTimeout {
+		timeout := time.Duration(opts.timeout) * time.Second
+		options.Timeout = &timeout
+	}
+
+	_, err = progress.Run(ctx, func(ctx context.Context) (string, error) {
+		projectName, err := opts.toProjectName()
+		if err != nil {
+			return "", err
+		}
+		return projectName, restarter.Restart(ctx, projectName, options)
+	})
+	return err
+}
@@ -0,0 +1,93 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package compose
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/compose-spec/compose-go/cli"
+	"github.com/spf13/cobra"
+
+	"github.com/docker/compose-cli/api/client"
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/cli/mobycli"
+	"github.com/docker/compose-cli/context/store"
+	"github.com/docker/compose-cli/errdefs"
+)
+
+type runOptions struct {
+	composeOptions
+	autoRemove bool
+}
+
+func runCommand() *cobra.Command {
+	opts := runOptions{}
+	runCmd := &cobra.Command{
+		Use:   "run [OPTIONS] SERVICE [COMMAND] [ARGS...]",
+		Short: "Run a oneoff command on a service",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRun(cmd.Context(), opts, args[0], args[1:])
+		},
+	}
+	runCmd.Flags().StringVarP(&opts.Name, "project-name", "p", "", "Project name")
+	runCmd.Flags().StringVar(&opts.WorkingDir, "workdir", "", "Work dir")
+	runCmd.Flags().StringArrayVarP(&opts.ConfigPaths, "file", "f", []string{}, "Compose configuration files")
+	runCmd.Flags().BoolVarP(&opts.Detach, "detach", "d", false, "Run container in background and print container name")
+	runCmd.Flags().BoolVar(&opts.autoRemove, "rm", false, "Remove container after run")
+	// flags after the service name are passed to the command
+	runCmd.Flags().SetInterspersed(false)
+	mobycli.SetCommandContextTypes(runCmd, store.LocalContextType)
+
+	return runCmd
+}
+
+func runRun(ctx context.Context, opts runOptions, service string, command []string) error {
+	c, err := client.New(ctx)
+	if err != nil {
+		return err
+	}
+	runner, ok := c.ComposeService().(compose.Runner)
+	if !ok {
+		return errdefs.ErrNotImplemented
+	}
+
+	options, err := opts.toProjectOptions()
+	if err != nil {
+		return err
+	}
+	project, err := cli.ProjectFromOptions(options)
+	if err != nil {
+		return err
+	}
+	name, err := runner.RunOneOffContainer(ctx, project, compose.RunOptions{
+		Service:    service,
+		Command:    command,
+		Detach:     opts.Detach,
+		AutoRemove: opts.autoRemove,
+		Writer:     os.Stdout,
+	})
+	if err != nil {
+		return err
+	}
+	if opts.Detach {
+		fmt.Println(name)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package compose
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/docker/compose-cli/api/client"
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/cli/mobycli"
+	"github.com/docker/compose-cli/context/store"
+	"github.com/docker/compose-cli/errdefs"
+	"github.com/docker/compose-cli/formatter"
+)
+
+func topCommand() *cobra.Command {
+	opts := composeOptions{}
+	topCmd := &cobra.Command{
+		Use:   "top [SERVICE...]",
+		Short: "Display the running processes of services",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTop(cmd.Context(), opts, args)
+		},
+	}
+	topCmd.Flags().StringVarP(&opts.Name, "project-name", "p", "", "Project name")
+	topCmd.Flags().StringVar(&opts.WorkingDir, "workdir", "", "Work dir")
+	topCmd.Flags().StringArrayVarP(&opts.ConfigPaths, "file", "f", []string{}, "Compose configuration files")
+	mobycli.SetCommandContextTypes(topCmd, store.LocalContextType)
+
+	return topCmd
+}
+
+func runTop(ctx context.Context, opts composeOptions, services []string) error {
+	c, err := client.New(ctx)
+	if err != nil {
+		return err
+	}
+	topper, ok := c.ComposeService().(compose.Topper)
+	if !ok {
+		return errdefs.ErrNotImplemented
+	}
+
+	projectName, err := opts.toProjectName()
+	if err != nil {
+		return err
+	}
+	summaries, err := topper.Top(ctx, projectName, compose.TopOptions{
+		Services: services,
+	})
+	if err != nil {
+		return err
+	}
+
+	service := ""
+	for _, summary := range summaries {
+		if summary.Service != service {
+			service = summary.Service
+			fmt.Printf("%s\n", service)
+		}
+		fmt.Printf("%s\n", summary.Name)
+		err := formatter.PrintPrettySection(os.Stdout, func(w io.Writer) {
+			for _, process := range summary.Processes {
+				_, _ = fmt.Fprintln(w, strings.Join(process, "\t"))
+			}
+		}, summary.Titles...)
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -18,29 +18,200 @@ package compose
 
 import (
 	"context"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/mattn/go-shellwords"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"github.com/compose-spec/compose-go/cli"
+	"github.com/compose-spec/compose-go/types"
 
 	"github.com/docker/compose-cli/api/client"
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/cli/mobycli"
 	"github.com/docker/compose-cli/context/store"
 	"github.com/docker/compose-cli/progress"
+	"github.com/docker/compose-cli/utils"
 )
 
+type upOptions struct {
+	composeOptions
+	rollback          bool
+	rollbackScope     string
+	recreateUnhealthy bool
+	renewAnonVolumes  bool
+	forceRecreate     bool
+	noRecreate        bool
+	recreateFiles     bool
+	noDeps            bool
+	parallel          int
+	wait              bool
+	waitTimeout       int
+	timeout           int
+	attachOnly        bool
+	scale             []string
+	command           []string
+	entrypoint        []string
+	nameConflict      string
+	strictEnv         bool
+	continueOnError   bool
+	adopt             bool
+	timestamps        bool
+	dependents        string
+	pull              string
+	quietPull         bool
+	collapseDone      int
+	attach            []string
+	noAttach          []string
+}
+
+func (opts upOptions) validate() error {
+	if opts.forceRecreate && opts.noRecreate {
+		return errors.New(`cannot combine "--force-recreate" and "--no-recreate" options`)
+	}
+	if opts.recreateFiles && opts.noRecreate {
+		return errors.New(`cannot combine "--recreate-changed-files" and "--no-recreate" options`)
+	}
+	if opts.attachOnly && opts.Detach {
+		return errors.New(`cannot combine "--attach-only" and "--detach" options`)
+	}
+	if opts.waitTimeout < 0 {
+		return errors.New(`"--wait-timeout" must be a positive number`)
+	}
+	if opts.timeout < 0 {
+		return errors.New(`"--timeout" must be a positive number`)
+	}
+	if len(opts.attach) > 0 && len(opts.noAttach) > 0 {
+		return errors.New(`cannot combine "--attach" and "--no-attach" options`)
+	}
+	if opts.collapseDone < 0 {
+		return errors.New(`"--collapse-done" must be a positive number`)
+	}
+	if opts.parallel < 0 {
+		return errors.New(`"--parallel" must be a positive number`)
+	}
+	switch opts.nameConflict {
+	case compose.NameConflictFail, compose.NameConflictReplace, compose.NameConflictAdopt:
+	default:
+		return errors.Errorf(`invalid "--on-name-conflict" value %q: must be %q, %q or %q`, opts.nameConflict, compose.NameConflictFail, compose.NameConflictReplace, compose.NameConflictAdopt)
+	}
+	switch opts.pull {
+	case "", compose.PullAlways, compose.PullMissing, compose.PullNever:
+	default:
+		return errors.Errorf(`invalid "--pull" value %q: must be %q, %q or %q`, opts.pull, compose.PullAlways, compose.PullMissing, compose.PullNever)
+	}
+	switch opts.rollbackScope {
+	case compose.RollbackCreated, compose.RollbackAll:
+	default:
+		return errors.Errorf(`invalid "--rollback-scope" value %q: must be %q or %q`, opts.rollbackScope, compose.RollbackCreated, compose.RollbackAll)
+	}
+	switch opts.dependents {
+	case compose.RecreateDependentsAll, compose.RecreateDependentsConsumers:
+	default:
+		return errors.Errorf(`invalid "--recreate-dependents" value %q: must be %q or %q`, opts.dependents, compose.RecreateDependentsAll, compose.RecreateDependentsConsumers)
+	}
+	return nil
+}
+
+// scaleOverrides parses --scale flags, set as `service=replicas`
+func (opts upOptions) scaleOverrides() (map[string]int, error) {
+	scale := map[string]int{}
+	for _, s := range opts.scale {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid scale %q: expected SERVICE=NUM", s)
+		}
+		replicas, err := strconv.Atoi(parts[1])
+		if err != nil || replicas < 0 {
+			return nil, errors.Errorf("invalid scale %q: number of replicas must be a non-negative integer", s)
+		}
+		scale[parts[0]] = replicas
+	}
+	return scale, nil
+}
+
+// commandOverrides parses --command or --entrypoint flags, set as `service=command` with command split as a shell
+// would do
+func commandOverrides(flag string, values []string) (map[string][]string, error) {
+	overrides := map[string][]string{}
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid %s %q: expected SERVICE=%s", flag, v, strings.ToUpper(flag))
+		}
+		args, err := shellwords.Parse(parts[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s %q", flag, v)
+		}
+		overrides[parts[0]] = args
+	}
+	return overrides, nil
+}
+
+func (opts upOptions) recreateStrategy() string {
+	if opts.forceRecreate {
+		return compose.RecreateForce
+	}
+	if opts.noRecreate {
+		return compose.RecreateNever
+	}
+	return compose.RecreateDiverged
+}
+
 func upCommand(contextType string) *cobra.Command {
-	opts := composeOptions{}
+	opts := upOptions{}
 	upCmd := &cobra.Command{
-		Use: "up",
+		Use: "up [SERVICE...]",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runUp(cmd.Context(), opts)
+			err := opts.validate()
+			if err != nil {
+				return err
+			}
+			return runUp(cmd.Context(), contextType, opts, args)
 		},
 	}
 	upCmd.Flags().StringVarP(&opts.Name, "project-name", "p", "", "Project name")
 	upCmd.Flags().StringVar(&opts.WorkingDir, "workdir", "", "Work dir")
 	upCmd.Flags().StringArrayVarP(&opts.ConfigPaths, "file", "f", []string{}, "Compose configuration files")
+	addProgressFormatFlag(upCmd.Flags(), &opts.composeOptions)
+	upCmd.Flags().IntVar(&opts.collapseDone, "collapse-done", 0, "Hide progress of completed services after this number of seconds, only listing those in progress or failed")
 	upCmd.Flags().StringArrayVarP(&opts.Environment, "environment", "e", []string{}, "Environment variables")
+	upCmd.Flags().StringArrayVar(&opts.Profiles, "profile", []string{}, "Specify a profile to enable")
 	upCmd.Flags().BoolVarP(&opts.Detach, "detach", "d", false, " Detached mode: Run containers in the background")
+	upCmd.Flags().BoolVar(&opts.rollback, "rollback", false, "Remove containers created by this command if it gets interrupted or fails")
+	upCmd.Flags().StringVar(&opts.rollbackScope, "rollback-scope", compose.RollbackCreated, `Changes undone on rollback: containers "created" for new replicas, or "all" to also restore recreated containers`)
+	upCmd.Flags().BoolVar(&opts.recreateUnhealthy, "recreate-unhealthy", false, "Recreate running containers reported unhealthy")
+	upCmd.Flags().BoolVar(&opts.forceRecreate, "force-recreate", false, "Recreate containers even if their configuration haven't changed")
+	upCmd.Flags().BoolVar(&opts.noRecreate, "no-recreate", false, "If containers already exist, don't recreate them")
+	upCmd.Flags().BoolVar(&opts.recreateFiles, "recreate-changed-files", false, "Recreate containers when files defining the configs and secrets they use changed")
+	upCmd.Flags().BoolVar(&opts.noDeps, "no-deps", false, "Don't start linked services")
+	upCmd.Flags().IntVar(&opts.parallel, "parallel", 0, "Maximum number of container operations run concurrently for a service")
+	upCmd.Flags().BoolVar(&opts.wait, "wait", false, "Wait for services with a healthcheck to be healthy")
+	upCmd.Flags().IntVar(&opts.waitTimeout, "wait-timeout", 0, "Maximum duration in seconds to wait for services to be healthy")
+	upCmd.Flags().IntVar(&opts.timeout, "timeout", 0, "Maximum duration in seconds for services to converge, cancelling operations in progress once elapsed")
+	upCmd.Flags().BoolVar(&opts.attachOnly, "attach-only", false, "Attach to running containers without creating, recreating or starting any")
+	upCmd.Flags().StringArrayVar(&opts.scale, "scale", []string{}, "Scale SERVICE to NUM instances. Overrides the scale setting in the Compose file if present.")
+	upCmd.Flags().StringArrayVar(&opts.command, "command", []string{}, "Override the command of SERVICE, set as SERVICE=COMMAND")
+	upCmd.Flags().StringArrayVar(&opts.entrypoint, "entrypoint", []string{}, "Override the entrypoint of SERVICE, set as SERVICE=ENTRYPOINT")
+	upCmd.Flags().StringVar(&opts.nameConflict, "on-name-conflict", compose.NameConflictFail, `Policy for a container with the name of one to create, which compose doesn't manage: "fail", "replace" (force removes it) or "adopt"`)
+	upCmd.Flags().BoolVar(&opts.strictEnv, "strict-env", false, "Fail if services have environment variables left unset or empty")
+	upCmd.Flags().BoolVar(&opts.continueOnError, "continue-on-error", false, "Keep converging other services when one fails, and report all failures at the end")
+	upCmd.Flags().BoolVar(&opts.adopt, "adopt", false, "Keep containers created by another tool if they run the service image and ports, instead of recreating them")
+	upCmd.Flags().BoolVar(&opts.quietPull, "quiet-pull", false, "Pull without printing progress information of image layers")
+	upCmd.Flags().StringVar(&opts.pull, "pull", "", `Pull images before running, overriding services pull_policy: "always", "missing" or "never"`)
+	upCmd.Flags().StringVar(&opts.dependents, "recreate-dependents", compose.RecreateDependentsAll, `Services to recreate along with a recreated dependency: "all", or only "consumers" linking to it, using its volumes or network`)
+	upCmd.Flags().BoolVar(&opts.timestamps, "timestamps", false, "Show timestamps of attached services logs")
+	upCmd.Flags().StringArrayVar(&opts.attach, "attach", []string{}, "Only show logs of the given service, all services still get started")
+	upCmd.Flags().StringArrayVar(&opts.noAttach, "no-attach", []string{}, "Don't show logs of the given service")
+	upCmd.Flags().BoolVarP(&opts.renewAnonVolumes, "renew-anon-volumes", "V", false, "Recreate anonymous volumes instead of retrieving data from the previous containers")
+
+	for _, flag := range []string{"rollback", "rollback-scope", "recreate-unhealthy", "force-recreate", "no-recreate", "recreate-changed-files", "renew-anon-volumes", "no-deps", "parallel", "wait", "wait-timeout", "timeout", "attach-only", "scale", "command", "entrypoint", "on-name-conflict", "strict-env", "continue-on-error", "adopt", "timestamps", "attach", "no-attach", "recreate-dependents", "pull", "quiet-pull"} {
+		mobycli.SetFlagContextTypes(upCmd.Flags(), flag, store.LocalContextType)
+	}
 
 	if contextType == store.AciContextType {
 		upCmd.Flags().StringVar(&opts.DomainName, "domainname", "", "Container NIS domain name")
@@ -49,26 +220,112 @@ func upCommand(contextType string) *cobra.Command {
 	return upCmd
 }
 
-func runUp(ctx context.Context, opts composeOptions) error {
+func runUp(ctx context.Context, contextType string, opts upOptions, services []string) error {
 	c, err := client.New(ctx)
 	if err != nil {
 		return err
 	}
+	err = opts.setProgressMode()
+	if err != nil {
+		return err
+	}
+	progress.CollapseDelay = time.Duration(opts.collapseDone) * time.Second
 
+	scale, err := opts.scaleOverrides()
+	if err != nil {
+		return err
+	}
+	command, err := commandOverrides("command", opts.command)
+	if err != nil {
+		return err
+	}
+	entrypoint, err := commandOverrides("entrypoint", opts.entrypoint)
+	if err != nil {
+		return err
+	}
+
+	var (
+		projectName string
+		attached    []string
+		attachNone  bool
+	)
 	_, err = progress.Run(ctx, func(ctx context.Context) (string, error) {
 		options, err := opts.toProjectOptions()
 		if err != nil {
 			return "", err
 		}
 		project, err := cli.ProjectFromOptions(options)
-		if opts.DomainName != "" {
-			//arbitrarily set the domain name on the first service ; ACI backend will expose the entire project
-			project.Services[0].DomainName = opts.DomainName
+		if err != nil {
+			return "", err
 		}
+		projectName = project.Name
+		compose.ApplyProfiles(project, opts.activeProfiles())
+		attached, attachNone, err = opts.attachedServices(project)
 		if err != nil {
 			return "", err
 		}
-		return "", c.ComposeService().Up(ctx, project, opts.Detach)
+		if opts.DomainName != "" {
+			//arbitrarily set the domain name on the first service ; ACI backend will expose the entire project
+			project.Services[0].DomainName = opts.DomainName
+		}
+		return "", c.ComposeService().Up(ctx, project, compose.UpOptions{
+			Detach:               opts.Detach,
+			Rollback:             opts.rollback,
+			RollbackScope:        opts.rollbackScope,
+			RecreateUnhealthy:    opts.recreateUnhealthy,
+			RenewAnonVolumes:     opts.renewAnonVolumes,
+			Recreate:             opts.recreateStrategy(),
+			RecreateChangedFiles: opts.recreateFiles,
+			Services:             services,
+			NoDeps:               opts.noDeps,
+			Parallel:             opts.parallel,
+			Wait:                 opts.wait,
+			WaitTimeout:          time.Duration(opts.waitTimeout) * time.Second,
+			Timeout:              time.Duration(opts.timeout) * time.Second,
+			AttachOnly:           opts.attachOnly,
+			Scale:                scale,
+			Command:              command,
+			Entrypoint:           entrypoint,
+			NameConflict:         opts.nameConflict,
+			StrictEnvironment:    opts.strictEnv,
+			ContinueOnError:      opts.continueOnError,
+			Adopt:                opts.adopt,
+			RecreateDependents:   opts.dependents,
+			Pull:                 opts.pull,
+			QuietPull:            opts.quietPull,
+		})
+	})
+	if err != nil || opts.Detach || contextType != store.LocalContextType {
+		return err
+	}
+	if attachNone {
+		// all services run silently, only wait for user to interrupt
+		<-ctx.Done()
+		return nil
+	}
+	// attached mode, follow services logs until user interrupts
+	return c.ComposeService().Logs(ctx, projectName, os.Stdout, compose.LogOptions{
+		Timestamps: opts.timestamps,
+		Services:   attached,
 	})
-	return err
+}
+
+// attachedServices returns the services to show logs of set by --attach, or all services but those set by
+// --no-attach. All services are attached when empty, the boolean reports none is when --no-attach lists them all
+func (opts upOptions) attachedServices(project *types.Project) ([]string, bool, error) {
+	for _, name := range append(opts.attach, opts.noAttach...) {
+		if _, err := project.GetService(name); err != nil {
+			return nil, false, errors.Errorf("can't attach to service %q: no such service", name)
+		}
+	}
+	if len(opts.noAttach) == 0 {
+		return opts.attach, false, nil
+	}
+	var attached []string
+	for _, service := range project.Services {
+		if !utils.StringContains(opts.noAttach, service.Name) {
+			attached = append(attached, service.Name)
+		}
+	}
+	return attached, len(attached) == 0, nil
 }
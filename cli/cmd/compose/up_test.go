@@ -0,0 +1,47 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package compose
+
+import (
+	"testing"
+
+	"github.com/compose-spec/compose-go/types"
+	"gotest.tools/v3/assert"
+)
+
+func TestAttachedServices(t *testing.T) {
+	project := &types.Project{Services: []types.ServiceConfig{{Name: "web"}, {Name: "db"}, {Name: "cache"}}}
+
+	attached, none, err := upOptions{attach: []string{"web"}}.attachedServices(project)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, attached, []string{"web"})
+	assert.Assert(t, !none)
+
+	attached, none, err = upOptions{noAttach: []string{"db"}}.attachedServices(project)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, attached, []string{"web", "cache"})
+	assert.Assert(t, !none)
+
+	// all services run silently, rather than none being filtered out
+	attached, none, err = upOptions{noAttach: []string{"web", "db", "cache"}}.attachedServices(project)
+	assert.NilError(t, err)
+	assert.Equal(t, len(attached), 0)
+	assert.Assert(t, none)
+
+	_, _, err = upOptions{noAttach: []string{"worker"}}.attachedServices(project)
+	assert.Error(t, err, `can't attach to service "worker": no such service`)
+}
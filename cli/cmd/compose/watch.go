@@ -0,0 +1,79 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package compose
+
+import (
+	"context"
+
+	"github.com/compose-spec/compose-go/cli"
+	"github.com/spf13/cobra"
+
+	"github.com/docker/compose-cli/api/client"
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/cli/mobycli"
+	"github.com/docker/compose-cli/context/store"
+	"github.com/docker/compose-cli/errdefs"
+	"github.com/docker/compose-cli/progress"
+)
+
+func watchCommand() *cobra.Command {
+	opts := composeOptions{}
+	watchCmd := &cobra.Command{
+		Use:   "watch [SERVICE...]",
+		Short: "Rebuild and recreate services when their source files change",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWatch(cmd.Context(), opts, args)
+		},
+	}
+	watchCmd.Flags().StringVarP(&opts.Name, "project-name", "p", "", "Project name")
+	watchCmd.Flags().StringVar(&opts.WorkingDir, "workdir", "", "Work dir")
+	watchCmd.Flags().StringArrayVarP(&opts.ConfigPaths, "file", "f", []string{}, "Compose configuration files")
+	addProgressFormatFlag(watchCmd.Flags(), &opts)
+	mobycli.SetCommandContextTypes(watchCmd, store.LocalContextType)
+
+	return watchCmd
+}
+
+func runWatch(ctx context.Context, opts composeOptions, services []string) error {
+	c, err := client.New(ctx)
+	if err != nil {
+		return err
+	}
+	watcher, ok := c.ComposeService().(compose.Watcher)
+	if !ok {
+		return errdefs.ErrNotImplemented
+	}
+	err = opts.setProgressMode()
+	if err != nil {
+		return err
+	}
+
+	_, err = progress.Run(ctx, func(ctx context.Context) (string, error) {
+		options, err := opts.toProjectOptions()
+		if err != nil {
+			return "", err
+		}
+		project, err := cli.ProjectFromOptions(options)
+		if err != nil {
+			return "", err
+		}
+		return "", watcher.Watch(ctx, project, compose.WatchOptions{
+			Services: services,
+		})
+	})
+	return err
+}
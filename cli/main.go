@@ -193,6 +193,7 @@ func main() {
 		compose.Command(ctype),
 		volume.Command(ctype),
 	)
+	mobycli.HideUnavailable(root, ctype)
 
 	ctx = apicontext.WithCurrentContext(ctx, currentContext)
 	ctx = store.WithContextStore(ctx, s)
@@ -229,6 +230,10 @@ func exit(ctx string, err error, ctype string) {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(errdefs.ExitCodeLoginRequired)
 	}
+	if errdefs.IsDaemonError(err) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(errdefs.ExitCodeDaemonError)
+	}
 	if errors.Is(err, errdefs.ErrNotImplemented) {
 		name := metrics.GetCommand(os.Args[1:])
 		fmt.Fprintf(os.Stderr, "Command %q not available in current context (%s)\n", name, ctx)
@@ -0,0 +1,76 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mobycli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/compose-cli/context/store"
+)
+
+const (
+	envDockerHost      = "DOCKER_HOST"
+	envDockerCertPath  = "DOCKER_CERT_PATH"
+	envDockerTLSVerify = "DOCKER_TLS_VERIFY"
+)
+
+var tlsFiles = []string{"ca.pem", "cert.pem", "key.pem"}
+
+// dockerEndpointEnv returns the environment for a delegated docker command so
+// it talks to the daemon of the docker endpoint of the given context. It
+// returns nil, meaning the current environment is inherited as is, for the
+// default context, which is already resolved from the environment, or when
+// the context has no docker endpoint.
+func dockerEndpointEnv(s store.Store, dockerCtx *store.DockerContext) []string {
+	if dockerCtx.Name == store.DefaultContextName {
+		return nil
+	}
+	endpoint, ok := dockerCtx.Endpoints[store.DockerEndpoint].(*store.Endpoint)
+	if !ok || endpoint.Host == "" {
+		return nil
+	}
+
+	env := []string{}
+	for _, v := range os.Environ() {
+		switch strings.SplitN(v, "=", 2)[0] {
+		case envDockerHost, envDockerCertPath, envDockerTLSVerify:
+			continue
+		}
+		env = append(env, v)
+	}
+	env = append(env, envDockerHost+"="+endpoint.Host)
+
+	tlsDir := s.TLSDir(dockerCtx.Name, store.DockerEndpoint)
+	if hasTLSMaterial(tlsDir) {
+		env = append(env, envDockerCertPath+"="+tlsDir)
+		if !endpoint.SkipTLSVerify {
+			env = append(env, envDockerTLSVerify+"=1")
+		}
+	}
+	return env
+}
+
+func hasTLSMaterial(dir string) bool {
+	for _, f := range tlsFiles {
+		if _, err := os.Stat(filepath.Join(dir, f)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mobycli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+
+	"github.com/docker/compose-cli/context/store"
+)
+
+func newTestStore(t *testing.T) store.Store {
+	dir, err := ioutil.TempDir("", "store")
+	assert.NilError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	s, err := store.New(dir)
+	assert.NilError(t, err)
+	return s
+}
+
+func TestDockerEndpointEnv(t *testing.T) {
+	s := newTestStore(t)
+	dockerCtx := &store.DockerContext{
+		Name: "remote",
+		Endpoints: map[string]interface{}{
+			store.DockerEndpoint: &store.Endpoint{Host: "tcp://remote:2375"},
+		},
+	}
+	os.Setenv("DOCKER_HOST", "unix:///var/run/docker.sock") // nolint errcheck
+	defer os.Unsetenv("DOCKER_HOST")                        // nolint errcheck
+
+	env := dockerEndpointEnv(s, dockerCtx)
+	assert.Check(t, is.DeepEqual(lookupEnv(env, "DOCKER_HOST"), []string{"tcp://remote:2375"}))
+	assert.Check(t, is.Len(lookupEnv(env, "DOCKER_CERT_PATH"), 0))
+	assert.Check(t, is.Len(lookupEnv(env, "DOCKER_TLS_VERIFY"), 0))
+}
+
+func TestDockerEndpointEnvWithTLS(t *testing.T) {
+	s := newTestStore(t)
+	tlsDir := s.TLSDir("secure", store.DockerEndpoint)
+	assert.NilError(t, os.MkdirAll(tlsDir, 0700))
+	for _, f := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		assert.NilError(t, ioutil.WriteFile(filepath.Join(tlsDir, f), []byte("pem"), 0600))
+	}
+
+	dockerCtx := &store.DockerContext{
+		Name: "secure",
+		Endpoints: map[string]interface{}{
+			store.DockerEndpoint: &store.Endpoint{Host: "tcp://secure:2376"},
+		},
+	}
+	env := dockerEndpointEnv(s, dockerCtx)
+	assert.Check(t, is.DeepEqual(lookupEnv(env, "DOCKER_HOST"), []string{"tcp://secure:2376"}))
+	assert.Check(t, is.DeepEqual(lookupEnv(env, "DOCKER_CERT_PATH"), []string{tlsDir}))
+	assert.Check(t, is.DeepEqual(lookupEnv(env, "DOCKER_TLS_VERIFY"), []string{"1"}))
+
+	dockerCtx.Endpoints[store.DockerEndpoint] = &store.Endpoint{Host: "tcp://secure:2376", SkipTLSVerify: true}
+	env = dockerEndpointEnv(s, dockerCtx)
+	assert.Check(t, is.DeepEqual(lookupEnv(env, "DOCKER_CERT_PATH"), []string{tlsDir}))
+	assert.Check(t, is.Len(lookupEnv(env, "DOCKER_TLS_VERIFY"), 0))
+}
+
+func TestDockerEndpointEnvInheritedForDefaultContext(t *testing.T) {
+	s := newTestStore(t)
+	dockerCtx := &store.DockerContext{
+		Name: store.DefaultContextName,
+		Endpoints: map[string]interface{}{
+			store.DockerEndpoint: &store.Endpoint{Host: "unix:///var/run/docker.sock"},
+		},
+	}
+	assert.Check(t, is.Nil(dockerEndpointEnv(s, dockerCtx)))
+}
+
+func lookupEnv(env []string, key string) []string {
+	values := []string{}
+	for _, v := range env {
+		if strings.HasPrefix(v, key+"=") {
+			values = append(values, strings.TrimPrefix(v, key+"="))
+		}
+	}
+	return values
+}
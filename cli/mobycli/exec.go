@@ -26,6 +26,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/docker/compose-cli/backend"
 	"github.com/docker/compose-cli/cli/mobycli/resolvepath"
 	apicontext "github.com/docker/compose-cli/context"
 	"github.com/docker/compose-cli/context/store"
@@ -45,8 +46,10 @@ func ExecIfDefaultCtxType(ctx context.Context, root *cobra.Command) {
 
 	currentCtx, err := s.Get(currentContext)
 	// Only run original docker command if the current context is not ours.
-	if err != nil || mustDelegateToMoby(currentCtx.Type()) {
+	if err != nil {
 		Exec(root)
+	} else if mustDelegateCommandToMoby(ctx, currentCtx.Type(), root, os.Args[1:]) {
+		delegate(root, dockerEndpointEnv(s, currentCtx))
 	}
 }
 
@@ -59,14 +62,42 @@ func mustDelegateToMoby(ctxType string) bool {
 	return false
 }
 
+// mustDelegateCommandToMoby checks the command args resolve to must be ran by the classic cli. On delegated context
+// types, only native commands are handled by this cli, as long as a backend is registered for the context type
+// to run them. Other commands are left to the classic cli
+func mustDelegateCommandToMoby(ctx context.Context, ctxType string, root *cobra.Command, args []string) bool {
+	if !mustDelegateToMoby(ctxType) {
+		return false
+	}
+	cmd, _, err := root.Find(args)
+	if err != nil || !isNativeCommand(cmd) {
+		return true
+	}
+	_, err = getBackend(ctx, ctxType)
+	return err != nil
+}
+
+// getBackend looks up the backend registered for a context type
+var getBackend = backend.Get
+
+// delegate runs the classic cli with env as the child environment, and exits with its status
+var delegate = execWithEnv
+
 // Exec delegates to com.docker.cli if on moby context
 func Exec(root *cobra.Command) {
+	delegate(root, nil)
+}
+
+// execWithEnv delegates to com.docker.cli, with env as the child environment.
+// A nil env makes the child inherit the current process environment.
+func execWithEnv(root *cobra.Command, env []string) {
 	execBinary, err := resolvepath.LookPath(ComDockerCli)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 	cmd := exec.Command(execBinary, os.Args[1:]...)
+	cmd.Env = env
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
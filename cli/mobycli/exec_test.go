@@ -17,11 +17,17 @@
 package mobycli
 
 import (
+	"context"
+	"os"
 	"testing"
 
+	"github.com/spf13/cobra"
 	"gotest.tools/v3/assert"
 
+	"github.com/docker/compose-cli/backend"
+	apicontext "github.com/docker/compose-cli/context"
 	"github.com/docker/compose-cli/context/store"
+	"github.com/docker/compose-cli/errdefs"
 )
 
 func TestDelegateContextTypeToMoby(t *testing.T) {
@@ -44,3 +50,48 @@ func TestDelegateContextTypeToMoby(t *testing.T) {
 		assert.Assert(t, !mustDelegateToMoby(ctx))
 	}
 }
+
+func TestDelegateUnknownCommandsToMoby(t *testing.T) {
+	s := newTestStore(t)
+	assert.NilError(t, s.Create("local-engine", store.DefaultContextType, "", &store.Endpoint{Host: "unix:///var/run/docker.sock"}))
+	ctx := store.WithContextStore(context.Background(), s)
+	ctx = apicontext.WithCurrentContext(ctx, "local-engine")
+
+	root := &cobra.Command{Use: "docker"}
+	compose := &cobra.Command{Use: "compose"}
+	compose.AddCommand(&cobra.Command{Use: "up", Run: func(cmd *cobra.Command, args []string) {}})
+	SetNativeCommand(compose)
+	root.AddCommand(compose, &cobra.Command{Use: "ps", Run: func(cmd *cobra.Command, args []string) {}})
+
+	var delegated bool
+	defer func(d func(*cobra.Command, []string)) { delegate = d }(delegate)
+	delegate = func(*cobra.Command, []string) { delegated = true }
+	defer func(args []string) { os.Args = args }(os.Args)
+	isDelegated := func(args ...string) bool {
+		delegated = false
+		os.Args = append([]string{"docker"}, args...)
+		ExecIfDefaultCtxType(ctx, root)
+		return delegated
+	}
+
+	defer func(get func(context.Context, string) (backend.Service, error)) { getBackend = get }(getBackend)
+	getBackend = func(context.Context, string) (backend.Service, error) {
+		return nil, errdefs.ErrNotFound
+	}
+	// no backend can run compose commands for the context type, so they are left to the classic cli
+	assert.Assert(t, isDelegated("compose", "up", "-d"))
+
+	getBackend = func(context.Context, string) (backend.Service, error) {
+		return nil, nil
+	}
+	// compose commands are handled locally, while other docker commands are left to the classic cli
+	assert.Assert(t, !isDelegated("compose", "up", "-d"))
+	assert.Assert(t, isDelegated("image", "ls"))
+	assert.Assert(t, isDelegated("ps"))
+
+	// commands are never delegated for context types handled by this cli
+	assert.NilError(t, s.Create("aci", store.AciContextType, "", nil))
+	ctx = apicontext.WithCurrentContext(ctx, "aci")
+	assert.Assert(t, !isDelegated("image", "ls"))
+	assert.Assert(t, !isDelegated("ps"))
+}
@@ -0,0 +1,93 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mobycli
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// ContextTypesAnnotation annotates commands and flags which are only available for some context types
+const ContextTypesAnnotation = "context-types"
+
+// NativeAnnotation annotates commands which are handled by this cli along with their subcommands, even for context
+// types delegated to the classic cli
+const NativeAnnotation = "native"
+
+// SetNativeCommand makes cmd and its subcommands handled by this cli, rather than delegated to the classic cli
+func SetNativeCommand(cmd *cobra.Command) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[NativeAnnotation] = "true"
+}
+
+func isNativeCommand(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if _, ok := c.Annotations[NativeAnnotation]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SetCommandContextTypes restricts the context types a command is available for
+func SetCommandContextTypes(cmd *cobra.Command, contextTypes ...string) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[ContextTypesAnnotation] = strings.Join(contextTypes, ",")
+}
+
+// SetFlagContextTypes restricts the context types a flag is available for
+func SetFlagContextTypes(flags *pflag.FlagSet, name string, contextTypes ...string) {
+	_ = flags.SetAnnotation(name, ContextTypesAnnotation, contextTypes)
+}
+
+// HideUnavailable hides from help output the commands and flags which are not available for the context type.
+// Commands ran with a delegated context type are handled by the classic cli, so nothing is hidden
+func HideUnavailable(root *cobra.Command, contextType string) {
+	if mustDelegateToMoby(contextType) {
+		return
+	}
+	hideUnavailable(root, contextType)
+}
+
+func hideUnavailable(cmd *cobra.Command, contextType string) {
+	if types, ok := cmd.Annotations[ContextTypesAnnotation]; ok && !contains(strings.Split(types, ","), contextType) {
+		cmd.Hidden = true
+	}
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if types, ok := flag.Annotations[ContextTypesAnnotation]; ok && !contains(types, contextType) {
+			flag.Hidden = true
+		}
+	})
+	for _, c := range cmd.Commands() {
+		hideUnavailable(c, contextType)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mobycli
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"gotest.tools/v3/assert"
+
+	"github.com/docker/compose-cli/context/store"
+)
+
+func newTestRoot() (*cobra.Command, *cobra.Command) {
+	root := &cobra.Command{Use: "docker"}
+	localOnly := &cobra.Command{Use: "localonly", Run: func(*cobra.Command, []string) {}}
+	SetCommandContextTypes(localOnly, store.LocalContextType)
+	up := &cobra.Command{Use: "up", Run: func(*cobra.Command, []string) {}}
+	up.Flags().Bool("rollback", false, "")
+	SetFlagContextTypes(up.Flags(), "rollback", store.LocalContextType)
+	up.Flags().Bool("detach", false, "")
+	root.AddCommand(localOnly, up)
+	return root, up
+}
+
+func TestHideUnavailableUnderAciContext(t *testing.T) {
+	root, up := newTestRoot()
+	HideUnavailable(root, store.AciContextType)
+
+	localOnly, _, err := root.Find([]string{"localonly"})
+	assert.NilError(t, err)
+	assert.Assert(t, localOnly.Hidden)
+	assert.Assert(t, !up.Hidden)
+	assert.Assert(t, up.Flags().Lookup("rollback").Hidden)
+	assert.Assert(t, !up.Flags().Lookup("detach").Hidden)
+}
+
+func TestShowAvailable(t *testing.T) {
+	for _, contextType := range []string{store.DefaultContextType, store.LocalContextType} {
+		root, up := newTestRoot()
+		HideUnavailable(root, contextType)
+
+		localOnly, _, err := root.Find([]string{"localonly"})
+		assert.NilError(t, err)
+		assert.Assert(t, !localOnly.Hidden)
+		assert.Assert(t, !up.Flags().Lookup("rollback").Hidden)
+	}
+}
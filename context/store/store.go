@@ -60,11 +60,14 @@ const (
 	ExampleContextType = "example"
 )
 
+// DockerEndpoint is the key of the docker endpoint in the context endpoints
+const DockerEndpoint = "docker"
+
 const (
-	dockerEndpointKey = "docker"
-	contextsDir       = "contexts"
-	metadataDir       = "meta"
-	metaFile          = "meta.json"
+	contextsDir = "contexts"
+	metadataDir = "meta"
+	tlsDir      = "tls"
+	metaFile    = "meta.json"
 )
 
 type contextStoreKey struct{}
@@ -97,6 +100,9 @@ type Store interface {
 	Remove(name string) error
 	// ContextExists checks if a context already exists
 	ContextExists(name string) bool
+	// TLSDir returns the directory holding the TLS material (ca.pem, cert.pem
+	// and key.pem) of an endpoint of a context
+	TLSDir(name string, endpoint string) string
 }
 
 // Endpoint holds the Docker or the Kubernetes endpoint, they both have the
@@ -104,6 +110,7 @@ type Store interface {
 type Endpoint struct {
 	Host             string `json:",omitempty"`
 	DefaultNamespace string `json:",omitempty"`
+	SkipTLSVerify    bool   `json:",omitempty"`
 }
 
 type store struct {
@@ -242,8 +249,8 @@ func (s *store) Create(name string, contextType string, description string, data
 			Description: description,
 		},
 		Endpoints: map[string]interface{}{
-			(dockerEndpointKey): data,
-			(contextType):       data,
+			(DockerEndpoint): data,
+			(contextType):    data,
 		},
 	}
 
@@ -300,6 +307,10 @@ func (s *store) Remove(name string) error {
 	return nil
 }
 
+func (s *store) TLSDir(name string, endpoint string) string {
+	return filepath.Join(s.root, contextsDir, tlsDir, contextDirOf(name), endpoint)
+}
+
 func contextDirOf(name string) string {
 	return digest.FromString(name).Encoded()
 }
@@ -19,10 +19,11 @@ package ecs
 import (
 	"context"
 
+	"github.com/docker/compose-cli/api/compose"
 	"github.com/docker/compose-cli/progress"
 )
 
-func (b *ecsAPIService) Down(ctx context.Context, project string) error {
+func (b *ecsAPIService) Down(ctx context.Context, project string, options compose.DownOptions) error {
 	resources, err := b.aws.ListStackResources(ctx, project)
 	if err != nil {
 		return err
@@ -26,6 +26,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	types2 "github.com/docker/docker/api/types"
@@ -41,7 +42,7 @@ import (
 	"golang.org/x/mod/semver"
 )
 
-func (e ecsLocalSimulation) Up(ctx context.Context, project *types.Project, detach bool) error {
+func (e ecsLocalSimulation) Up(ctx context.Context, project *types.Project, options compose.UpOptions) error {
 	cmd := exec.Command("docker-compose", "version", "--short")
 	b := bytes.Buffer{}
 	b.WriteString("v")
@@ -149,8 +150,12 @@ func (e ecsLocalSimulation) Convert(ctx context.Context, project *types.Project,
 
 }
 
-func (e ecsLocalSimulation) Down(ctx context.Context, projectName string) error {
-	cmd := exec.Command("docker-compose", "--context", "default", "--project-name", projectName, "-f", "-", "down", "--remove-orphans")
+func (e ecsLocalSimulation) Down(ctx context.Context, projectName string, options compose.DownOptions) error {
+	args := []string{"--context", "default", "--project-name", projectName, "-f", "-", "down", "--remove-orphans"}
+	if options.Timeout != nil {
+		args = append(args, "--timeout", strconv.Itoa(int(options.Timeout.Seconds())))
+	}
+	cmd := exec.Command("docker-compose", args...)
 	cmd.Stdin = strings.NewReader(string(`
 services:
    ecs-local-endpoints:
@@ -161,7 +166,7 @@ services:
 	return cmd.Run()
 }
 
-func (e ecsLocalSimulation) Logs(ctx context.Context, projectName string, w io.Writer) error {
+func (e ecsLocalSimulation) Logs(ctx context.Context, projectName string, w io.Writer, options compose.LogOptions) error {
 	list, err := e.moby.ContainerList(ctx, types2.ContainerListOptions{
 		Filters: filters.NewArgs(filters.Arg("label", "com.docker.compose.project="+projectName)),
 	})
@@ -181,7 +186,11 @@ func (e ecsLocalSimulation) Logs(ctx context.Context, projectName string, w io.W
 	if err != nil {
 		return err
 	}
-	cmd := exec.Command("docker-compose", "--context", "default", "--project-name", projectName, "-f", "-", "logs", "-f")
+	args := []string{"--context", "default", "--project-name", projectName, "-f", "-", "logs", "-f"}
+	if options.Timestamps {
+		args = append(args, "--timestamps")
+	}
+	cmd := exec.Command("docker-compose", args...)
 	cmd.Stdin = strings.NewReader(string(marshal))
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
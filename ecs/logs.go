@@ -20,10 +20,11 @@ import (
 	"context"
 	"io"
 
+	"github.com/docker/compose-cli/api/compose"
 	"github.com/docker/compose-cli/formatter"
 )
 
-func (b *ecsAPIService) Logs(ctx context.Context, project string, w io.Writer) error {
+func (b *ecsAPIService) Logs(ctx context.Context, project string, w io.Writer, options compose.LogOptions) error {
 	consumer := formatter.NewLogConsumer(w)
 	err := b.aws.GetLogs(ctx, project, consumer.Log)
 	return err
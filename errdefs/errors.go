@@ -24,6 +24,8 @@ const (
 	//ExitCodeLoginRequired exit code when command cannot execute because it requires cloud login
 	// This will be used by VSCode to detect when creating context if the user needs to login first
 	ExitCodeLoginRequired = 5
+	// ExitCodeDaemonError exit code when command failed to reach or operate the container engine, as set by `docker run`
+	ExitCodeDaemonError = 125
 )
 
 var (
@@ -51,6 +53,48 @@ var (
 	ErrWrongContextType = errors.New("wrong context type")
 )
 
+// ConfigError is returned when an operation failed because of user's configuration, like a reference to a
+// missing image. It wraps the original error
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the original error
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// DaemonError is returned when an operation failed because the container engine can't be reached or failed
+// to process a valid request. It wraps the original error
+type DaemonError struct {
+	Err error
+}
+
+func (e *DaemonError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the original error
+func (e *DaemonError) Unwrap() error {
+	return e.Err
+}
+
+// IsConfigError returns true if the error, or one it wraps, is a ConfigError
+func IsConfigError(err error) bool {
+	var e *ConfigError
+	return errors.As(err, &e)
+}
+
+// IsDaemonError returns true if the error, or one it wraps, is a DaemonError
+func IsDaemonError(err error) bool {
+	var e *DaemonError
+	return errors.As(err, &e)
+}
+
 // IsNotFoundError returns true if the unwrapped error is ErrNotFound
 func IsNotFoundError(err error) bool {
 	return errors.Is(err, ErrNotFound)
@@ -50,3 +50,21 @@ func TestIsUnknown(t *testing.T) {
 
 	assert.Assert(t, !IsUnknownError(errors.New("another error")))
 }
+
+func TestIsConfigError(t *testing.T) {
+	cause := errors.New("no such image")
+	err := errors.Wrap(&ConfigError{Err: cause}, `service "web"`)
+	assert.Assert(t, IsConfigError(err))
+	assert.Assert(t, !IsDaemonError(err))
+	assert.Assert(t, errors.Is(err, cause))
+
+	assert.Assert(t, !IsConfigError(errors.New("another error")))
+}
+
+func TestIsDaemonError(t *testing.T) {
+	err := errors.Wrap(&DaemonError{Err: errors.New("connection refused")}, `service "web"`)
+	assert.Assert(t, IsDaemonError(err))
+	assert.Assert(t, !IsConfigError(err))
+
+	assert.Assert(t, !IsDaemonError(errors.New("another error")))
+}
@@ -22,19 +22,23 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // NewLogConsumer creates a new LogConsumer
-func NewLogConsumer(w io.Writer) LogConsumer {
-	return LogConsumer{
+func NewLogConsumer(w io.Writer) *LogConsumer {
+	return &LogConsumer{
 		colors: map[string]colorFunc{},
 		width:  0,
 		writer: w,
 	}
 }
 
-// Log formats a log message as received from service/container
+// Log formats a log message as received from service/container. It is safe to log concurrently from multiple
+// containers, lines of a message are never interleaved with others
 func (l *LogConsumer) Log(service, container, message string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
 	cf, ok := l.colors[service]
 	if !ok {
 		cf = <-loop
@@ -70,6 +74,7 @@ func (l *LogConsumer) computeWidth() {
 
 // LogConsumer consume logs from services and format them
 type LogConsumer struct {
+	mtx    sync.Mutex
 	colors map[string]colorFunc
 	width  int
 	writer io.Writer
@@ -30,6 +30,7 @@ import (
 	"github.com/docker/compose-cli/api/volumes"
 	"github.com/docker/compose-cli/backend"
 	"github.com/docker/compose-cli/context/cloud"
+	"github.com/docker/compose-cli/context/store"
 )
 
 type local struct {
@@ -39,6 +40,8 @@ type local struct {
 
 func init() {
 	backend.Register("local", "local", service, cloud.NotImplementedCloudService)
+	// commands handled by this cli on moby contexts, such as compose, run against the local engine
+	backend.Register("moby", store.DefaultContextType, service, cloud.NotImplementedCloudService)
 }
 
 func service(ctx context.Context) (backend.Service, error) {
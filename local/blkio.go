@@ -0,0 +1,151 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/compose-spec/compose-go/types"
+	"github.com/docker/docker/api/types/blkiodev"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-units"
+	"github.com/pkg/errors"
+)
+
+// extBlkioConfig is set as an extension as the compose model doesn't retain `blkio_config`, it uses the same syntax:
+//
+//	x-blkio_config:
+//	  weight: 300
+//	  device_read_bps:
+//	    - path: /dev/sda
+//	      rate: 20mb
+const extBlkioConfig = "x-blkio_config"
+
+type blkioConfig struct {
+	Weight          uint16        `json:"weight,omitempty"`
+	WeightDevice    []blkioWeight `json:"weight_device,omitempty"`
+	DeviceReadBps   []blkioLimit  `json:"device_read_bps,omitempty"`
+	DeviceReadIOps  []blkioLimit  `json:"device_read_iops,omitempty"`
+	DeviceWriteBps  []blkioLimit  `json:"device_write_bps,omitempty"`
+	DeviceWriteIOps []blkioLimit  `json:"device_write_iops,omitempty"`
+}
+
+type blkioWeight struct {
+	Path   string `json:"path"`
+	Weight uint16 `json:"weight"`
+}
+
+// blkioLimit rate is a number, or a byte size such as `20mb` for bps limits
+type blkioLimit struct {
+	Path string      `json:"path"`
+	Rate interface{} `json:"rate"`
+}
+
+// applyBlkioConfig sets block IO weight and throttling declared by service on container resources
+func applyBlkioConfig(service types.ServiceConfig, resources *container.Resources) error {
+	config, ok := service.Extensions[extBlkioConfig]
+	if !ok {
+		return nil
+	}
+	var blkio blkioConfig
+	marshalled, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(marshalled, &blkio)
+	if err != nil {
+		return errors.Wrapf(err, "invalid %s for service %q", extBlkioConfig, service.Name)
+	}
+
+	if err := checkBlkioWeight(blkio.Weight); err != nil {
+		return errors.Wrapf(err, "invalid %s for service %q", extBlkioConfig, service.Name)
+	}
+	resources.BlkioWeight = blkio.Weight
+	for _, w := range blkio.WeightDevice {
+		if err := checkBlkioDevice(w.Path); err != nil {
+			return errors.Wrapf(err, "invalid %s weight_device for service %q", extBlkioConfig, service.Name)
+		}
+		if err := checkBlkioWeight(w.Weight); err != nil {
+			return errors.Wrapf(err, "invalid %s weight_device for service %q", extBlkioConfig, service.Name)
+		}
+		resources.BlkioWeightDevice = append(resources.BlkioWeightDevice, &blkiodev.WeightDevice{Path: w.Path, Weight: w.Weight})
+	}
+
+	for _, limits := range []struct {
+		name   string
+		limits []blkioLimit
+		bytes  bool
+		target *[]*blkiodev.ThrottleDevice
+	}{
+		{"device_read_bps", blkio.DeviceReadBps, true, &resources.BlkioDeviceReadBps},
+		{"device_read_iops", blkio.DeviceReadIOps, false, &resources.BlkioDeviceReadIOps},
+		{"device_write_bps", blkio.DeviceWriteBps, true, &resources.BlkioDeviceWriteBps},
+		{"device_write_iops", blkio.DeviceWriteIOps, false, &resources.BlkioDeviceWriteIOps},
+	} {
+		for _, l := range limits.limits {
+			if err := checkBlkioDevice(l.Path); err != nil {
+				return errors.Wrapf(err, "invalid %s %s for service %q", extBlkioConfig, limits.name, service.Name)
+			}
+			rate, err := toBlkioRate(l.Rate, limits.bytes)
+			if err != nil {
+				return errors.Wrapf(err, "invalid %s %s for service %q", extBlkioConfig, limits.name, service.Name)
+			}
+			*limits.target = append(*limits.target, &blkiodev.ThrottleDevice{Path: l.Path, Rate: rate})
+		}
+	}
+	return nil
+}
+
+// checkBlkioWeight checks weight is in the range accepted by engine, 0 meaning unset
+func checkBlkioWeight(weight uint16) error {
+	if weight != 0 && (weight < 10 || weight > 1000) {
+		return fmt.Errorf("weight %d must be between 10 and 1000", weight)
+	}
+	return nil
+}
+
+func checkBlkioDevice(device string) error {
+	if !path.IsAbs(device) {
+		return fmt.Errorf("device path %q must be absolute", device)
+	}
+	return nil
+}
+
+// toBlkioRate converts a rate, accepting byte sizes for bps limits
+func toBlkioRate(rate interface{}, bytes bool) (uint64, error) {
+	switch r := rate.(type) {
+	case float64:
+		if r < 0 || r != float64(uint64(r)) {
+			return 0, fmt.Errorf("rate %v must be a positive integer", r)
+		}
+		return uint64(r), nil
+	case string:
+		if bytes {
+			size, err := units.RAMInBytes(r)
+			if err == nil && size >= 0 {
+				return uint64(size), nil
+			}
+		}
+		return 0, fmt.Errorf("invalid rate %q", r)
+	default:
+		return 0, fmt.Errorf("invalid rate %v", rate)
+	}
+}
@@ -0,0 +1,62 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"testing"
+
+	"github.com/compose-spec/compose-go/types"
+	"github.com/docker/docker/api/types/blkiodev"
+	"gotest.tools/v3/assert"
+)
+
+func TestBlkioConfig(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:  "db",
+		Image: "postgres",
+		Extensions: map[string]interface{}{extBlkioConfig: map[string]interface{}{
+			"weight": 300,
+			"device_read_bps": []interface{}{
+				map[string]interface{}{"path": "/dev/sda", "rate": "20mb"},
+			},
+			"device_write_iops": []interface{}{
+				map[string]interface{}{"path": "/dev/sda", "rate": 120},
+			},
+		}},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&types.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, hostConfig.BlkioWeight, uint16(300))
+	assert.DeepEqual(t, hostConfig.BlkioDeviceReadBps, []*blkiodev.ThrottleDevice{{Path: "/dev/sda", Rate: 20 * 1024 * 1024}})
+	assert.DeepEqual(t, hostConfig.BlkioDeviceWriteIOps, []*blkiodev.ThrottleDevice{{Path: "/dev/sda", Rate: 120}})
+
+	for expected, config := range map[string]map[string]interface{}{
+		`invalid x-blkio_config for service "db": weight 5 must be between 10 and 1000`: {"weight": 5},
+		`invalid x-blkio_config device_read_bps for service "db": device path "sda" must be absolute`: {
+			"device_read_bps": []interface{}{map[string]interface{}{"path": "sda", "rate": 1}},
+		},
+		`invalid x-blkio_config device_read_iops for service "db": invalid rate "20mb"`: {
+			"device_read_iops": []interface{}{map[string]interface{}{"path": "/dev/sda", "rate": "20mb"}},
+		},
+	} {
+		service.Extensions[extBlkioConfig] = config
+		_, _, _, err := getContainerCreateOptions(&types.Project{Name: "myproject"}, service, 1, nil)
+		assert.Error(t, err, expected)
+	}
+}
@@ -0,0 +1,185 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/compose-spec/compose-go/types"
+	"github.com/docker/cli/cli/command/image/build"
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/idtools"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/pkg/errors"
+
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/progress"
+)
+
+const (
+	// extPullPolicy is set as an extension as the compose model doesn't retain `pull_policy`
+	extPullPolicy = "x-pull_policy"
+
+	pullPolicyBuild   = "build"
+	pullPolicyAlways  = compose.PullAlways
+	pullPolicyMissing = compose.PullMissing
+	pullPolicyNever   = compose.PullNever
+)
+
+// getPullPolicy returns the pull_policy of service, unless overridden
+func getPullPolicy(service types.ServiceConfig, override string) string {
+	if override != "" {
+		return override
+	}
+	if policy, ok := service.Extensions[extPullPolicy].(string); ok {
+		return policy
+	}
+	return pullPolicyMissing
+}
+
+// getImageName returns the image used by service, defaulting to the one built for it
+func getImageName(project *types.Project, service types.ServiceConfig) string {
+	if service.Image != "" {
+		return service.Image
+	}
+	return fmt.Sprintf("%s_%s", project.Name, service.Name)
+}
+
+// ensureImageBuilt builds service image if missing, or unconditionally when forced to
+func (s *local) ensureImageBuilt(ctx context.Context, project *types.Project, service types.ServiceConfig, force bool) error {
+	image := getImageName(project, service)
+	if !force {
+		_, _, err := s.containerService.apiClient.ImageInspectWithRaw(ctx, image)
+		if err == nil {
+			return nil
+		}
+		if !errdefs.IsNotFound(err) {
+			return err
+		}
+	}
+	return s.buildImage(ctx, project, service, image)
+}
+
+func (s *local) buildImage(ctx context.Context, project *types.Project, service types.ServiceConfig, image string) error {
+	w := progress.ContextWriter(ctx)
+	eventName := fmt.Sprintf("Service %q", service.Name)
+	w.Event(progress.Event{
+		ID:         eventName,
+		Status:     progress.Working,
+		StatusText: "Build",
+	})
+
+	buildContext, dockerfile, err := getBuildContext(project, *service.Build)
+	if err != nil {
+		return err
+	}
+	defer buildContext.Close() //nolint:errcheck
+
+	response, err := s.containerService.apiClient.ImageBuild(ctx, buildContext, moby.ImageBuildOptions{
+		Tags:        []string{image},
+		Dockerfile:  dockerfile,
+		BuildArgs:   service.Build.Args,
+		Labels:      service.Build.Labels,
+		CacheFrom:   service.Build.CacheFrom,
+		ExtraHosts:  service.Build.ExtraHosts,
+		NetworkMode: service.Build.Network,
+		Target:      service.Build.Target,
+		Platform:    service.Platform,
+		Remove:      true,
+	})
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close() //nolint:errcheck
+
+	dec := json.NewDecoder(response.Body)
+	for {
+		var jm jsonmessage.JSONMessage
+		if err := dec.Decode(&jm); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if jm.Error != nil {
+			w.Event(progress.Event{
+				ID:         eventName,
+				Status:     progress.Error,
+				StatusText: "Error",
+				Done:       true,
+			})
+			return errors.Wrapf(errors.New(jm.Error.Message), "failed to build service %q", service.Name)
+		}
+		if text := strings.TrimSpace(jm.Stream); text != "" {
+			w.Event(progress.Event{
+				ID:         eventName,
+				Text:       text,
+				Status:     progress.Working,
+				StatusText: "Build",
+			})
+		}
+	}
+
+	w.Event(progress.Event{
+		ID:         eventName,
+		Status:     progress.Done,
+		StatusText: "Built",
+		Done:       true,
+	})
+	return nil
+}
+
+// getBuildContext archives the build context directory, honoring .dockerignore, and returns the Dockerfile
+// path relative to it
+func getBuildContext(project *types.Project, config types.BuildConfig) (io.ReadCloser, string, error) {
+	contextDir := config.Context
+	if !filepath.IsAbs(contextDir) {
+		contextDir = filepath.Join(project.WorkingDir, contextDir)
+	}
+	dockerfile := config.Dockerfile
+	if dockerfile != "" && !filepath.IsAbs(dockerfile) {
+		// compose resolves dockerfile relative to build context
+		dockerfile = filepath.Join(contextDir, dockerfile)
+	}
+	contextDir, dockerfile, err := build.GetContextFromLocalDir(contextDir, dockerfile)
+	if err != nil {
+		return nil, "", err
+	}
+
+	excludes, err := build.ReadDockerignore(contextDir)
+	if err != nil {
+		return nil, "", err
+	}
+	excludes = build.TrimBuildFilesFromExcludes(excludes, dockerfile, false)
+	buildContext, err := archive.TarWithOptions(contextDir, &archive.TarOptions{
+		ExcludePatterns: excludes,
+		ChownOpts:       &idtools.Identity{UID: 0, GID: 0},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return buildContext, filepath.ToSlash(dockerfile), nil
+}
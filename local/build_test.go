@@ -0,0 +1,221 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/types"
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/progress"
+)
+
+func TestUpBuildsImageBeforeCreate(t *testing.T) {
+	dir := fs.NewDir(t, "build", fs.WithFile("Dockerfile.dev", "FROM nginx\n"))
+	defer dir.Remove()
+
+	var calls []string
+	record := func(args mock.Arguments) {
+		calls = append(calls, "build")
+	}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, "myproject_web").
+		Return(moby.ImageInspect{}, errdefs.NotFound(errors.New("no such image")))
+	apiClient.On("ImageBuild", mock.Anything, mock.Anything, mock.MatchedBy(func(options moby.ImageBuildOptions) bool {
+		return options.Dockerfile == "Dockerfile.dev" && options.Target == "dev" &&
+			*options.BuildArgs["VERSION"] == "1" && options.Tags[0] == "myproject_web"
+	})).Run(record).Return(moby.ImageBuildResponse{
+		Body: ioutil.NopCloser(strings.NewReader(`{"stream":"Step 1/1 : FROM nginx\n"}`)),
+	}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{}, nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.MatchedBy(func(config *container.Config) bool {
+		return config.Image == "myproject_web"
+	}), mock.Anything, mock.Anything, "myproject_web_1").Run(func(args mock.Arguments) {
+		calls = append(calls, "create")
+	}).Return(container.ContainerCreateCreatedBody{ID: "c1"}, nil)
+	apiClient.On("ContainerStart", mock.Anything, "c1", moby.ContainerStartOptions{}).Return(nil)
+	apiClient.On("ServerVersion", mock.Anything).Return(moby.Version{APIVersion: "1.41"}, nil)
+	s := newMockBackend(apiClient)
+
+	version := "1"
+	project := &types.Project{
+		Name:       "myproject",
+		WorkingDir: dir.Path(),
+		Services: []types.ServiceConfig{
+			{
+				Name: "web",
+				Build: &types.BuildConfig{
+					Context:    ".",
+					Dockerfile: "Dockerfile.dev",
+					Target:     "dev",
+					Args:       types.MappingWithEquals{"VERSION": &version},
+				},
+			},
+		},
+	}
+	w := &recordingWriter{}
+	err := s.Up(progress.WithContextWriter(context.TODO(), w), project, compose.UpOptions{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, calls, []string{"build", "create"})
+	assert.DeepEqual(t, w.statuses(`Service "web"`)[:3], []string{"Build", "Build", "Built"})
+	apiClient.AssertExpectations(t)
+}
+
+func TestBuildOnlyServiceIgnoresPullPolicy(t *testing.T) {
+	dir := fs.NewDir(t, "build", fs.WithFile("Dockerfile", "FROM nginx\n"))
+	defer dir.Remove()
+
+	project := &types.Project{Name: "myproject", WorkingDir: dir.Path(), Services: []types.ServiceConfig{{
+		Name:  "web",
+		Build: &types.BuildConfig{Context: "."},
+	}}}
+	for _, pull := range []string{compose.PullAlways, compose.PullNever} {
+		apiClient := &mockAPIClient{}
+		apiClient.On("ImageInspectWithRaw", mock.Anything, "myproject_web").
+			Return(moby.ImageInspect{}, errdefs.NotFound(errors.New("no such image"))).Once()
+		apiClient.On("ImageBuild", mock.Anything, mock.Anything, mock.Anything).Return(moby.ImageBuildResponse{
+			Body: ioutil.NopCloser(strings.NewReader(`{"stream":"Step 1/1 : FROM nginx\n"}`)),
+		}, nil).Once()
+		s := newMockBackend(apiClient)
+
+		err := s.applyPullPolicy(context.TODO(), project, project.Services[0], compose.UpOptions{Pull: pull})
+		assert.NilError(t, err)
+		apiClient.AssertExpectations(t)
+	}
+}
+
+func TestBuildWhenPullFails(t *testing.T) {
+	dir := fs.NewDir(t, "build", fs.WithFile("Dockerfile", "FROM nginx\n"))
+	defer dir.Remove()
+
+	project := &types.Project{Name: "myproject", WorkingDir: dir.Path(), Services: []types.ServiceConfig{{
+		Name:  "web",
+		Image: "registry.example.com/web",
+		Build: &types.BuildConfig{Context: "."},
+	}}}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImagePull", mock.Anything, "registry.example.com/web", moby.ImagePullOptions{}).
+		Return(nil, errdefs.NotFound(errors.New("manifest unknown"))).Once()
+	apiClient.On("ImageInspectWithRaw", mock.Anything, "registry.example.com/web").
+		Return(moby.ImageInspect{}, errdefs.NotFound(errors.New("no such image"))).Once()
+	apiClient.On("ImageBuild", mock.Anything, mock.Anything, mock.MatchedBy(func(options moby.ImageBuildOptions) bool {
+		return options.Tags[0] == "registry.example.com/web"
+	})).Return(moby.ImageBuildResponse{
+		Body: ioutil.NopCloser(strings.NewReader(`{"stream":"Step 1/1 : FROM nginx\n"}`)),
+	}, nil).Once()
+	s := newMockBackend(apiClient)
+
+	err := s.applyPullPolicy(context.TODO(), project, project.Services[0], compose.UpOptions{Pull: compose.PullAlways})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+}
+
+func TestPullOverridesPullPolicy(t *testing.T) {
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{{
+		Name:       "web",
+		Image:      "nginx",
+		Extensions: map[string]interface{}{extPullPolicy: pullPolicyAlways},
+	}}}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImagePull", mock.Anything, "nginx", moby.ImagePullOptions{}).
+		Return(ioutil.NopCloser(strings.NewReader(`{"status":"Pulling from library/nginx"}`)), nil).Once()
+	s := newMockBackend(apiClient)
+
+	// image is pulled without checking it is available locally
+	err := s.applyPullPolicy(context.TODO(), project, project.Services[0], compose.UpOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+
+	err = s.applyPullPolicy(context.TODO(), project, project.Services[0], compose.UpOptions{Pull: compose.PullNever})
+	assert.NilError(t, err)
+	apiClient.AssertNumberOfCalls(t, "ImagePull", 1)
+	apiClient.AssertNotCalled(t, "ImageInspectWithRaw", mock.Anything, mock.Anything)
+	assert.Equal(t, getPullPolicy(project.Services[0], compose.PullMissing), pullPolicyMissing)
+}
+
+func TestQuietPull(t *testing.T) {
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{{
+		Name:  "web",
+		Image: "nginx",
+	}}}
+	layers := `{"status":"Pulling fs layer","progressDetail":{},"id":"a1"}
+{"status":"Downloading","progressDetail":{"current":1,"total":2},"id":"a1"}
+{"status":"Pull complete","progressDetail":{},"id":"a1"}
+`
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImagePull", mock.Anything, "nginx", moby.ImagePullOptions{}).
+		Return(ioutil.NopCloser(strings.NewReader(layers)), nil).Once()
+	apiClient.On("ImagePull", mock.Anything, "nginx", moby.ImagePullOptions{}).
+		Return(ioutil.NopCloser(strings.NewReader(layers)), nil).Once()
+	s := newMockBackend(apiClient)
+
+	w := &recordingWriter{}
+	err := s.applyPullPolicy(progress.WithContextWriter(context.TODO(), w), project, project.Services[0],
+		compose.UpOptions{Pull: compose.PullAlways})
+	assert.NilError(t, err)
+	assert.Equal(t, len(w.statuses("a1")), 3)
+	assert.Equal(t, len(w.statuses(`Service "web"`)), 0)
+
+	w = &recordingWriter{}
+	err = s.applyPullPolicy(progress.WithContextWriter(context.TODO(), w), project, project.Services[0],
+		compose.UpOptions{Pull: compose.PullAlways, QuietPull: true})
+	assert.NilError(t, err)
+	assert.Equal(t, len(w.events), 2)
+	assert.DeepEqual(t, w.statuses(`Service "web"`), []string{"Pulling", "Pulled"})
+	apiClient.AssertExpectations(t)
+}
+
+func TestPullPlatform(t *testing.T) {
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{{
+		Name:     "web",
+		Image:    "nginx",
+		Platform: "linux/arm64",
+	}}}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, "nginx").
+		Return(moby.ImageInspect{Os: "linux", Architecture: "amd64"}, nil)
+	apiClient.On("ImagePull", mock.Anything, "nginx", moby.ImagePullOptions{Platform: "linux/arm64"}).
+		Return(ioutil.NopCloser(strings.NewReader(`{"status":"Pulling from library/nginx"}`)), nil).Once()
+	s := newMockBackend(apiClient)
+
+	// local image is pulled again, as it was pulled for another platform
+	err := s.applyPullPolicy(context.TODO(), project, project.Services[0], compose.UpOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+
+	assert.Assert(t, matchesPlatform(moby.ImageInspect{Os: "linux", Architecture: "arm64"}, "linux/arm64"))
+	assert.Assert(t, matchesPlatform(moby.ImageInspect{Os: "linux", Architecture: "amd64"}, ""))
+
+	project.Services[0].Platform = "linux/arm64/v8/extra"
+	err = s.applyPullPolicy(context.TODO(), project, project.Services[0], compose.UpOptions{})
+	assert.Error(t, err, `invalid platform "linux/arm64/v8/extra" for service "web": expected os[/arch[/variant]]`)
+}
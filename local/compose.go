@@ -19,70 +19,333 @@
 package local
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
+	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/compose-spec/compose-go/types"
+	"github.com/containerd/containerd/platforms"
 	moby "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/strslice"
+	timetypes "github.com/docker/docker/api/types/time"
+	volumetypes "github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/errdefs"
 	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"github.com/sanathkr/go-yaml"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/docker/compose-cli/api/compose"
 	"github.com/docker/compose-cli/api/containers"
 	"github.com/docker/compose-cli/formatter"
+	"github.com/docker/compose-cli/internal"
 	"github.com/docker/compose-cli/progress"
 )
 
-func (s *local) Up(ctx context.Context, project *types.Project, detach bool) error {
-	for k, network := range project.Networks {
-		if !network.External.External && network.Name != "" {
-			network.Name = fmt.Sprintf("%s_%s", project.Name, k)
-			project.Networks[k] = network
+func (s *local) Up(ctx context.Context, project *types.Project, options compose.UpOptions) error {
+	if options.Timeout <= 0 {
+		return s.up(ctx, project, options)
+	}
+	ctx, cancel := context.WithTimeout(ctx, options.Timeout)
+	defer cancel()
+	tracker := &convergenceTracker{project: project, wait: options.Wait, states: map[string]string{}}
+	// selected services are all pending until they report a transition, so that those up never got to are reported
+	selected, err := getSelectedServices(project, options.Services, options.NoDeps)
+	if err != nil {
+		return err
+	}
+	for name := range selected {
+		tracker.states[name] = compose.ServicePending
+	}
+	listener := options.Listener
+	options.Listener = func(transition compose.ServiceTransition) {
+		tracker.record(transition)
+		if listener != nil {
+			listener(transition)
+		}
+	}
+	err = s.up(ctx, project, options)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return errors.Wrapf(context.DeadlineExceeded, "services did not converge within %s (%s)", options.Timeout, strings.Join(tracker.pending(), ", "))
+	}
+	return err
+}
+
+// convergenceTracker records services state transitions, to report the services which didn't converge when up times
+// out. Services are converged once started, or healthy if up waits for them to be
+type convergenceTracker struct {
+	mtx     sync.Mutex
+	project *types.Project
+	wait    bool
+	states  map[string]string
+}
+
+func (t *convergenceTracker) record(transition compose.ServiceTransition) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.states[transition.Service] = transition.State
+}
+
+func (t *convergenceTracker) pending() []string {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	var pending []string
+	for name, state := range t.states {
+		converged := state == compose.ServiceHealthy
+		if state == compose.ServiceStarted {
+			service, err := t.project.GetService(name)
+			converged = err != nil || !t.wait || service.HealthCheck == nil || service.HealthCheck.Disable
+		}
+		if !converged {
+			pending = append(pending, name)
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+func (s *local) up(ctx context.Context, project *types.Project, options compose.UpOptions) error {
+	for name, replicas := range options.Scale {
+		service, err := project.GetService(name)
+		if err != nil {
+			return fmt.Errorf("can't scale service %q: no such service", name)
+		}
+		if replicas < 0 || (isGlobal(service) && replicas != 1) {
+			return fmt.Errorf("can't scale service %q to %d replicas", name, replicas)
+		}
+	}
+	for _, service := range project.Services {
+		if err := checkDeployMode(service); err != nil {
+			return err
+		}
+	}
+	err := applyCommandOverrides(project, options)
+	if err != nil {
+		return err
+	}
+
+	if options.AttachOnly {
+		return s.ensureRunning(ctx, project, options)
+	}
+
+	err = s.checkAPIVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	selected, err := getSelectedServices(project, options.Services, options.NoDeps)
+	if err != nil {
+		return err
+	}
+
+	err = checkExtendsResolved(project)
+	if err != nil {
+		return err
+	}
+
+	err = auditEnvironment(ctx, project, selected, options.StrictEnvironment)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkPortConflicts(ctx, project, selected, options.Scale)
+	if err != nil {
+		return err
+	}
+
+	setResourceNames(project)
+	for _, network := range project.Networks {
+		err := s.ensureNetwork(ctx, project.Name, network)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, volume := range project.Volumes {
+		err := s.ensureVolume(ctx, project.Name, volume)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, service := range project.Services {
+		if !selected[service.Name] {
+			continue
 		}
-		err := s.ensureNetwork(ctx, network)
+		err := s.applyPullPolicy(ctx, project, service, options)
 		if err != nil {
 			return err
 		}
 	}
 
+	for _, service := range project.Services {
+		if selected[service.Name] {
+			notifyTransition(options, service.Name, compose.ServicePending, nil)
+		}
+	}
+
+	created := &createdContainers{}
+	failures := &serviceFailures{}
+	err = inDependencyOrder(withCreatedContainers(ctx, created), project, func(c context.Context, service types.ServiceConfig) error {
+		if !selected[service.Name] {
+			return nil
+		}
+		var err error
+		if options.ContinueOnError {
+			err = failures.run(c, service, func() error {
+				return s.ensureService(c, project, service, options)
+			})
+		} else {
+			err = s.ensureService(c, project, service, options)
+		}
+		if err != nil {
+			notifyTransition(options, service.Name, compose.ServiceFailed, err)
+		} else {
+			notifyTransition(options, service.Name, compose.ServiceStarted, nil)
+		}
+		if options.ContinueOnError {
+			return nil
+		}
+		return err
+	})
+	if err == nil {
+		err = failures.errorOrNil()
+	}
+	if err != nil && options.Rollback {
+		if rollbackErr := s.rollback(ctx, created, options.RollbackScope); rollbackErr != nil {
+			return errors.Wrapf(err, "rollback failed (%s)", rollbackErr)
+		}
+	}
+	if err == nil {
+		err = s.removeReplacedContainers(ctx, created, options)
+	}
+	if err != nil || !options.Wait {
+		return err
+	}
+	return s.waitHealthy(ctx, project, selected, options)
+}
+
+// setResourceNames prefixes names of the networks and volumes managed by project with the project name
+func setResourceNames(project *types.Project) {
+	for k, network := range project.Networks {
+		if !network.External.External && network.Name != "" {
+			network.Name = fmt.Sprintf("%s_%s", project.Name, k)
+			project.Networks[k] = network
+		}
+	}
 	for k, volume := range project.Volumes {
 		if !volume.External.External && volume.Name != "" {
 			volume.Name = fmt.Sprintf("%s_%s", project.Name, k)
 			project.Volumes[k] = volume
 		}
-		err := s.ensureVolume(ctx, volume)
+	}
+}
+
+// ensureRunning checks containers of selected services exist and are running, without applying any change
+func (s *local) ensureRunning(ctx context.Context, project *types.Project, options compose.UpOptions) error {
+	selected, err := getSelectedServices(project, options.Services, options.NoDeps)
+	if err != nil {
+		return err
+	}
+	for _, service := range project.Services {
+		if !selected[service.Name] {
+			continue
+		}
+		containers, err := s.getServiceContainers(ctx, project, service)
 		if err != nil {
 			return err
 		}
+		if len(containers) == 0 {
+			return fmt.Errorf("service %q has no container, run up without attach to create it", service.Name)
+		}
+		for _, c := range containers {
+			if c.State != "running" {
+				return fmt.Errorf("container %s of service %q is %s, run up without attach to start it", getContainerName(c), service.Name, c.State)
+			}
+		}
 	}
+	return nil
+}
 
+// waitHealthy waits for selected services with a healthcheck to be healthy, until timeout if set
+func (s *local) waitHealthy(ctx context.Context, project *types.Project, selected map[string]bool, options compose.UpOptions) error {
+	parent := ctx
+	timeout := options.WaitTimeout
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	w := progress.ContextWriter(ctx)
+	eg, ctx := errgroup.WithContext(ctx)
 	for _, service := range project.Services {
-		err := s.applyPullPolicy(ctx, service)
-		if err != nil {
-			return err
+		if !selected[service.Name] || service.HealthCheck == nil || service.HealthCheck.Disable {
+			continue
 		}
+		name := service.Name
+		eg.Go(func() error {
+			w.Event(progress.Event{
+				ID:         fmt.Sprintf("Service %q", name),
+				Status:     progress.Working,
+				StatusText: "Waiting",
+			})
+			err := waitUntil(ctx, getHealthCheckInterval(project, name), func(ctx context.Context) (bool, string, error) {
+				return s.isServiceHealthy(ctx, project, name)
+			})
+			// up might have its own deadline, shorter than timeout
+			if err == context.DeadlineExceeded && parent.Err() == nil {
+				err = fmt.Errorf("service %q is not healthy after %s", name, timeout)
+			}
+			if err != nil {
+				w.Event(progress.Event{
+					ID:         fmt.Sprintf("Service %q", name),
+					Status:     progress.Error,
+					StatusText: "Unhealthy",
+					Done:       true,
+				})
+				notifyTransition(options, name, compose.ServiceFailed, err)
+				return err
+			}
+			w.Event(progress.Event{
+				ID:         fmt.Sprintf("Service %q", name),
+				Status:     progress.Done,
+				StatusText: "Healthy",
+				Done:       true,
+			})
+			notifyTransition(options, name, compose.ServiceHealthy, nil)
+			return nil
+		})
 	}
+	return eg.Wait()
+}
 
-	err := inDependencyOrder(ctx, project, func(c context.Context, service types.ServiceConfig) error {
-		return s.ensureService(c, project, service)
-	})
-	return err
+// getReplicaName returns the name identifying a service replica in logs, as service_number
+func getReplicaName(c moby.Container) string {
+	service := c.Labels[serviceLabel]
+	if number, ok := c.Labels[containerNumberLabel]; ok {
+		return fmt.Sprintf("%s_%s", service, number)
+	}
+	return service
 }
 
 func getContainerName(c moby.Container) string {
@@ -95,32 +358,114 @@ func getContainerName(c moby.Container) string {
 	return c.Names[0][1:]
 }
 
-func (s *local) applyPullPolicy(ctx context.Context, service types.ServiceConfig) error {
+// applyPullPolicy pulls or builds service image according to its pull_policy, or to the one set by options
+func (s *local) applyPullPolicy(ctx context.Context, project *types.Project, service types.ServiceConfig, options compose.UpOptions) error {
+	platform, err := getPlatform(service)
+	if err != nil {
+		return err
+	}
+	policy := getPullPolicy(service, options.Pull)
+	if service.Build != nil {
+		switch {
+		case policy == pullPolicyBuild:
+			return s.ensureImageBuilt(ctx, project, service, true)
+		case policy == pullPolicyAlways && service.Image != "":
+			// image might not have been pushed yet, in which case it is built when missing locally
+			if err := s.pullImage(ctx, service, platform, options.QuietPull); err == nil {
+				return nil
+			}
+		}
+		return s.ensureImageBuilt(ctx, project, service, false)
+	}
+	if service.Image == "" || policy == pullPolicyNever {
+		return nil
+	}
+	if policy != pullPolicyAlways {
+		inspect, _, err := s.containerService.apiClient.ImageInspectWithRaw(ctx, service.Image)
+		if !errdefs.IsNotFound(err) && (err != nil || matchesPlatform(inspect, platform)) {
+			return nil
+		}
+	}
+	return s.pullImage(ctx, service, platform, options.QuietPull)
+}
+
+// getPlatform validates the platform service image is pulled or built for, set as `os[/arch[/variant]]`
+func getPlatform(service types.ServiceConfig) (string, error) {
+	if service.Platform == "" {
+		return "", nil
+	}
+	if _, err := platforms.Parse(service.Platform); err != nil {
+		return "", fmt.Errorf("invalid platform %q for service %q: expected os[/arch[/variant]]", service.Platform, service.Name)
+	}
+	return service.Platform, nil
+}
+
+// matchesPlatform checks a local image was pulled for platform, as engine only keeps one variant of an image
+// per tag. Any image matches when platform isn't set
+func matchesPlatform(image moby.ImageInspect, platform string) bool {
+	if platform == "" || image.Os == "" {
+		return true
+	}
+	expected, err := platforms.Parse(platform)
+	if err != nil {
+		return false
+	}
+	return platforms.Only(expected).Match(specs.Platform{
+		OS:           image.Os,
+		Architecture: image.Architecture,
+	})
+}
+
+// pullImage pulls service image, reporting progress of each layer, or only of the service when quiet
+func (s *local) pullImage(ctx context.Context, service types.ServiceConfig, platform string, quiet bool) error {
 	w := progress.ContextWriter(ctx)
-	// TODO build vs pull should be controlled by pull policy
-	// if service.Build {}
-	if service.Image != "" {
-		_, _, err := s.containerService.apiClient.ImageInspectWithRaw(ctx, service.Image)
-		if err != nil {
-			if errdefs.IsNotFound(err) {
-				stream, err := s.containerService.apiClient.ImagePull(ctx, service.Image, moby.ImagePullOptions{})
-				if err != nil {
-					return err
-				}
-				dec := json.NewDecoder(stream)
-				for {
-					var jm jsonmessage.JSONMessage
-					if err := dec.Decode(&jm); err != nil {
-						if err == io.EOF {
-							break
-						}
-						return err
-					}
-					toProgressEvent(jm, w)
-				}
+	eventName := fmt.Sprintf("Service %q", service.Name)
+	if quiet {
+		w.Event(progress.Event{
+			ID:         eventName,
+			Status:     progress.Working,
+			StatusText: "Pulling",
+		})
+	}
+	stream, err := s.containerService.apiClient.ImagePull(ctx, service.Image, moby.ImagePullOptions{
+		Platform: platform,
+	})
+	if err != nil {
+		return err
+	}
+	defer stream.Close() //nolint:errcheck
+
+	dec := json.NewDecoder(stream)
+	for {
+		var jm jsonmessage.JSONMessage
+		if err := dec.Decode(&jm); err != nil {
+			if err == io.EOF {
+				break
 			}
+			return err
+		}
+		if !quiet {
+			toProgressEvent(jm, w)
+			continue
+		}
+		if jm.Error != nil {
+			w.Event(progress.Event{
+				ID:         eventName,
+				Status:     progress.Error,
+				StatusText: jm.Error.Message,
+				Done:       true,
+			})
+			return errors.New(jm.Error.Message)
 		}
 	}
+	if quiet {
+		w.Event(progress.Event{
+			ID:         eventName,
+			Status:     progress.Done,
+			StatusText: "Pulled",
+			Done:       true,
+		})
+	}
 	return nil
 }
 
@@ -164,90 +509,347 @@ func toProgressEvent(jm jsonmessage.JSONMessage, w progress.Writer) {
 	}
 }
 
-func (s *local) Down(ctx context.Context, projectName string) error {
+func (s *local) Down(ctx context.Context, projectName string, options compose.DownOptions) error {
 	list, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
 		Filters: filters.NewArgs(
 			projectFilter(projectName),
 		),
+		All: true,
 	})
 	if err != nil {
 		return err
 	}
 
-	eg, ctx := errgroup.WithContext(ctx)
+	// without explicit timeout, engine applies the stop_grace_period set on containers
+	var timeout *uint32
+	if options.Timeout != nil {
+		t := uint32(options.Timeout.Seconds())
+		timeout = &t
+	}
+
+	limit, err := getParallelLimit(0)
+	if err != nil {
+		return err
+	}
+
 	w := progress.ContextWriter(ctx)
-	for _, c := range list {
-		container := c
-		eg.Go(func() error {
-			w.Event(progress.Event{
-				ID:     getContainerName(container),
-				Text:   "Stopping",
-				Status: progress.Working,
-				Done:   false,
-			})
-			err := s.containerService.Stop(ctx, container.ID, nil)
-			if err != nil {
-				return err
-			}
-			w.Event(progress.Event{
-				ID:     getContainerName(container),
-				Text:   "Removing",
-				Status: progress.Working,
-				Done:   false,
-			})
-			err = s.containerService.Delete(ctx, container.ID, containers.DeleteRequest{})
-			if err != nil {
-				return err
+	err = inReverseDependencyOrder(ctx, getServicesFromContainers(list), func(ctx context.Context, service types.ServiceConfig) error {
+		eg, errCtx := newLimitedGroup(ctx, limit)
+		for _, c := range list {
+			if c.Labels[serviceLabel] != service.Name {
+				continue
 			}
-			w.Event(progress.Event{
-				ID:     getContainerName(container),
-				Text:   "Removed",
-				Status: progress.Done,
-				Done:   true,
+			container := c
+			eg.Go(func() error {
+				w.Event(progress.Event{
+					ID:     getContainerName(container),
+					Text:   "Stopping",
+					Status: progress.Working,
+					Done:   false,
+				})
+				err := s.containerService.Stop(errCtx, container.ID, timeout)
+				if err != nil {
+					return err
+				}
+				w.Event(progress.Event{
+					ID:     getContainerName(container),
+					Text:   "Removing",
+					Status: progress.Working,
+					Done:   false,
+				})
+				err = s.containerService.Delete(errCtx, container.ID, containers.DeleteRequest{})
+				if err != nil {
+					return err
+				}
+				w.Event(progress.Event{
+					ID:     getContainerName(container),
+					Text:   "Removed",
+					Status: progress.Done,
+					Done:   true,
+				})
+				return nil
 			})
-			return nil
-		})
-	}
-	return eg.Wait()
-}
-
-func (s *local) Logs(ctx context.Context, projectName string, w io.Writer) error {
-	list, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
-		Filters: filters.NewArgs(
-			projectFilter(projectName),
-		),
+		}
+		return eg.Wait()
 	})
 	if err != nil {
 		return err
 	}
-	var wg sync.WaitGroup
-	consumer := formatter.NewLogConsumer(w)
-	for _, c := range list {
-		service := c.Labels[serviceLabel]
-		containerID := c.ID
-		go func() {
-			_ = s.containerService.Logs(ctx, containerID, containers.LogsRequest{
-				Follow: true,
-				Writer: consumer.GetWriter(service, containerID),
-			})
-			wg.Done()
-		}()
-		wg.Add(1)
+	err = removeFileReferences(projectName)
+	if err != nil {
+		return err
 	}
-	wg.Wait()
-	return nil
+	err = s.removeNetworks(ctx, projectName)
+	if err != nil || !options.Volumes {
+		return err
+	}
+	return s.removeVolumes(ctx, projectName)
 }
 
-func (s *local) Ps(ctx context.Context, projectName string) ([]compose.ServiceStatus, error) {
+// getSelectedServicesFromContainers returns the named services, or all services having a container in the list
+// when none is named. A named service without container is an error
+func getSelectedServicesFromContainers(list []moby.Container, names []string) (map[string]bool, error) {
+	selected := map[string]bool{}
+	for _, c := range list {
+		selected[c.Labels[serviceLabel]] = len(names) == 0
+	}
+	for _, name := range names {
+		if _, ok := selected[name]; !ok {
+			return nil, fmt.Errorf("no container found for service %q", name)
+		}
+		selected[name] = true
+	}
+	return selected, nil
+}
+
+// getServicesFromContainers rebuilds the services of a project and their dependencies from containers labels,
+// so that teardown can be ordered without access to the compose model
+func getServicesFromContainers(list []moby.Container) types.Services {
+	services := map[string]types.ServiceConfig{}
+	for _, c := range list {
+		name := c.Labels[serviceLabel]
+		service, ok := services[name]
+		if !ok {
+			service = types.ServiceConfig{
+				Name:      name,
+				DependsOn: types.DependsOnConfig{},
+			}
+		}
+		for _, dep := range strings.Split(c.Labels[dependenciesLabel], ",") {
+			if dep != "" {
+				service.DependsOn[dep] = types.ServiceDependency{Condition: types.ServiceConditionStarted}
+			}
+		}
+		services[name] = service
+	}
+
+	var result types.Services
+	for _, service := range services {
+		result = append(result, service)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (s *local) removeNetworks(ctx context.Context, projectName string) error {
+	networks, err := s.containerService.apiClient.NetworkList(ctx, moby.NetworkListOptions{
+		Filters: filters.NewArgs(
+			projectFilter(projectName),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	w := progress.ContextWriter(ctx)
+	for _, n := range networks {
+		w.Event(progress.Event{
+			ID:         fmt.Sprintf("Network %q", n.Name),
+			Status:     progress.Working,
+			StatusText: "Remove",
+			Done:       false,
+		})
+		err := s.containerService.apiClient.NetworkRemove(ctx, n.ID)
+		if err != nil {
+			w.Event(progress.Event{
+				ID:         fmt.Sprintf("Network %q", n.Name),
+				Status:     progress.Error,
+				StatusText: "Error",
+				Done:       true,
+			})
+			return errors.Wrapf(err, "failed to remove network %s", n.Name)
+		}
+		w.Event(progress.Event{
+			ID:         fmt.Sprintf("Network %q", n.Name),
+			Status:     progress.Done,
+			StatusText: "Removed",
+			Done:       true,
+		})
+	}
+	return nil
+}
+
+// removeVolumes removes the volumes labelled as created for the project, external ones being left untouched
+func (s *local) removeVolumes(ctx context.Context, projectName string) error {
+	list, err := s.containerService.apiClient.VolumeList(ctx, filters.NewArgs(
+		projectFilter(projectName),
+	))
+	if err != nil {
+		return err
+	}
+	w := progress.ContextWriter(ctx)
+	for _, v := range list.Volumes {
+		// filtering is applied by engine, but better safe than sorry when deleting resources
+		if v.Labels[projectLabel] != projectName {
+			continue
+		}
+		eventName := fmt.Sprintf("Volume %q", v.Name)
+		w.Event(progress.Event{
+			ID:         eventName,
+			Status:     progress.Working,
+			StatusText: "Remove",
+		})
+		err := s.containerService.apiClient.VolumeRemove(ctx, v.Name, false)
+		if err != nil {
+			w.Event(progress.Event{
+				ID:         eventName,
+				Status:     progress.Error,
+				StatusText: "Error",
+				Done:       true,
+			})
+			return errors.Wrapf(err, "failed to remove volume %s", v.Name)
+		}
+		w.Event(progress.Event{
+			ID:         eventName,
+			Status:     progress.Done,
+			StatusText: "Removed",
+			Done:       true,
+		})
+	}
+	return nil
+}
+
+func (s *local) Logs(ctx context.Context, projectName string, w io.Writer, options compose.LogOptions) error {
+	// validate time filters upfront, as streaming errors are not reported
+	now := time.Now()
+	for _, filter := range [][2]string{{"since", options.Since}, {"until", options.Until}} {
+		if filter[1] == "" {
+			continue
+		}
+		if _, err := timetypes.GetTimestamp(filter[1], now); err != nil {
+			return errors.Wrapf(err, "invalid %s value %q", filter[0], filter[1])
+		}
+	}
+
 	list, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
 		Filters: filters.NewArgs(
 			projectFilter(projectName),
 		),
 	})
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	consumer := formatter.NewLogConsumer(w)
+	for _, c := range list {
+		if len(options.Services) > 0 && !contains(options.Services, c.Labels[serviceLabel]) {
+			continue
+		}
+		replica := getReplicaName(c)
+		containerID := c.ID
+		go func() {
+			// streaming stops once context is cancelled
+			_ = s.containerService.Logs(ctx, containerID, containers.LogsRequest{
+				Follow:     true,
+				Writer:     consumer.GetWriter(replica, containerID),
+				Timestamps: options.Timestamps,
+				Since:      options.Since,
+				Until:      options.Until,
+			})
+			wg.Done()
+		}()
+		wg.Add(1)
+	}
+	wg.Wait()
+	return nil
+}
+
+func (s *local) Ps(ctx context.Context, projectName string) ([]compose.ServiceStatus, error) {
+	list, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
+		Filters: filters.NewArgs(
+			projectFilter(projectName),
+		),
+	})
+	if err != nil {
+		return nil, err
+	}
+	services, err := containersToServiceStatus(list)
 	if err != nil {
 		return nil, err
 	}
-	return containersToServiceStatus(list)
+
+	summaries := map[string][]compose.ContainerSummary{}
+	for _, c := range list {
+		container, err := s.containerService.apiClient.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			return nil, err
+		}
+		service := c.Labels[serviceLabel]
+		summaries[service] = append(summaries[service], toContainerSummary(c, container))
+	}
+	for i, service := range services {
+		services[i].Containers = summaries[service.Name]
+		for _, c := range services[i].Containers {
+			for _, p := range c.Publishers {
+				services[i].Publishers = append(services[i].Publishers, p)
+				services[i].Ports = append(services[i].Ports, fmt.Sprintf("%s:%d->%d/%s", p.URL, p.PublishedPort, p.TargetPort, p.Protocol))
+			}
+		}
+	}
+	return services, nil
+}
+
+func toContainerSummary(c moby.Container, container moby.ContainerJSON) compose.ContainerSummary {
+	summary := compose.ContainerSummary{
+		ID:     c.ID,
+		Name:   getContainerName(c),
+		State:  c.State,
+		Health: compose.HealthNone,
+	}
+	if container.ContainerJSONBase == nil {
+		return summary
+	}
+	summary.Created = parseTimestamp(container.Created)
+	summary.RestartCount = container.RestartCount
+	if container.NetworkSettings != nil {
+		summary.Publishers = toPublishers(container.NetworkSettings.Ports)
+	}
+	if container.State != nil {
+		summary.StartedAt = parseTimestamp(container.State.StartedAt)
+		if container.State.Health != nil {
+			summary.Health = container.State.Health.Status
+		}
+	}
+	return summary
+}
+
+// toPublishers lists the ports bound on host, sorted by container port
+func toPublishers(ports nat.PortMap) []compose.PortPublisher {
+	var publishers []compose.PortPublisher
+	for port, bindings := range ports {
+		for _, binding := range bindings {
+			published, err := strconv.Atoi(binding.HostPort)
+			if err != nil {
+				continue
+			}
+			publishers = append(publishers, compose.PortPublisher{
+				URL:           binding.HostIP,
+				TargetPort:    port.Int(),
+				PublishedPort: published,
+				Protocol:      port.Proto(),
+			})
+		}
+	}
+	sort.Slice(publishers, func(i, j int) bool {
+		if publishers[i].TargetPort != publishers[j].TargetPort {
+			return publishers[i].TargetPort < publishers[j].TargetPort
+		}
+		if publishers[i].Protocol != publishers[j].Protocol {
+			return publishers[i].Protocol < publishers[j].Protocol
+		}
+		return publishers[i].URL < publishers[j].URL
+	})
+	return publishers
+}
+
+// parseTimestamp parses a timestamp as set by engine on inspect, returning zero time when unset or invalid
+func parseTimestamp(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
 }
 
 func containersToServiceStatus(containers []moby.Container) ([]compose.ServiceStatus, error) {
@@ -287,158 +889,640 @@ func groupContainerByLabel(containers []moby.Container, labelName string) (map[s
 			labelContainers = []moby.Container{}
 			keys = append(keys, label)
 		}
-		labelContainers = append(labelContainers, c)
-		containersByLabel[label] = labelContainers
+		labelContainers = append(labelContainers, c)
+		containersByLabel[label] = labelContainers
+	}
+	sort.Strings(keys)
+	return containersByLabel, keys, nil
+}
+
+func (s *local) List(ctx context.Context, projectName string) ([]compose.Stack, error) {
+	list, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
+		Filters: filters.NewArgs(hasProjectLabelFilter()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return containersToStacks(list)
+}
+
+func containersToStacks(containers []moby.Container) ([]compose.Stack, error) {
+	containersByLabel, keys, err := groupContainerByLabel(containers, projectLabel)
+	if err != nil {
+		return nil, err
+	}
+	var projects []compose.Stack
+	for _, project := range keys {
+		projects = append(projects, compose.Stack{
+			ID:     project,
+			Name:   project,
+			Status: combinedStatus(containerToState(containersByLabel[project])),
+		})
+	}
+	return projects, nil
+}
+
+func containerToState(containers []moby.Container) []string {
+	statuses := []string{}
+	for _, c := range containers {
+		statuses = append(statuses, c.State)
+	}
+	return statuses
+}
+
+func combinedStatus(statuses []string) string {
+	nbByStatus := map[string]int{}
+	keys := []string{}
+	for _, status := range statuses {
+		nb, ok := nbByStatus[status]
+		if !ok {
+			nb = 0
+			keys = append(keys, status)
+		}
+		nbByStatus[status] = nb + 1
+	}
+	sort.Strings(keys)
+	result := ""
+	for _, status := range keys {
+		nb := nbByStatus[status]
+		if result != "" {
+			result = result + ", "
+		}
+		result = result + fmt.Sprintf("%s(%d)", status, nb)
+	}
+	return result
+}
+
+func (s *local) Convert(ctx context.Context, project *types.Project, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.MarshalIndent(project, "", "  ")
+	case "yaml":
+		return yaml.Marshal(project)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+func getContainerCreateOptions(p *types.Project, s types.ServiceConfig, number int, inherit *moby.Container) (*container.Config, *container.HostConfig, *network.NetworkingConfig, error) {
+	hash, err := jsonHash(s)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	config, err := getRecordedConfig(s)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	dependencies := getDependencies(s)
+	sort.Strings(dependencies)
+	annotations, err := getAnnotations(s)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	labels := map[string]string{}
+	for k, v := range s.Labels {
+		labels[k] = v
+	}
+	for k, v := range annotations {
+		labels[k] = v
+	}
+	// compose labels win over user defined ones, as those are used to manage containers
+	for k, v := range map[string]string{
+		projectLabel:           p.Name,
+		serviceLabel:           s.Name,
+		configHashLabel:        hash,
+		configHashVersionLabel: configHashVersion,
+		containerNumberLabel:   strconv.Itoa(number),
+		replicaLabel:           compose.GetReplicaKey(s.Name, number),
+		dependenciesLabel:      strings.Join(dependencies, ","),
+		configLabel:            string(config),
+		workingDirLabel:        p.WorkingDir,
+		oneoffLabel:            "False",
+		versionLabel:           internal.Version,
+	} {
+		labels[k] = v
+	}
+
+	var (
+		runCmd     strslice.StrSlice
+		entrypoint strslice.StrSlice
+	)
+	if len(s.Command) > 0 {
+		runCmd = strslice.StrSlice(s.Command)
+	}
+	if len(s.Entrypoint) > 0 {
+		entrypoint = strslice.StrSlice(s.Entrypoint)
+	}
+	image := getImageName(p, s)
+
+	if s.MacAddress != "" {
+		// engine assigns the address to the container ethernet interface, so only accept 48-bit ones
+		mac, err := net.ParseMAC(s.MacAddress)
+		if err != nil || len(mac) != 6 {
+			return nil, nil, nil, fmt.Errorf("invalid mac_address %q for service %q: expected 6 hexadecimal octets, e.g. 02:42:ac:11:00:02", s.MacAddress, s.Name)
+		}
+	}
+
+	var (
+		tty         = s.Tty
+		stdinOpen   = s.StdinOpen
+		attachStdin = false
+	)
+
+	containerConfig := container.Config{
+		Hostname:        s.Hostname,
+		Domainname:      s.DomainName,
+		User:            s.User,
+		ExposedPorts:    buildContainerPorts(s),
+		Tty:             tty,
+		OpenStdin:       stdinOpen,
+		StdinOnce:       true,
+		AttachStdin:     attachStdin,
+		AttachStderr:    true,
+		AttachStdout:    true,
+		Cmd:             runCmd,
+		Image:           image,
+		WorkingDir:      s.WorkingDir,
+		Entrypoint:      entrypoint,
+		NetworkDisabled: s.NetworkMode == "disabled",
+		MacAddress:      s.MacAddress,
+		Labels:          labels,
+		StopSignal:      s.StopSignal,
+		Env:             toMobyEnv(s.Environment),
+		Healthcheck:     toMobyHealthCheck(s.HealthCheck),
+		// Volumes:         // FIXME unclear to me the overlap with HostConfig.Mounts
+		StopTimeout: toSeconds(s.StopGracePeriod),
+	}
+
+	resources, err := getDeployResources(s)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	extraHosts, err := toExtraHosts(s.ExtraHosts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	dns, err := toDNS(s.DNS)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tmpfs, err := toTmpfs(s.Tmpfs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	securityOpts, err := toSecurityOpts(p, s.SecurityOpt)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	logConfig, err := toLogConfig(s.Logging)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	devices, err := toDevices(s.Devices)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	resources.Devices = devices
+	resources.DeviceCgroupRules, err = getDeviceCgroupRules(s)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	groupAdd, err := getGroupAdd(s)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	err = applyBlkioConfig(s, &resources)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	secretMounts, err := buildContainerSecretMounts(p, s)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	mountOptions := mergeMounts(buildContainerMountOptions(p, s, inherit), secretMounts)
+	bindings := buildContainerBindingOptions(s)
+
+	networkMode, err := getNetworkMode(p, s)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	volumesFrom, err := getVolumesFrom(p, s)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ipcMode, err := getIpcMode(p, s)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pidMode, err := getNamespaceMode(p, s, "pid", s.Pid)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	isolation, err := getIsolation(s)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	hostConfig := container.HostConfig{
+		Mounts:         mountOptions,
+		CapAdd:         toCapabilities(s.CapAdd),
+		CapDrop:        toCapabilities(s.CapDrop),
+		NetworkMode:    networkMode,
+		VolumesFrom:    volumesFrom,
+		IpcMode:        container.IpcMode(ipcMode),
+		PidMode:        container.PidMode(pidMode),
+		UsernsMode:     container.UsernsMode(s.UserNSMode),
+		Init:           s.Init,
+		ReadonlyRootfs: s.ReadOnly,
+		// ShmSize: , TODO
+		Sysctls:      s.Sysctls,
+		PortBindings: bindings,
+		Resources:    resources,
+		ExtraHosts:   extraHosts,
+		DNS:          dns,
+		DNSSearch:    s.DNSSearch,
+		DNSOptions:   s.DNSOpts,
+		GroupAdd:     groupAdd,
+		Tmpfs:        tmpfs,
+		SecurityOpt:  securityOpts,
+		LogConfig:    logConfig,
+		Isolation:    isolation,
+	}
+
+	networkConfig := buildDefaultNetworkConfig(p, s, networkMode)
+	return &containerConfig, &hostConfig, networkConfig, nil
+}
+
+func getDeployResources(s types.ServiceConfig) (container.Resources, error) {
+	resources := container.Resources{
+		Memory:            int64(s.MemLimit),
+		MemoryReservation: int64(s.MemReservation),
+		Ulimits:           toUlimits(s.Ulimits),
+		// cgroup namespace mode (`cgroup: host|private`) isn't supported: compose-go doesn't retain it, and engine API
+		// types in use have no CgroupnsMode, HostConfig.Cgroup selecting another container's cgroup instead
+		CgroupParent: s.CgroupParent,
+	}
+	if s.CPUS != 0 {
+		cpus, err := toNanoCPUs(strconv.FormatFloat(float64(s.CPUS), 'f', -1, 32))
+		if err != nil {
+			return resources, err
+		}
+		resources.NanoCPUs = cpus
+	}
+	if s.PidLimit != 0 {
+		pids := s.PidLimit
+		resources.PidsLimit = &pids
+	}
+	if s.Deploy == nil {
+		return resources, nil
+	}
+
+	if limits := s.Deploy.Resources.Limits; limits != nil {
+		if limits.MemoryBytes != 0 {
+			resources.Memory = int64(limits.MemoryBytes)
+		}
+		if limits.NanoCPUs != "" {
+			cpus, err := toNanoCPUs(limits.NanoCPUs)
+			if err != nil {
+				return resources, err
+			}
+			resources.NanoCPUs = cpus
+		}
+	}
+	if reservations := s.Deploy.Resources.Reservations; reservations != nil {
+		if reservations.MemoryBytes != 0 {
+			resources.MemoryReservation = int64(reservations.MemoryBytes)
+		}
+	}
+	return resources, nil
+}
+
+func toNanoCPUs(cpus string) (int64, error) {
+	f, err := strconv.ParseFloat(cpus, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid cpus value %q", cpus)
+	}
+	return int64(f * 1e9), nil
+}
+
+// toExtraHosts validates `extra_hosts` entries and converts them to the host:ip format expected by engine.
+// As IPv6 addresses contain colons, entries are split on the first one, and brackets around address are dropped
+func toExtraHosts(hosts types.HostsList) ([]string, error) {
+	var result []string
+	for _, h := range hosts {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid extra host %q: expected host:ip", h)
+		}
+		ip := strings.TrimSuffix(strings.TrimPrefix(parts[1], "["), "]")
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid extra host %q: %q is not a valid IP address", h, parts[1])
+		}
+		result = append(result, fmt.Sprintf("%s:%s", parts[0], ip))
+	}
+	return result, nil
+}
+
+// toDNS validates `dns` entries are IP addresses, as engine doesn't resolve DNS servers names
+func toDNS(servers types.StringList) ([]string, error) {
+	for _, server := range servers {
+		if net.ParseIP(server) == nil {
+			return nil, fmt.Errorf("invalid dns %q: not a valid IP address", server)
+		}
+	}
+	return servers, nil
+}
+
+// toTmpfs converts `tmpfs` entries, declared as `path[:options]`, into the path to mount options mapping expected by
+// engine. Options are comma separated mount options, `size` and `mode` values are validated
+func toTmpfs(entries types.StringList) (map[string]string, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	tmpfs := map[string]string{}
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 2)
+		target := parts[0]
+		if !path.IsAbs(target) {
+			return nil, fmt.Errorf("invalid tmpfs %q: mount path must be absolute", entry)
+		}
+		options := ""
+		if len(parts) == 2 {
+			options = parts[1]
+			for _, option := range strings.Split(options, ",") {
+				kv := strings.SplitN(option, "=", 2)
+				switch kv[0] {
+				case "size":
+					if len(kv) != 2 {
+						return nil, fmt.Errorf("invalid tmpfs %q: size requires a value", entry)
+					}
+					if _, err := units.RAMInBytes(kv[1]); err != nil {
+						return nil, errors.Wrapf(err, "invalid tmpfs %q", entry)
+					}
+				case "mode":
+					if len(kv) != 2 {
+						return nil, fmt.Errorf("invalid tmpfs %q: mode requires a value", entry)
+					}
+					if _, err := strconv.ParseUint(kv[1], 8, 32); err != nil {
+						return nil, fmt.Errorf("invalid tmpfs %q: mode must be an octal value", entry)
+					}
+				case "":
+					return nil, fmt.Errorf("invalid tmpfs %q: empty option", entry)
+				}
+			}
+		}
+		tmpfs[target] = options
+	}
+	return tmpfs, nil
+}
+
+// toSecurityOpts validates `security_opt` entries, declared as `key=value` or `key:value`, and converts them into the
+// `key=value` format expected by engine. A seccomp profile is set by path, so its JSON content gets loaded
+func toSecurityOpts(p *types.Project, opts []string) ([]string, error) {
+	var result []string
+	for _, opt := range opts {
+		if opt == "no-new-privileges" {
+			result = append(result, opt)
+			continue
+		}
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 {
+			kv = strings.SplitN(opt, ":", 2)
+		}
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("invalid security_opt %q: expected key=value", opt)
+		}
+		switch kv[0] {
+		case "label", "apparmor", "no-new-privileges", "systempaths":
+		case "seccomp":
+			if kv[1] != "unconfined" {
+				profile, err := loadSeccompProfile(p, kv[1])
+				if err != nil {
+					return nil, errors.Wrapf(err, "invalid security_opt %q", opt)
+				}
+				kv[1] = profile
+			}
+		default:
+			return nil, fmt.Errorf("invalid security_opt %q: unsupported option %q", opt, kv[0])
+		}
+		result = append(result, fmt.Sprintf("%s=%s", kv[0], kv[1]))
 	}
-	sort.Strings(keys)
-	return containersByLabel, keys, nil
+	return result, nil
 }
 
-func (s *local) List(ctx context.Context, projectName string) ([]compose.Stack, error) {
-	list, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
-		Filters: filters.NewArgs(hasProjectLabelFilter()),
-	})
+func loadSeccompProfile(p *types.Project, file string) (string, error) {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(p.WorkingDir, file)
+	}
+	b, err := ioutil.ReadFile(file)
 	if err != nil {
-		return nil, err
+		return "", errors.Wrap(err, "failed to load seccomp profile")
 	}
-
-	return containersToStacks(list)
+	var profile bytes.Buffer
+	err = json.Compact(&profile, b)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse seccomp profile")
+	}
+	return profile.String(), nil
 }
 
-func containersToStacks(containers []moby.Container) ([]compose.Stack, error) {
-	containersByLabel, keys, err := groupContainerByLabel(containers, projectLabel)
-	if err != nil {
-		return nil, err
+// logDriverName matches log drivers built into engine as well as logging plugins, as `[registry/]name[:tag]`
+var logDriverName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.\-]*(/[a-zA-Z0-9][a-zA-Z0-9_.\-]*)*(:[a-zA-Z0-9_.\-]+)?$`)
+
+// toLogConfig converts `logging` into the container log configuration, options are passed to the driver verbatim.
+// Engine default log driver applies when none is set
+func toLogConfig(logging *types.LoggingConfig) (container.LogConfig, error) {
+	if logging == nil {
+		return container.LogConfig{}, nil
 	}
-	var projects []compose.Stack
-	for _, project := range keys {
-		projects = append(projects, compose.Stack{
-			ID:     project,
-			Name:   project,
-			Status: combinedStatus(containerToState(containersByLabel[project])),
-		})
+	if logging.Driver != "" && !logDriverName.MatchString(logging.Driver) {
+		return container.LogConfig{}, fmt.Errorf("invalid logging driver %q", logging.Driver)
 	}
-	return projects, nil
+	return container.LogConfig{
+		Type:   logging.Driver,
+		Config: logging.Options,
+	}, nil
 }
 
-func containerToState(containers []moby.Container) []string {
-	statuses := []string{}
-	for _, c := range containers {
-		statuses = append(statuses, c.State)
+// toCapabilities normalizes capability names as upper case without `CAP_` prefix, so they're understood by
+// engines which don't normalize them
+func toCapabilities(caps []string) strslice.StrSlice {
+	var result strslice.StrSlice
+	for _, c := range caps {
+		c = strings.ToUpper(c)
+		result = append(result, strings.TrimPrefix(c, "CAP_"))
 	}
-	return statuses
+	return result
 }
 
-func combinedStatus(statuses []string) string {
-	nbByStatus := map[string]int{}
-	keys := []string{}
-	for _, status := range statuses {
-		nb, ok := nbByStatus[status]
-		if !ok {
-			nb = 0
-			keys = append(keys, status)
+// toDevices converts `devices`, set as `host[:container][:permissions]`, into container device mappings. Container
+// path defaults to the host one, permissions default to `rwm`
+func toDevices(devices []string) ([]container.DeviceMapping, error) {
+	var result []container.DeviceMapping
+	for _, device := range devices {
+		parts := strings.Split(device, ":")
+		mapping := container.DeviceMapping{
+			PathOnHost:        parts[0],
+			PathInContainer:   parts[0],
+			CgroupPermissions: "rwm",
 		}
-		nbByStatus[status] = nb + 1
-	}
-	sort.Strings(keys)
-	result := ""
-	for _, status := range keys {
-		nb := nbByStatus[status]
-		if result != "" {
-			result = result + ", "
+		switch len(parts) {
+		case 1:
+		case 2:
+			if isDevicePermissions(parts[1]) {
+				mapping.CgroupPermissions = parts[1]
+			} else {
+				mapping.PathInContainer = parts[1]
+			}
+		case 3:
+			mapping.PathInContainer = parts[1]
+			mapping.CgroupPermissions = parts[2]
+		default:
+			return nil, fmt.Errorf("invalid device %q: expected host[:container][:permissions]", device)
 		}
-		result = result + fmt.Sprintf("%s(%d)", status, nb)
+		if !path.IsAbs(mapping.PathOnHost) || !path.IsAbs(mapping.PathInContainer) {
+			return nil, fmt.Errorf("invalid device %q: device paths must be absolute", device)
+		}
+		if !isDevicePermissions(mapping.CgroupPermissions) {
+			return nil, fmt.Errorf("invalid device %q: permissions must be a combination of r, w and m", device)
+		}
+		result = append(result, mapping)
 	}
-	return result
+	return result, nil
 }
 
-func (s *local) Convert(ctx context.Context, project *types.Project, format string) ([]byte, error) {
-	switch format {
-	case "json":
-		return json.MarshalIndent(project, "", "  ")
-	case "yaml":
-		return yaml.Marshal(project)
-	default:
-		return nil, fmt.Errorf("unsupported format %q", format)
+// isDevicePermissions checks s is a set of device cgroup permissions: read, write and mknod
+func isDevicePermissions(s string) bool {
+	if s == "" || len(s) > 3 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("rwm", c) {
+			return false
+		}
 	}
+	return true
 }
 
-func getContainerCreateOptions(p *types.Project, s types.ServiceConfig, number int, inherit *moby.Container) (*container.Config, *container.HostConfig, *network.NetworkingConfig, error) {
-	hash, err := jsonHash(s)
+// extDeviceCgroupRules is set as an extension as the compose model doesn't retain `device_cgroup_rules`, it uses the
+// same syntax:
+//
+//	x-device_cgroup_rules:
+//	  - 'c 1:3 mr'
+//	  - 'a 7:* rmw'
+const extDeviceCgroupRules = "x-device_cgroup_rules"
+
+// getDeviceCgroupRules returns the rules added to container devices cgroup, checking those are set as
+// `type major:minor permissions`, engine only validating them once the container gets created
+func getDeviceCgroupRules(service types.ServiceConfig) ([]string, error) {
+	value, ok := service.Extensions[extDeviceCgroupRules]
+	if !ok {
+		return nil, nil
+	}
+	var rules []string
+	marshalled, err := json.Marshal(value)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
 	}
-	labels := map[string]string{
-		projectLabel:         p.Name,
-		serviceLabel:         s.Name,
-		configHashLabel:      hash,
-		containerNumberLabel: strconv.Itoa(number),
+	err = json.Unmarshal(marshalled, &rules)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s for service %q", extDeviceCgroupRules, service.Name)
+	}
+	for _, rule := range rules {
+		if !isDeviceCgroupRule(rule) {
+			return nil, fmt.Errorf("invalid %s for service %q: rule %q must be set as `type major:minor permissions`",
+				extDeviceCgroupRules, service.Name, rule)
+		}
 	}
+	return rules, nil
+}
 
-	var (
-		runCmd     strslice.StrSlice
-		entrypoint strslice.StrSlice
-	)
-	if len(s.Command) > 0 {
-		runCmd = strslice.StrSlice(s.Command)
+// isDeviceCgroupRule checks rule applies to all (a), char (c) or block (b) devices, identified by their major and
+// minor numbers, or * for any
+func isDeviceCgroupRule(rule string) bool {
+	fields := strings.Fields(rule)
+	if len(fields) != 3 || len(fields[0]) != 1 || !strings.Contains("acb", fields[0]) || !isDevicePermissions(fields[2]) {
+		return false
 	}
-	if len(s.Entrypoint) > 0 {
-		entrypoint = strslice.StrSlice(s.Entrypoint)
+	numbers := strings.Split(fields[1], ":")
+	if len(numbers) != 2 {
+		return false
 	}
-	image := s.Image
-	if s.Image == "" {
-		image = fmt.Sprintf("%s_%s", p.Name, s.Name)
+	for _, n := range numbers {
+		if _, err := strconv.ParseUint(n, 10, 32); err != nil && n != "*" {
+			return false
+		}
 	}
+	return true
+}
 
-	var (
-		tty         = s.Tty
-		stdinOpen   = s.StdinOpen
-		attachStdin = false
-	)
+// extGroupAdd is set as an extension as compose-go decodes `group_add` from a misspelled `group_app` key, so it never
+// gets set from a compose file. It uses the same syntax, groups being set by name or GID:
+//
+//	x-group_add:
+//	  - audio
+//	  - 1001
+const extGroupAdd = "x-group_add"
 
-	containerConfig := container.Config{
-		Hostname:        s.Hostname,
-		Domainname:      s.DomainName,
-		User:            s.User,
-		ExposedPorts:    buildContainerPorts(s),
-		Tty:             tty,
-		OpenStdin:       stdinOpen,
-		StdinOnce:       true,
-		AttachStdin:     attachStdin,
-		AttachStderr:    true,
-		AttachStdout:    true,
-		Cmd:             runCmd,
-		Image:           image,
-		WorkingDir:      s.WorkingDir,
-		Entrypoint:      entrypoint,
-		NetworkDisabled: s.NetworkMode == "disabled",
-		MacAddress:      s.MacAddress,
-		Labels:          labels,
-		StopSignal:      s.StopSignal,
-		Env:             toMobyEnv(s.Environment),
-		Healthcheck:     toMobyHealthCheck(s.HealthCheck),
-		// Volumes:         // FIXME unclear to me the overlap with HostConfig.Mounts
-		StopTimeout: toSeconds(s.StopGracePeriod),
+// getGroupAdd returns the supplementary groups container user is added to, which engine resolves
+func getGroupAdd(service types.ServiceConfig) ([]string, error) {
+	value, ok := service.Extensions[extGroupAdd]
+	if !ok {
+		return service.GroupAdd, nil
 	}
-
-	mountOptions := buildContainerMountOptions(p, s, inherit)
-	bindings := buildContainerBindingOptions(s)
-
-	networkMode := getNetworkMode(p, s)
-	hostConfig := container.HostConfig{
-		Mounts:         mountOptions,
-		CapAdd:         strslice.StrSlice(s.CapAdd),
-		CapDrop:        strslice.StrSlice(s.CapDrop),
-		NetworkMode:    networkMode,
-		Init:           s.Init,
-		ReadonlyRootfs: s.ReadOnly,
-		// ShmSize: , TODO
-		Sysctls:      s.Sysctls,
-		PortBindings: bindings,
+	var groups []interface{}
+	marshalled, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(marshalled, &groups)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s for service %q", extGroupAdd, service.Name)
+	}
+	groupAdd := append([]string{}, service.GroupAdd...)
+	for _, group := range groups {
+		switch g := group.(type) {
+		case string:
+			groupAdd = append(groupAdd, g)
+		case float64:
+			if g < 0 || g != float64(uint32(g)) {
+				return nil, fmt.Errorf("invalid %s for service %q: GID %v must be a positive integer", extGroupAdd, service.Name, g)
+			}
+			groupAdd = append(groupAdd, strconv.FormatUint(uint64(g), 10))
+		default:
+			return nil, fmt.Errorf("invalid %s for service %q: group %v must be a name or a GID", extGroupAdd, service.Name, group)
+		}
 	}
+	return groupAdd, nil
+}
 
-	networkConfig := buildDefaultNetworkConfig(s, networkMode)
-	return &containerConfig, &hostConfig, networkConfig, nil
+func toUlimits(ulimits map[string]*types.UlimitsConfig) []*units.Ulimit {
+	var result []*units.Ulimit
+	for name, u := range ulimits {
+		soft, hard := u.Soft, u.Hard
+		if u.Single != 0 {
+			soft, hard = u.Single, u.Single
+		}
+		result = append(result, &units.Ulimit{
+			Name: name,
+			Soft: int64(soft),
+			Hard: int64(hard),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
 }
 
 func buildContainerPorts(s types.ServiceConfig) nat.PortSet {
@@ -477,12 +1561,26 @@ func buildContainerMountOptions(p *types.Project, s types.ServiceConfig, inherit
 			if m.Type == "volume" {
 				src = m.Name
 			}
-			mounts = append(mounts, mount.Mount{
+			inheritedMount := mount.Mount{
 				Type:     m.Type,
 				Source:   src,
 				Target:   m.Destination,
 				ReadOnly: !m.RW,
-			})
+			}
+			if m.Type == mount.TypeBind && m.Propagation != "" {
+				inheritedMount.BindOptions = &mount.BindOptions{Propagation: m.Propagation}
+			}
+			// long syntax options of the declared volume still apply to the mount inherited for the same target
+			for _, v := range s.Volumes {
+				if v.Target == m.Destination {
+					inheritedMount.Consistency = mount.Consistency(v.Consistency)
+					if v.Bind != nil {
+						inheritedMount.BindOptions = buildBindOption(v.Bind)
+					}
+					inheritedMount.VolumeOptions = buildVolumeOptions(v.Volume)
+				}
+			}
+			mounts = append(mounts, inheritedMount)
 			inherited = append(inherited, m.Destination)
 		}
 	}
@@ -511,6 +1609,21 @@ func buildContainerMountOptions(p *types.Project, s types.ServiceConfig, inherit
 	return mounts
 }
 
+// mergeMounts adds mounts to the base ones, replacing those set for the same target
+func mergeMounts(base []mount.Mount, mounts []mount.Mount) []mount.Mount {
+	merged := []mount.Mount{}
+	for _, m := range base {
+		overridden := false
+		for _, o := range mounts {
+			overridden = overridden || o.Target == m.Target
+		}
+		if !overridden {
+			merged = append(merged, m)
+		}
+	}
+	return append(merged, mounts...)
+}
+
 func buildBindOption(bind *types.ServiceVolumeBind) *mount.BindOptions {
 	if bind == nil {
 		return nil
@@ -542,11 +1655,23 @@ func buildTmpfsOptions(tmpfs *types.ServiceVolumeTmpfs) *mount.TmpfsOptions {
 	}
 }
 
-func buildDefaultNetworkConfig(s types.ServiceConfig, networkMode container.NetworkMode) *network.NetworkingConfig {
+func buildDefaultNetworkConfig(p *types.Project, s types.ServiceConfig, networkMode container.NetworkMode) *network.NetworkingConfig {
 	config := map[string]*network.EndpointSettings{}
+	if s.NetworkMode != "" {
+		// container doesn't join a project network, aliases are only supported by user defined networks
+		return &network.NetworkingConfig{
+			EndpointsConfig: config,
+		}
+	}
 	net := string(networkMode)
+	var networkConfig *types.ServiceNetworkConfig
+	for name, c := range s.Networks {
+		if p.Networks[name].Name == net {
+			networkConfig = c
+		}
+	}
 	config[net] = &network.EndpointSettings{
-		Aliases: getAliases(s, s.Networks[net]),
+		Aliases: getAliases(s, networkConfig),
 	}
 
 	return &network.NetworkingConfig{
@@ -562,26 +1687,116 @@ func getAliases(s types.ServiceConfig, c *types.ServiceNetworkConfig) []string {
 	return aliases
 }
 
-func getNetworkMode(p *types.Project, service types.ServiceConfig) container.NetworkMode {
+// getNetworkMode computes the network mode container is created with. When `network_mode` is set, container doesn't
+// join project networks, `service:name` is kept as is to share the network namespace of an existing container of that
+// service once it gets created
+func getNetworkMode(p *types.Project, service types.ServiceConfig) (container.NetworkMode, error) {
 	mode := service.NetworkMode
 	if mode == "" {
 		if len(p.Networks) > 0 {
-			for name := range getNetworksForService(service) {
-				return container.NetworkMode(p.Networks[name].Name)
-			}
+			// container is created attached to the network with the highest priority, which sets the default gateway
+			name := getNetworksByPriority(service)[0]
+			return container.NetworkMode(p.Networks[name].Name), nil
 		}
-		return container.NetworkMode("none")
+		return container.NetworkMode("none"), nil
 	}
 
-	/// FIXME incomplete implementation
+	if hasExplicitNetworks(service) {
+		return "", fmt.Errorf("service %q declares network_mode %q, which can't be combined with networks", service.Name, mode)
+	}
 	if strings.HasPrefix(mode, "service:") {
-		panic("Not yet implemented")
+		name := strings.TrimPrefix(mode, "service:")
+		if _, err := p.GetService(name); err != nil {
+			return "", fmt.Errorf("service %q network_mode refers to undefined service %q", service.Name, name)
+		}
+		return container.NetworkMode(mode), nil
+	}
+	if mode == "container:" {
+		return "", fmt.Errorf("service %q network_mode %q doesn't set a container", service.Name, mode)
+	}
+	return container.NetworkMode(mode), nil
+}
+
+// getIpcMode computes the IPC namespace mode container is created with. Containers of services which IPC namespace
+// is used by other services are made shareable, as engine may create them private by default
+func getIpcMode(p *types.Project, service types.ServiceConfig) (string, error) {
+	if service.Ipc != "" {
+		return getNamespaceMode(p, service, "ipc", service.Ipc)
+	}
+	for _, s := range p.Services {
+		if s.Ipc == "service:"+service.Name {
+			return "shareable", nil
+		}
+	}
+	return "", nil
+}
+
+// getIsolation checks the `isolation` technology is one engine knows. Engine running on Linux only supports default
+// isolation, and rejects others when the container is created
+func getIsolation(service types.ServiceConfig) (container.Isolation, error) {
+	isolation := container.Isolation(service.Isolation)
+	switch {
+	case isolation.IsDefault(), isolation.IsProcess(), isolation.IsHyperV():
+		return isolation, nil
+	}
+	return "", fmt.Errorf("invalid isolation %q for service %q: must be one of default, process, hyperv", service.Isolation, service.Name)
+}
+
+// getNamespaceMode checks `ipc` or `pid` set as `service:name` refers to a declared service. Those are kept as is, to
+// be resolved into a container of that service once it exists, other modes are passed as is to engine
+func getNamespaceMode(p *types.Project, service types.ServiceConfig, kind string, mode string) (string, error) {
+	if !strings.HasPrefix(mode, "service:") {
+		return mode, nil
+	}
+	name := strings.TrimPrefix(mode, "service:")
+	if _, err := p.GetService(name); err != nil {
+		return "", fmt.Errorf("service %q %s refers to undefined service %q", service.Name, kind, name)
 	}
-	if strings.HasPrefix(mode, "container:") {
-		panic("Not yet implemented")
+	return mode, nil
+}
+
+// getVolumesFrom resolves `volumes_from`, set as `service[:mode]` or `container:name[:mode]`, into the containers to
+// mount volumes from, in declaration order. Services are kept as is, to be resolved into an existing container of
+// that service once it gets created, as for network_mode
+func getVolumesFrom(p *types.Project, service types.ServiceConfig) ([]string, error) {
+	var volumesFrom []string
+	for _, v := range service.VolumesFrom {
+		parts := strings.Split(v, ":")
+		source := parts[0]
+		switch {
+		case source == "container" && len(parts) >= 2 && parts[1] != "":
+			source, parts = parts[1], parts[1:]
+		case source == "container" || source == "":
+			return nil, fmt.Errorf("service %q volumes_from %q doesn't set a service or container", service.Name, v)
+		default:
+			if _, err := p.GetService(source); err != nil {
+				return nil, fmt.Errorf("service %q volumes_from refers to undefined service %q", service.Name, source)
+			}
+		}
+		switch len(parts) {
+		case 1:
+		case 2:
+			mode := parts[1]
+			if mode != "ro" && mode != "rw" {
+				return nil, fmt.Errorf("service %q volumes_from %q has invalid mode %q: must be ro or rw", service.Name, v, mode)
+			}
+			source += ":" + mode
+		default:
+			return nil, fmt.Errorf("service %q volumes_from %q: expected service[:mode] or container:name[:mode]", service.Name, v)
+		}
+		volumesFrom = append(volumesFrom, source)
 	}
+	return volumesFrom, nil
+}
 
-	return container.NetworkMode(mode)
+// hasExplicitNetworks checks service declares networks, other than the default one it is implicitly attached to
+func hasExplicitNetworks(s types.ServiceConfig) bool {
+	for name, config := range s.Networks {
+		if name != "default" || config != nil {
+			return true
+		}
+	}
+	return false
 }
 
 func getNetworksForService(s types.ServiceConfig) map[string]*types.ServiceNetworkConfig {
@@ -591,57 +1806,157 @@ func getNetworksForService(s types.ServiceConfig) map[string]*types.ServiceNetwo
 	return map[string]*types.ServiceNetworkConfig{"default": nil}
 }
 
-func (s *local) ensureNetwork(ctx context.Context, n types.NetworkConfig) error {
-	_, err := s.containerService.apiClient.NetworkInspect(ctx, n.Name, moby.NetworkInspectOptions{})
-	if err != nil {
-		if errdefs.IsNotFound(err) {
-			createOpts := moby.NetworkCreate{
-				// TODO NameSpace Labels
-				Labels:     n.Labels,
-				Driver:     n.Driver,
-				Options:    n.DriverOpts,
-				Internal:   n.Internal,
-				Attachable: n.Attachable,
-			}
+// getNetworksByPriority returns the networks service is connected to, sorted by decreasing priority then by name
+func getNetworksByPriority(s types.ServiceConfig) []string {
+	networks := getNetworksForService(s)
+	var names []string
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		pi, pj := getNetworkPriority(networks[names[i]]), getNetworkPriority(networks[names[j]])
+		if pi != pj {
+			return pi > pj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
 
-			if n.Ipam.Driver != "" || len(n.Ipam.Config) > 0 {
-				createOpts.IPAM = &network.IPAM{}
-			}
+// getNetworkPriority reads the network `priority` set as an extension, as the compose model doesn't retain it
+func getNetworkPriority(config *types.ServiceNetworkConfig) int {
+	if config == nil {
+		return 0
+	}
+	switch priority := config.Extensions[extNetworkPriority].(type) {
+	case int:
+		return priority
+	case float64:
+		return int(priority)
+	}
+	return 0
+}
 
-			if n.Ipam.Driver != "" {
-				createOpts.IPAM.Driver = n.Ipam.Driver
-			}
+// applyCommandOverrides replaces command and entrypoint of services overridden at up time. Containers get
+// recreated as their configuration diverges, and are recreated again with the declared command on next plain up
+func applyCommandOverrides(project *types.Project, options compose.UpOptions) error {
+	for i, service := range project.Services {
+		if command, ok := options.Command[service.Name]; ok {
+			service.Command = command
+		}
+		if entrypoint, ok := options.Entrypoint[service.Name]; ok {
+			service.Entrypoint = entrypoint
+		}
+		project.Services[i] = service
+	}
+	for name := range options.Command {
+		if _, err := project.GetService(name); err != nil {
+			return fmt.Errorf("can't override command of service %q: no such service", name)
+		}
+	}
+	for name := range options.Entrypoint {
+		if _, err := project.GetService(name); err != nil {
+			return fmt.Errorf("can't override entrypoint of service %q: no such service", name)
+		}
+	}
+	return nil
+}
 
-			for _, ipamConfig := range n.Ipam.Config {
-				config := network.IPAMConfig{
-					Subnet: ipamConfig.Subnet,
-				}
-				createOpts.IPAM.Config = append(createOpts.IPAM.Config, config)
-			}
-			w := progress.ContextWriter(ctx)
-			w.Event(progress.Event{
-				ID:         fmt.Sprintf("Network %q", n.Name),
-				Status:     progress.Working,
-				StatusText: "Create",
-				Done:       false,
-			})
-			if _, err := s.containerService.apiClient.NetworkCreate(context.Background(), n.Name, createOpts); err != nil {
-				return errors.Wrapf(err, "failed to create network %s", n.Name)
-			}
-			w.Event(progress.Event{
-				ID:         fmt.Sprintf("Network %q", n.Name),
-				Status:     progress.Done,
-				StatusText: "Created",
-				Done:       true,
-			})
-			return nil
+func (s *local) ensureNetwork(ctx context.Context, projectName string, n types.NetworkConfig) error {
+	resource, err := s.containerService.apiClient.NetworkInspect(ctx, n.Name, moby.NetworkInspectOptions{})
+	if err == nil {
+		for _, drift := range getNetworkDrift(n, resource) {
+			logrus.Warnf("network %s exists but doesn't match configuration: %s. Remove it to get it recreated", n.Name, drift)
 		}
+		return nil
+	}
+	if !errdefs.IsNotFound(err) {
 		return err
 	}
+	if n.External.External {
+		return fmt.Errorf("network %s declared as external, but could not be found", n.Name)
+	}
+	w := progress.ContextWriter(ctx)
+	w.Event(progress.Event{
+		ID:         fmt.Sprintf("Network %q", n.Name),
+		Status:     progress.Working,
+		StatusText: "Create",
+		Done:       false,
+	})
+	if _, err := s.containerService.apiClient.NetworkCreate(ctx, n.Name, buildNetworkCreateOptions(projectName, n)); err != nil {
+		return errors.Wrapf(err, "failed to create network %s", n.Name)
+	}
+	w.Event(progress.Event{
+		ID:         fmt.Sprintf("Network %q", n.Name),
+		Status:     progress.Done,
+		StatusText: "Created",
+		Done:       true,
+	})
 	return nil
 }
 
-func (s *local) ensureVolume(ctx context.Context, volume types.VolumeConfig) error {
+func buildNetworkCreateOptions(projectName string, n types.NetworkConfig) moby.NetworkCreate {
+	labels := map[string]string{
+		projectLabel: projectName,
+	}
+	for k, v := range n.Labels {
+		labels[k] = v
+	}
+	createOpts := moby.NetworkCreate{
+		Labels:     labels,
+		Driver:     n.Driver,
+		Options:    n.DriverOpts,
+		Internal:   n.Internal,
+		Attachable: n.Attachable,
+	}
+
+	if n.Ipam.Driver != "" || len(n.Ipam.Config) > 0 {
+		createOpts.IPAM = &network.IPAM{
+			Driver: n.Ipam.Driver,
+		}
+	}
+	for _, ipamConfig := range n.Ipam.Config {
+		createOpts.IPAM.Config = append(createOpts.IPAM.Config, network.IPAMConfig{
+			Subnet:     ipamConfig.Subnet,
+			Gateway:    ipamConfig.Gateway,
+			IPRange:    ipamConfig.IPRange,
+			AuxAddress: ipamConfig.AuxiliaryAddresses,
+		})
+	}
+	return createOpts
+}
+
+// getNetworkDrift lists the differences between an existing network and the settings declared by compose file
+func getNetworkDrift(n types.NetworkConfig, resource moby.NetworkResource) []string {
+	var drift []string
+	if n.Driver != "" && n.Driver != resource.Driver {
+		drift = append(drift, fmt.Sprintf("driver is %q, expected %q", resource.Driver, n.Driver))
+	}
+	if n.Internal != resource.Internal {
+		drift = append(drift, fmt.Sprintf("internal is %t, expected %t", resource.Internal, n.Internal))
+	}
+	if n.Attachable != resource.Attachable {
+		drift = append(drift, fmt.Sprintf("attachable is %t, expected %t", resource.Attachable, n.Attachable))
+	}
+	for k, v := range n.DriverOpts {
+		if resource.Options[k] != v {
+			drift = append(drift, fmt.Sprintf("driver option %s is %q, expected %q", k, resource.Options[k], v))
+		}
+	}
+	for _, pool := range n.Ipam.Config {
+		found := false
+		for _, config := range resource.IPAM.Config {
+			found = found || config.Subnet == pool.Subnet
+		}
+		if pool.Subnet != "" && !found {
+			drift = append(drift, fmt.Sprintf("subnet %s is missing", pool.Subnet))
+		}
+	}
+	return drift
+}
+
+// ensureVolume creates volume when missing, labelled with the project so down can remove it
+func (s *local) ensureVolume(ctx context.Context, projectName string, volume types.VolumeConfig) error {
 	// TODO could identify volume by label vs name
 	_, err := s.volumeService.Inspect(ctx, volume.Name)
 	if err != nil {
@@ -653,17 +1968,28 @@ func (s *local) ensureVolume(ctx context.Context, volume types.VolumeConfig) err
 				StatusText: "Create",
 				Done:       false,
 			})
-			// TODO we miss support for driver_opts and labels
-			_, err := s.volumeService.Create(ctx, volume.Name, nil)
+			// TODO we miss support for driver_opts and labels declared by the volume
+			_, err := s.containerService.apiClient.VolumeCreate(ctx, volumetypes.VolumeCreateBody{
+				Driver: "local",
+				Labels: map[string]string{projectLabel: projectName},
+				Name:   volume.Name,
+			})
+			if err != nil {
+				w.Event(progress.Event{
+					ID:         fmt.Sprintf("Volume %q", volume.Name),
+					Status:     progress.Error,
+					StatusText: "Error",
+					Done:       true,
+				})
+				return errors.Wrapf(err, "failed to create volume %s", volume.Name)
+			}
 			w.Event(progress.Event{
 				ID:         fmt.Sprintf("Volume %q", volume.Name),
 				Status:     progress.Done,
 				StatusText: "Created",
 				Done:       true,
 			})
-			if err != nil {
-				return err
-			}
+			return nil
 		}
 		return err
 	}
@@ -19,12 +19,41 @@
 package local
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/compose-spec/compose-go/loader"
+	composetypes "github.com/compose-spec/compose-go/types"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/strslice"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
 
 	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/internal"
+	"github.com/docker/compose-cli/progress"
 )
 
 func TestContainersToStacks(t *testing.T) {
@@ -107,3 +136,1410 @@ func TestStacksMixedStatus(t *testing.T) {
 	assert.Equal(t, combinedStatus([]string{"running", "running", "running"}), "running(3)")
 	assert.Equal(t, combinedStatus([]string{"running", "exited", "running"}), "exited(1), running(2)")
 }
+
+func TestEnsureNetworkEmitsProgressEvents(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("NetworkInspect", mock.Anything, "myproject_back", types.NetworkInspectOptions{}).
+		Return(types.NetworkResource{}, errdefs.NotFound(errors.New("not found")))
+	apiClient.On("NetworkCreate", mock.Anything, "myproject_back", types.NetworkCreate{
+		Labels: map[string]string{projectLabel: "myproject"},
+		Driver: "bridge",
+		IPAM: &network.IPAM{
+			Config: []network.IPAMConfig{{Subnet: "10.1.0.0/16"}},
+		},
+	}).Return(types.NetworkCreateResponse{}, nil)
+	s := newMockBackend(apiClient)
+
+	w := &recordingWriter{}
+	ctx := progress.WithContextWriter(context.TODO(), w)
+	err := s.ensureNetwork(ctx, "myproject", composetypes.NetworkConfig{
+		Name:   "myproject_back",
+		Driver: "bridge",
+		Ipam: composetypes.IPAMConfig{
+			Config: []*composetypes.IPAMPool{{Subnet: "10.1.0.0/16"}},
+		},
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, w.statuses(`Network "myproject_back"`), []string{"Create", "Created"})
+	assert.Equal(t, w.events[0].Status, progress.Working)
+	assert.Equal(t, w.events[1].Status, progress.Done)
+	apiClient.AssertExpectations(t)
+}
+
+func TestRemoveNetworksEmitsProgressEvents(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("NetworkList", mock.Anything, types.NetworkListOptions{
+		Filters: filters.NewArgs(projectFilter("myproject")),
+	}).Return([]types.NetworkResource{{ID: "abc", Name: "myproject_default"}}, nil)
+	apiClient.On("NetworkRemove", mock.Anything, "abc").Return(nil)
+	s := newMockBackend(apiClient)
+
+	w := &recordingWriter{}
+	ctx := progress.WithContextWriter(context.TODO(), w)
+	err := s.removeNetworks(ctx, "myproject")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, w.statuses(`Network "myproject_default"`), []string{"Remove", "Removed"})
+	apiClient.AssertExpectations(t)
+}
+
+func TestEnsureVolumeEmitsProgressEvents(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("VolumeInspect", mock.Anything, "myproject_data").Return(types.Volume{}, errdefs.NotFound(errors.New("no such volume")))
+	apiClient.On("VolumeCreate", mock.Anything, volume.VolumeCreateBody{
+		Driver: "local",
+		Labels: map[string]string{projectLabel: "myproject"},
+		Name:   "myproject_data",
+	}).Return(types.Volume{Name: "myproject_data"}, nil).Once()
+	apiClient.On("VolumeCreate", mock.Anything, mock.Anything).Return(types.Volume{}, errors.New("driver failure")).Once()
+	s := newMockBackend(apiClient)
+
+	w := &recordingWriter{}
+	ctx := progress.WithContextWriter(context.TODO(), w)
+	err := s.ensureVolume(ctx, "myproject", composetypes.VolumeConfig{Name: "myproject_data"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, w.statuses(`Volume "myproject_data"`), []string{"Create", "Created"})
+
+	w = &recordingWriter{}
+	ctx = progress.WithContextWriter(context.TODO(), w)
+	err = s.ensureVolume(ctx, "myproject", composetypes.VolumeConfig{Name: "myproject_data"})
+	assert.Error(t, err, "failed to create volume myproject_data: driver failure")
+	assert.DeepEqual(t, w.statuses(`Volume "myproject_data"`), []string{"Create", "Error"})
+	assert.Equal(t, w.events[1].Status, progress.Error)
+	apiClient.AssertExpectations(t)
+}
+
+func TestDownRemovesVolumes(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{}, nil)
+	apiClient.On("NetworkList", mock.Anything, mock.Anything).Return([]types.NetworkResource{}, nil)
+	apiClient.On("VolumeList", mock.Anything, filters.NewArgs(projectFilter("myproject"))).Return(volume.VolumeListOKBody{
+		Volumes: []*types.Volume{
+			{Name: "myproject_data", Labels: map[string]string{projectLabel: "myproject"}},
+			{Name: "myproject_logs", Labels: map[string]string{projectLabel: "myproject"}},
+		},
+	}, nil)
+	apiClient.On("VolumeRemove", mock.Anything, "myproject_data", false).Return(nil).Once()
+	apiClient.On("VolumeRemove", mock.Anything, "myproject_logs", false).Return(errors.New("volume is in use")).Once()
+	s := newMockBackend(apiClient)
+
+	w := &recordingWriter{}
+	ctx := progress.WithContextWriter(context.TODO(), w)
+	err := s.Down(ctx, "myproject", compose.DownOptions{Volumes: true})
+	assert.Error(t, err, "failed to remove volume myproject_logs: volume is in use")
+	assert.DeepEqual(t, w.statuses(`Volume "myproject_data"`), []string{"Remove", "Removed"})
+	assert.DeepEqual(t, w.statuses(`Volume "myproject_logs"`), []string{"Remove", "Error"})
+	apiClient.AssertExpectations(t)
+
+	// volumes are kept unless asked for
+	apiClient = &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{}, nil)
+	apiClient.On("NetworkList", mock.Anything, mock.Anything).Return([]types.NetworkResource{}, nil)
+	s = newMockBackend(apiClient)
+	err = s.Down(context.TODO(), "myproject", compose.DownOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertNotCalled(t, "VolumeList", mock.Anything, mock.Anything)
+}
+
+func TestReplicaLabels(t *testing.T) {
+	project := &composetypes.Project{
+		Name: "myproject",
+		Services: []composetypes.ServiceConfig{
+			{
+				Name:  "web",
+				Image: "nginx",
+			},
+		},
+	}
+	config, _, _, err := getContainerCreateOptions(project, project.Services[0], 2, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, config.Labels[containerNumberLabel], "2")
+	assert.Equal(t, config.Labels[replicaLabel], "web_2")
+
+	service, number, err := compose.ParseReplicaKey(config.Labels[replicaLabel])
+	assert.NilError(t, err)
+	assert.Equal(t, service, "web")
+	assert.Equal(t, number, 2)
+}
+
+func TestDeployResources(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:     "web",
+		MemLimit: 256 * 1024 * 1024,
+		PidLimit: 100,
+		Ulimits: map[string]*composetypes.UlimitsConfig{
+			"nproc":  {Single: 65535},
+			"nofile": {Soft: 20000, Hard: 40000},
+		},
+		Deploy: &composetypes.DeployConfig{
+			Resources: composetypes.Resources{
+				Limits: &composetypes.Resource{
+					NanoCPUs:    "0.5",
+					MemoryBytes: 512 * 1024 * 1024,
+				},
+				Reservations: &composetypes.Resource{
+					MemoryBytes: 128 * 1024 * 1024,
+				},
+			},
+		},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	pids := int64(100)
+	assert.DeepEqual(t, hostConfig.Resources, container.Resources{
+		NanoCPUs:          500000000,
+		Memory:            512 * 1024 * 1024,
+		MemoryReservation: 128 * 1024 * 1024,
+		PidsLimit:         &pids,
+		Ulimits: []*units.Ulimit{
+			{Name: "nofile", Soft: 20000, Hard: 40000},
+			{Name: "nproc", Soft: 65535, Hard: 65535},
+		},
+	})
+}
+
+func TestLegacyResources(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:           "web",
+		CPUS:           0.3,
+		MemLimit:       256 * 1024 * 1024,
+		MemReservation: 64 * 1024 * 1024,
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, hostConfig.Resources.NanoCPUs, int64(300000000))
+	assert.Equal(t, hostConfig.Resources.Memory, int64(256*1024*1024))
+	assert.Equal(t, hostConfig.Resources.MemoryReservation, int64(64*1024*1024))
+}
+
+func TestInvalidCPUs(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name: "web",
+		Deploy: &composetypes.DeployConfig{
+			Resources: composetypes.Resources{
+				Limits: &composetypes.Resource{NanoCPUs: "half"},
+			},
+		},
+	}
+	_, _, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.ErrorContains(t, err, `invalid cpus value "half"`)
+}
+
+func TestPsReportsContainersHealth(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, types.ContainerListOptions{
+		Filters: filters.NewArgs(projectFilter("myproject")),
+	}).Return([]types.Container{
+		{ID: "c1", Names: []string{"/myproject_web_1"}, State: "running", Labels: map[string]string{serviceLabel: "web"}},
+		{ID: "c2", Names: []string{"/myproject_db_1"}, State: "running", Labels: map[string]string{serviceLabel: "db"}},
+	}, nil)
+	apiClient.On("ContainerInspect", mock.Anything, "c1").Return(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{State: &types.ContainerState{}},
+	}, nil)
+	apiClient.On("ContainerInspect", mock.Anything, "c2").Return(withHealth("healthy"), nil)
+	s := newMockBackend(apiClient)
+
+	services, err := s.Ps(context.TODO(), "myproject")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, services[0].Containers, []compose.ContainerSummary{
+		{ID: "c2", Name: "myproject_db_1", State: "running", Health: compose.HealthHealthy},
+	})
+	assert.DeepEqual(t, services[1].Containers, []compose.ContainerSummary{
+		{ID: "c1", Name: "myproject_web_1", State: "running", Health: compose.HealthNone},
+	})
+}
+
+func TestPsReportsContainersRestarts(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{
+		{ID: "c1", Names: []string{"/myproject_web_1"}, State: "running", Labels: map[string]string{serviceLabel: "web"}},
+	}, nil)
+	apiClient.On("ContainerInspect", mock.Anything, "c1").Return(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			Created:      "2020-11-20T10:00:00.123456789Z",
+			RestartCount: 4,
+			State:        &types.ContainerState{StartedAt: "2020-11-20T10:05:00Z"},
+		},
+	}, nil)
+	s := newMockBackend(apiClient)
+
+	services, err := s.Ps(context.TODO(), "myproject")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, services[0].Containers, []compose.ContainerSummary{
+		{
+			ID:           "c1",
+			Name:         "myproject_web_1",
+			State:        "running",
+			Health:       compose.HealthNone,
+			Created:      time.Date(2020, 11, 20, 10, 0, 0, 123456789, time.UTC),
+			StartedAt:    time.Date(2020, 11, 20, 10, 5, 0, 0, time.UTC),
+			RestartCount: 4,
+		},
+	})
+}
+
+func TestPsReportsPublishedPorts(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{
+		{ID: "c1", Names: []string{"/myproject_web_1"}, State: "running", Labels: map[string]string{serviceLabel: "web"}},
+	}, nil)
+	apiClient.On("ContainerInspect", mock.Anything, "c1").Return(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{State: &types.ContainerState{}},
+		NetworkSettings: &types.NetworkSettings{NetworkSettingsBase: types.NetworkSettingsBase{
+			Ports: nat.PortMap{
+				"80/tcp":  {{HostIP: "0.0.0.0", HostPort: "8080"}},
+				"443/tcp": nil,
+			},
+		}},
+	}, nil)
+	s := newMockBackend(apiClient)
+
+	services, err := s.Ps(context.TODO(), "myproject")
+	assert.NilError(t, err)
+	expected := []compose.PortPublisher{{URL: "0.0.0.0", TargetPort: 80, PublishedPort: 8080, Protocol: "tcp"}}
+	assert.DeepEqual(t, services[0].Containers[0].Publishers, expected)
+	assert.DeepEqual(t, services[0].Publishers, expected)
+	assert.DeepEqual(t, services[0].Ports, []string{"0.0.0.0:8080->80/tcp"})
+}
+
+func TestUpRollbackOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, "nginx").Return(types.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{}, nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+		Return(container.ContainerCreateCreatedBody{ID: "c1"}, nil).
+		Run(func(args mock.Arguments) {
+			// user hits Ctrl-C once container has been created
+			cancel()
+		})
+	apiClient.On("ContainerStart", mock.Anything, "c1", types.ContainerStartOptions{}).Return(context.Canceled)
+	apiClient.On("ContainerRemove", mock.Anything, "c1", types.ContainerRemoveOptions{Force: true}).Return(nil)
+	apiClient.On("ServerVersion", mock.Anything).Return(types.Version{APIVersion: "1.41"}, nil)
+	s := newMockBackend(apiClient)
+
+	project := &composetypes.Project{
+		Name: "myproject",
+		Services: []composetypes.ServiceConfig{
+			{
+				Name:  "web",
+				Image: "nginx",
+			},
+		},
+	}
+	err := s.Up(ctx, project, compose.UpOptions{Rollback: true})
+	assert.ErrorContains(t, err, "context canceled")
+	apiClient.AssertExpectations(t)
+}
+
+// upWithFailure runs up for a project which db service is recreated, web is created, and cache fails to be created
+func upWithFailure(t *testing.T, scope string, expect func(apiClient *mockAPIClient)) *mockAPIClient {
+	project := &composetypes.Project{
+		Name: "myproject",
+		Services: []composetypes.ServiceConfig{
+			{Name: "db", Image: "postgres"},
+			{Name: "web", Image: "nginx"},
+			{Name: "cache", Image: "redis"},
+		},
+	}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ServerVersion", mock.Anything).Return(types.Version{APIVersion: "1.41"}, nil)
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(types.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.MatchedBy(func(options types.ContainerListOptions) bool {
+		return options.Filters.ExactMatch("label", fmt.Sprintf("%s=db", serviceLabel))
+	})).Return([]types.Container{{
+		ID:     "123456789012345",
+		Names:  []string{"/myproject_db_1"},
+		Image:  "postgres",
+		State:  "running",
+		Labels: map[string]string{containerNumberLabel: "1", configHashLabel: "outdated"},
+	}}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{}, nil)
+	apiClient.On("ContainerStop", mock.Anything, "123456789012345", mock.Anything).Return(nil)
+	apiClient.On("ContainerRename", mock.Anything, "123456789012345", "123456789012_myproject_db_1").Return(nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_db_1").
+		Return(container.ContainerCreateCreatedBody{ID: "db2"}, nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+		Return(container.ContainerCreateCreatedBody{ID: "web1"}, nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_cache_1").
+		Return(container.ContainerCreateCreatedBody{}, errors.New("no space left on device"))
+	apiClient.On("ContainerStart", mock.Anything, mock.Anything, types.ContainerStartOptions{}).Return(nil)
+	apiClient.On("ContainerRemove", mock.Anything, "web1", types.ContainerRemoveOptions{Force: true}).Return(nil).Once()
+	expect(apiClient)
+	s := newMockBackend(apiClient)
+
+	err := s.Up(context.TODO(), project, compose.UpOptions{Rollback: true, RollbackScope: scope, ContinueOnError: true})
+	assert.ErrorContains(t, err, "no space left on device")
+	apiClient.AssertExpectations(t)
+	return apiClient
+}
+
+func TestUpTimeout(t *testing.T) {
+	project := &composetypes.Project{
+		Name: "myproject",
+		Services: []composetypes.ServiceConfig{
+			{Name: "db", Image: "postgres"},
+			{Name: "web", Image: "nginx", DependsOn: composetypes.DependsOnConfig{"db": {}}},
+		},
+	}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ServerVersion", mock.Anything).Return(types.Version{APIVersion: "1.41"}, nil)
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(types.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{}, nil)
+	// daemon is stuck creating db container until the request is cancelled
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_db_1").
+		Run(func(args mock.Arguments) {
+			<-args.Get(0).(context.Context).Done()
+		}).
+		Return(container.ContainerCreateCreatedBody{}, context.DeadlineExceeded)
+	s := newMockBackend(apiClient)
+
+	var transitions []string
+	err := s.Up(context.TODO(), project, compose.UpOptions{
+		Timeout: 100 * time.Millisecond,
+		Listener: func(transition compose.ServiceTransition) {
+			transitions = append(transitions, transition.Service+":"+transition.State)
+		},
+	})
+	assert.Error(t, err, "services did not converge within 100ms (db, web): context deadline exceeded")
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+	// listener set by caller is still notified
+	assert.DeepEqual(t, transitions[:2], []string{"db:pending", "web:pending"})
+	apiClient.AssertNotCalled(t, "ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1")
+}
+
+func TestUpTimeoutReportsServicesNotStarted(t *testing.T) {
+	project := &composetypes.Project{
+		Name: "myproject",
+		Services: []composetypes.ServiceConfig{
+			{Name: "db", Image: "postgres"},
+			{Name: "web", Image: "nginx", DependsOn: composetypes.DependsOnConfig{"db": {}}},
+			{Name: "admin", Image: "adminer"},
+		},
+	}
+	apiClient := &mockAPIClient{}
+	// daemon doesn't answer before any service got to converge
+	apiClient.On("ServerVersion", mock.Anything).
+		Run(func(args mock.Arguments) {
+			<-args.Get(0).(context.Context).Done()
+		}).
+		Return(types.Version{}, context.DeadlineExceeded)
+	s := newMockBackend(apiClient)
+
+	err := s.Up(context.TODO(), project, compose.UpOptions{Services: []string{"web"}, Timeout: 100 * time.Millisecond})
+	assert.Error(t, err, "services did not converge within 100ms (db, web): context deadline exceeded")
+}
+
+func TestUpRollbackCreatedContainers(t *testing.T) {
+	apiClient := upWithFailure(t, compose.RollbackCreated, func(apiClient *mockAPIClient) {
+		// previous container is removed as soon as db got recreated
+		apiClient.On("ContainerRemove", mock.Anything, "123456789012345", types.ContainerRemoveOptions{}).Return(nil).Once()
+	})
+	// recreated db is left running
+	apiClient.AssertNotCalled(t, "ContainerRemove", mock.Anything, "db2", mock.Anything)
+	apiClient.AssertNotCalled(t, "ContainerRemove", mock.Anything, "myproject_db_1", mock.Anything)
+}
+
+func TestUpRollbackAll(t *testing.T) {
+	apiClient := upWithFailure(t, compose.RollbackAll, func(apiClient *mockAPIClient) {
+		// recreated db is replaced by the previous container
+		apiClient.On("ContainerRemove", mock.Anything, "myproject_db_1", types.ContainerRemoveOptions{Force: true}).Return(nil).Once()
+		apiClient.On("ContainerRename", mock.Anything, "123456789012345", "myproject_db_1").Return(nil).Once()
+	})
+	apiClient.AssertCalled(t, "ContainerStart", mock.Anything, "123456789012345", types.ContainerStartOptions{})
+	apiClient.AssertNotCalled(t, "ContainerRemove", mock.Anything, "123456789012345", mock.Anything)
+}
+
+func TestRemoveReplacedContainersOnSuccess(t *testing.T) {
+	created := &createdContainers{}
+	ctx := withCreatedContainers(context.TODO(), created)
+	options := compose.UpOptions{Rollback: true, RollbackScope: compose.RollbackAll}
+	assert.Assert(t, trackReplacedContainer(ctx, "myproject_db_1", types.Container{ID: "123456789012345"}, options))
+	assert.Assert(t, !trackReplacedContainer(ctx, "myproject_db_1", types.Container{ID: "123456789012345"}, compose.UpOptions{Rollback: true}))
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerRemove", mock.Anything, "123456789012345", types.ContainerRemoveOptions{}).Return(nil).Once()
+	s := newMockBackend(apiClient)
+	err := s.removeReplacedContainers(ctx, created, options)
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+}
+
+func TestCreatedContainersTracking(t *testing.T) {
+	created := &createdContainers{}
+	ctx := withCreatedContainers(context.TODO(), created)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			trackCreatedContainer(ctx, "c", "myproject_web_1")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, len(created.list()), 10)
+
+	// tracking is a no-op unless enabled
+	trackCreatedContainer(context.TODO(), "c", "myproject_web_1")
+}
+
+func TestDownInReverseDependencyOrder(t *testing.T) {
+	serviceContainer := func(name string, service string, dependencies string) types.Container {
+		return types.Container{
+			ID:     name,
+			Names:  []string{"/" + name},
+			Labels: map[string]string{serviceLabel: service, dependenciesLabel: dependencies},
+		}
+	}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, types.ContainerListOptions{
+		Filters: filters.NewArgs(projectFilter("myproject")),
+		All:     true,
+	}).Return([]types.Container{
+		serviceContainer("myproject_db_1", "db", ""),
+		serviceContainer("myproject_web_1", "web", "api"),
+		serviceContainer("myproject_api_1", "api", "db"),
+		serviceContainer("myproject_web_2", "web", "api"),
+	}, nil)
+
+	var (
+		mtx     sync.Mutex
+		stopped []string
+	)
+	timeout := 5 * time.Second
+	apiClient.On("ContainerStop", mock.Anything, mock.Anything, &timeout).Run(func(args mock.Arguments) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		stopped = append(stopped, args.String(1))
+	}).Return(nil)
+	apiClient.On("ContainerRemove", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	apiClient.On("NetworkList", mock.Anything, mock.Anything).Return([]types.NetworkResource{}, nil)
+	s := newMockBackend(apiClient)
+
+	err := s.Down(context.TODO(), "myproject", compose.DownOptions{Timeout: &timeout})
+	assert.NilError(t, err)
+	assert.Equal(t, len(stopped), 4)
+	assert.DeepEqual(t, stopped[2:], []string{"myproject_api_1", "myproject_db_1"})
+}
+
+func TestExtraHosts(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:       "web",
+		ExtraHosts: composetypes.HostsList{"somehost:162.242.195.82", "otherhost:::1", "ipv6host:[fe80::1]"},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hostConfig.ExtraHosts, []string{"somehost:162.242.195.82", "otherhost:::1", "ipv6host:fe80::1"})
+
+	service.ExtraHosts = composetypes.HostsList{"somehost:not-an-ip"}
+	_, _, _, err = getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.ErrorContains(t, err, `invalid extra host "somehost:not-an-ip"`)
+
+	service.ExtraHosts = composetypes.HostsList{"162.242.195.82"}
+	_, _, _, err = getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.ErrorContains(t, err, `invalid extra host "162.242.195.82": expected host:ip`)
+}
+
+func TestTmpfs(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:  "web",
+		Tmpfs: composetypes.StringList{"/run", "/tmp:rw,noexec,size=64m,mode=1777"},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hostConfig.Tmpfs, map[string]string{
+		"/run": "",
+		"/tmp": "rw,noexec,size=64m,mode=1777",
+	})
+
+	for entry, message := range map[string]string{
+		"run":             "mount path must be absolute",
+		"/run:size=big":   "invalid size",
+		"/run:mode=rwx":   "mode must be an octal value",
+		"/run:rw,,noexec": "empty option",
+	} {
+		_, err = toTmpfs(composetypes.StringList{entry})
+		assert.ErrorContains(t, err, message)
+	}
+}
+
+func TestReadOnlyAndSecurityOpts(t *testing.T) {
+	dir := fs.NewDir(t, "seccomp", fs.WithFile("profile.json", `{
+  "defaultAction": "SCMP_ACT_ERRNO"
+}`))
+	defer dir.Remove()
+	project := &composetypes.Project{Name: "myproject", WorkingDir: dir.Path()}
+	service := composetypes.ServiceConfig{
+		Name:        "web",
+		ReadOnly:    true,
+		SecurityOpt: []string{"no-new-privileges", "apparmor:unconfined", "seccomp=profile.json"},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(project, service, 1, nil)
+	assert.NilError(t, err)
+	assert.Check(t, hostConfig.ReadonlyRootfs)
+	assert.DeepEqual(t, hostConfig.SecurityOpt, []string{
+		"no-new-privileges",
+		"apparmor=unconfined",
+		`seccomp={"defaultAction":"SCMP_ACT_ERRNO"}`,
+	})
+
+	for opt, message := range map[string]string{
+		"apparmor":        "expected key=value",
+		"selinux=disable": `unsupported option "selinux"`,
+		"seccomp=missing": "failed to load seccomp profile",
+	} {
+		_, err = toSecurityOpts(project, []string{opt})
+		assert.ErrorContains(t, err, message)
+	}
+}
+
+func TestUpWaitsForHealthyServices(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:        "web",
+		Image:       "nginx",
+		HealthCheck: &composetypes.HealthCheckConfig{Test: []string{"CMD", "true"}},
+	}
+	hash, err := jsonHash(service)
+	assert.NilError(t, err)
+	project := &composetypes.Project{Name: "myproject", Services: []composetypes.ServiceConfig{service}}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, "nginx").Return(types.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{{
+		ID:     "c1",
+		Names:  []string{"/myproject_web_1"},
+		Image:  "nginx",
+		State:  "running",
+		Labels: map[string]string{containerNumberLabel: "1", configHashLabel: hash},
+	}}, nil)
+	apiClient.On("ContainerInspect", mock.Anything, "c1").Return(withHealth("starting"), nil).Once()
+	apiClient.On("ContainerInspect", mock.Anything, "c1").Return(withHealth("healthy"), nil).Once()
+	apiClient.On("ServerVersion", mock.Anything).Return(types.Version{APIVersion: "1.41"}, nil)
+	s := newMockBackend(apiClient)
+
+	w := &recordingWriter{}
+	err = s.Up(progress.WithContextWriter(context.TODO(), w), project, compose.UpOptions{Wait: true})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	assert.DeepEqual(t, w.statuses(`Service "web"`), []string{"Running", "Waiting", "Healthy"})
+}
+
+func TestUpNotifiesServiceTransitions(t *testing.T) {
+	project := &composetypes.Project{Name: "myproject", Services: []composetypes.ServiceConfig{
+		{Name: "db", Image: "postgres"},
+		{Name: "web", Image: "nginx", HealthCheck: &composetypes.HealthCheckConfig{Test: []string{"CMD", "true"}}},
+	}}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ServerVersion", mock.Anything).Return(types.Version{APIVersion: "1.41"}, nil)
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(types.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{}, nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_db_1").
+		Return(container.ContainerCreateCreatedBody{ID: "db1"}, nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+		Return(container.ContainerCreateCreatedBody{ID: "web1"}, nil)
+	apiClient.On("ContainerStart", mock.Anything, mock.Anything, types.ContainerStartOptions{}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	var mtx sync.Mutex
+	transitions := map[string][]string{}
+	err := s.Up(context.TODO(), project, compose.UpOptions{
+		Wait: true,
+		Listener: func(transition compose.ServiceTransition) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			transitions[transition.Service] = append(transitions[transition.Service], transition.State)
+		},
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, transitions, map[string][]string{
+		"db":  {compose.ServicePending, compose.ServiceCreating, compose.ServiceStarted},
+		"web": {compose.ServicePending, compose.ServiceCreating, compose.ServiceStarted, compose.ServiceHealthy},
+	})
+}
+
+func TestUpContinueOnError(t *testing.T) {
+	project := &composetypes.Project{Name: "myproject", Services: []composetypes.ServiceConfig{
+		{Name: "web", Image: "nginx"},
+		{Name: "db", Image: "postgres"},
+		{Name: "cache", Image: "redis"},
+	}}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ServerVersion", mock.Anything).Return(types.Version{APIVersion: "1.41"}, nil)
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(types.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{}, nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_db_1").
+		Return(container.ContainerCreateCreatedBody{}, errors.New("no space left on device"))
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+		Return(container.ContainerCreateCreatedBody{ID: "web1"}, nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_cache_1").
+		Return(container.ContainerCreateCreatedBody{ID: "cache1"}, nil)
+	apiClient.On("ContainerStart", mock.Anything, "web1", types.ContainerStartOptions{}).Return(nil).Once()
+	apiClient.On("ContainerStart", mock.Anything, "cache1", types.ContainerStartOptions{}).Return(nil).Once()
+	s := newMockBackend(apiClient)
+
+	var failed []compose.ServiceTransition
+	w := &recordingWriter{}
+	err := s.Up(progress.WithContextWriter(context.TODO(), w), project, compose.UpOptions{
+		ContinueOnError: true,
+		Listener: func(transition compose.ServiceTransition) {
+			if transition.State == compose.ServiceFailed {
+				failed = append(failed, transition)
+			}
+		},
+	})
+	assert.ErrorContains(t, err, `service "db": no space left on device`)
+	assert.Equal(t, len(failed), 1)
+	assert.Equal(t, failed[0].Service, "db")
+	assert.ErrorContains(t, failed[0].Error, "no space left on device")
+	apiClient.AssertExpectations(t)
+	assert.DeepEqual(t, w.statuses(`Service "db"`), []string{"Create", "no space left on device"})
+	assert.DeepEqual(t, w.statuses(`Service "web"`), []string{"Create", "Created"})
+	assert.DeepEqual(t, w.statuses(`Service "cache"`), []string{"Create", "Created"})
+}
+
+func TestContinueOnErrorSkipsDependents(t *testing.T) {
+	failures := &serviceFailures{}
+	failures.run(context.TODO(), composetypes.ServiceConfig{Name: "db"}, func() error {
+		return errors.New("failed")
+	})
+	called := false
+	failures.run(context.TODO(), composetypes.ServiceConfig{
+		Name:      "web",
+		DependsOn: composetypes.DependsOnConfig{"db": {Condition: composetypes.ServiceConditionStarted}},
+	}, func() error {
+		called = true
+		return nil
+	})
+	assert.Assert(t, !called)
+	assert.ErrorContains(t, failures.errorOrNil(), `service "web": skipped as dependency "db" failed`)
+}
+
+func TestUpWaitTimeout(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:        "web",
+		Image:       "nginx",
+		HealthCheck: &composetypes.HealthCheckConfig{Test: []string{"CMD", "true"}},
+	}
+	hash, err := jsonHash(service)
+	assert.NilError(t, err)
+	project := &composetypes.Project{Name: "myproject", Services: []composetypes.ServiceConfig{service}}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, "nginx").Return(types.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{{
+		ID:     "c1",
+		Names:  []string{"/myproject_web_1"},
+		Image:  "nginx",
+		State:  "running",
+		Labels: map[string]string{containerNumberLabel: "1", configHashLabel: hash},
+	}}, nil)
+	apiClient.On("ContainerInspect", mock.Anything, "c1").Return(withHealth("unhealthy"), nil)
+	apiClient.On("ServerVersion", mock.Anything).Return(types.Version{APIVersion: "1.41"}, nil)
+	s := newMockBackend(apiClient)
+
+	err = s.Up(context.TODO(), project, compose.UpOptions{Wait: true, WaitTimeout: 700 * time.Millisecond})
+	assert.Error(t, err, `service "web" is not healthy after 700ms`)
+}
+
+func TestLogsPrefixedByReplica(t *testing.T) {
+	stream := func(lines ...string) io.ReadCloser {
+		var b bytes.Buffer
+		stdout := stdcopy.NewStdWriter(&b, stdcopy.Stdout)
+		for _, line := range lines {
+			_, err := stdout.Write([]byte(line + "\n"))
+			assert.NilError(t, err)
+		}
+		return ioutil.NopCloser(&b)
+	}
+	replica := func(id, service string) types.Container {
+		return types.Container{
+			ID:     id,
+			Labels: map[string]string{serviceLabel: service, containerNumberLabel: "1"},
+		}
+	}
+	logsOptions := types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Follow: true}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, types.ContainerListOptions{
+		Filters: filters.NewArgs(projectFilter("myproject")),
+	}).Return([]types.Container{replica("c1", "web"), replica("c2", "db")}, nil)
+	apiClient.On("ContainerInspect", mock.Anything, mock.Anything).Return(types.ContainerJSON{Config: &container.Config{}}, nil)
+	apiClient.On("ContainerLogs", mock.Anything, "c1", logsOptions).Return(stream("listening on :80", "GET /"), nil)
+	apiClient.On("ContainerLogs", mock.Anything, "c2", logsOptions).Return(stream("ready for connections"), nil)
+	s := newMockBackend(apiClient)
+
+	var out bytes.Buffer
+	err := s.Logs(context.TODO(), "myproject", &out, compose.LogOptions{})
+	assert.NilError(t, err)
+
+	ansi := regexp.MustCompile("\033\\[[0-9;]*m")
+	prefix := regexp.MustCompile(`^(\S+)\s+\| `)
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(ansi.ReplaceAllString(out.String(), "")), "\n") {
+		lines = append(lines, prefix.ReplaceAllString(line, "$1 | "))
+	}
+	sort.Strings(lines)
+	assert.DeepEqual(t, lines, []string{
+		"db_1 | ready for connections",
+		"web_1 | GET /",
+		"web_1 | listening on :80",
+	})
+}
+
+func TestLogsOptions(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{{
+		ID:     "c1",
+		Labels: map[string]string{serviceLabel: "web", containerNumberLabel: "1"},
+	}}, nil)
+	apiClient.On("ContainerInspect", mock.Anything, "c1").Return(types.ContainerJSON{Config: &container.Config{Tty: true}}, nil)
+	apiClient.On("ContainerLogs", mock.Anything, "c1", types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Timestamps: true,
+		Since:      "10m",
+	}).Return(ioutil.NopCloser(strings.NewReader("2020-11-20T10:00:00.000000000Z listening on :80\n")), nil)
+	s := newMockBackend(apiClient)
+
+	var out bytes.Buffer
+	err := s.Logs(context.TODO(), "myproject", &out, compose.LogOptions{Timestamps: true, Since: "10m"})
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(out.String(), "2020-11-20T10:00:00.000000000Z listening on :80"))
+	apiClient.AssertExpectations(t)
+
+	err = s.Logs(context.TODO(), "myproject", &out, compose.LogOptions{Until: "yesterday"})
+	assert.ErrorContains(t, err, `invalid until value "yesterday"`)
+}
+
+func TestLogsSelectedServices(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{
+		{ID: "c1", Labels: map[string]string{serviceLabel: "web", containerNumberLabel: "1"}},
+		{ID: "c2", Labels: map[string]string{serviceLabel: "db", containerNumberLabel: "1"}},
+	}, nil)
+	apiClient.On("ContainerInspect", mock.Anything, "c1").Return(types.ContainerJSON{Config: &container.Config{Tty: true}}, nil)
+	apiClient.On("ContainerLogs", mock.Anything, "c1", mock.Anything).
+		Return(ioutil.NopCloser(strings.NewReader("listening on :80\n")), nil).Once()
+	s := newMockBackend(apiClient)
+
+	var out bytes.Buffer
+	err := s.Logs(context.TODO(), "myproject", &out, compose.LogOptions{Services: []string{"web"}})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	apiClient.AssertNotCalled(t, "ContainerInspect", mock.Anything, "c2")
+	apiClient.AssertNotCalled(t, "ContainerLogs", mock.Anything, "c2", mock.Anything)
+}
+
+func TestNetworkMode(t *testing.T) {
+	project := &composetypes.Project{
+		Name: "myproject",
+		Services: []composetypes.ServiceConfig{
+			{Name: "proxy", Image: "nginx", NetworkMode: "host", Networks: map[string]*composetypes.ServiceNetworkConfig{"default": nil}},
+			{Name: "sidecar", Image: "envoy", NetworkMode: "service:app", Networks: map[string]*composetypes.ServiceNetworkConfig{"default": nil}},
+			{Name: "app", Image: "myapp"},
+		},
+		Networks: composetypes.Networks{"default": composetypes.NetworkConfig{Name: "myproject_default"}},
+	}
+
+	_, hostConfig, networkConfig, err := getContainerCreateOptions(project, project.Services[0], 1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, hostConfig.NetworkMode, container.NetworkMode("host"))
+	assert.Equal(t, len(networkConfig.EndpointsConfig), 0)
+
+	_, hostConfig, networkConfig, err = getContainerCreateOptions(project, project.Services[1], 1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, hostConfig.NetworkMode, container.NetworkMode("service:app"))
+	assert.Equal(t, len(networkConfig.EndpointsConfig), 0)
+
+	_, err = getNetworkMode(project, composetypes.ServiceConfig{Name: "sidecar", NetworkMode: "service:db"})
+	assert.Error(t, err, `service "sidecar" network_mode refers to undefined service "db"`)
+
+	_, err = getNetworkMode(project, composetypes.ServiceConfig{
+		Name:        "proxy",
+		NetworkMode: "host",
+		Networks:    map[string]*composetypes.ServiceNetworkConfig{"front": nil},
+	})
+	assert.Error(t, err, `service "proxy" declares network_mode "host", which can't be combined with networks`)
+}
+
+func TestEnsureNetworkWithDeclaredSettings(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("NetworkInspect", mock.Anything, "myproject_back", types.NetworkInspectOptions{}).
+		Return(types.NetworkResource{}, errdefs.NotFound(errors.New("not found")))
+	apiClient.On("NetworkCreate", mock.Anything, "myproject_back", types.NetworkCreate{
+		Labels:     map[string]string{projectLabel: "myproject", "com.example.tier": "back"},
+		Driver:     "bridge",
+		Options:    map[string]string{"com.docker.network.bridge.enable_icc": "false"},
+		Internal:   true,
+		Attachable: true,
+		IPAM: &network.IPAM{
+			Config: []network.IPAMConfig{{Subnet: "172.28.0.0/16", Gateway: "172.28.5.254", IPRange: "172.28.5.0/24"}},
+		},
+	}).Return(types.NetworkCreateResponse{}, nil)
+	s := newMockBackend(apiClient)
+
+	err := s.ensureNetwork(context.TODO(), "myproject", composetypes.NetworkConfig{
+		Name:       "myproject_back",
+		Driver:     "bridge",
+		DriverOpts: map[string]string{"com.docker.network.bridge.enable_icc": "false"},
+		Internal:   true,
+		Attachable: true,
+		Labels:     composetypes.Labels{"com.example.tier": "back"},
+		Ipam: composetypes.IPAMConfig{
+			Config: []*composetypes.IPAMPool{{Subnet: "172.28.0.0/16", Gateway: "172.28.5.254", IPRange: "172.28.5.0/24"}},
+		},
+	})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+}
+
+func TestEnsureExistingNetworkWarnsOnDrift(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("NetworkInspect", mock.Anything, "myproject_back", types.NetworkInspectOptions{}).
+		Return(types.NetworkResource{
+			Name:   "myproject_back",
+			Driver: "bridge",
+			IPAM:   network.IPAM{Config: []network.IPAMConfig{{Subnet: "172.18.0.0/16"}}},
+		}, nil)
+	s := newMockBackend(apiClient)
+
+	var out bytes.Buffer
+	logrus.SetOutput(&out)
+	defer logrus.SetOutput(os.Stderr)
+	err := s.ensureNetwork(context.TODO(), "myproject", composetypes.NetworkConfig{
+		Name:   "myproject_back",
+		Driver: "overlay",
+		Ipam: composetypes.IPAMConfig{
+			Config: []*composetypes.IPAMPool{{Subnet: "172.28.0.0/16"}},
+		},
+	})
+	assert.NilError(t, err)
+	apiClient.AssertNotCalled(t, "NetworkCreate", mock.Anything, mock.Anything, mock.Anything)
+	assert.Assert(t, strings.Contains(out.String(), `driver is \"bridge\", expected \"overlay\"`), out.String())
+	assert.Assert(t, strings.Contains(out.String(), "subnet 172.28.0.0/16 is missing"), out.String())
+}
+
+func TestEnsureMissingExternalNetwork(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("NetworkInspect", mock.Anything, "shared", types.NetworkInspectOptions{}).
+		Return(types.NetworkResource{}, errdefs.NotFound(errors.New("not found")))
+	s := newMockBackend(apiClient)
+
+	err := s.ensureNetwork(context.TODO(), "myproject", composetypes.NetworkConfig{
+		Name:     "shared",
+		External: composetypes.External{External: true},
+	})
+	assert.Error(t, err, "network shared declared as external, but could not be found")
+	apiClient.AssertNotCalled(t, "NetworkCreate", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestInit(t *testing.T) {
+	enabled := true
+	service := composetypes.ServiceConfig{
+		Name: "web",
+		Init: &enabled,
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.Assert(t, hostConfig.Init != nil && *hostConfig.Init)
+
+	// daemon default applies when unset
+	service.Init = nil
+	_, hostConfig, _, err = getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.Assert(t, hostConfig.Init == nil)
+}
+
+func TestUpAttachOnly(t *testing.T) {
+	project := &composetypes.Project{
+		Name: "myproject",
+		Services: []composetypes.ServiceConfig{
+			{Name: "web", Image: "nginx"},
+			{Name: "db", Image: "mysql"},
+		},
+	}
+	forService := func(name string) interface{} {
+		return mock.MatchedBy(func(options types.ContainerListOptions) bool {
+			return options.Filters.ExactMatch("label", fmt.Sprintf("%s=%s", serviceLabel, name))
+		})
+	}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, forService("web")).
+		Return([]types.Container{{ID: "c1", Names: []string{"/myproject_web_1"}, State: "running"}}, nil)
+	apiClient.On("ContainerList", mock.Anything, forService("db")).
+		Return([]types.Container{{ID: "c2", Names: []string{"/myproject_db_1"}, State: "running"}}, nil)
+	s := newMockBackend(apiClient)
+
+	err := s.Up(context.TODO(), project, compose.UpOptions{AttachOnly: true})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	apiClient.AssertNotCalled(t, "ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	apiClient.AssertNotCalled(t, "ContainerStart", mock.Anything, mock.Anything, mock.Anything)
+	apiClient.AssertNotCalled(t, "ContainerStop", mock.Anything, mock.Anything, mock.Anything)
+	apiClient.AssertNotCalled(t, "NetworkCreate", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpAttachOnlyRequiresRunningContainers(t *testing.T) {
+	project := &composetypes.Project{
+		Name:     "myproject",
+		Services: []composetypes.ServiceConfig{{Name: "web", Image: "nginx"}},
+	}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).
+		Return([]types.Container{{ID: "c1", Names: []string{"/myproject_web_1"}, State: "exited"}}, nil).Once()
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{}, nil).Once()
+	s := newMockBackend(apiClient)
+
+	err := s.Up(context.TODO(), project, compose.UpOptions{AttachOnly: true})
+	assert.Error(t, err, `container myproject_web_1 of service "web" is exited, run up without attach to start it`)
+	err = s.Up(context.TODO(), project, compose.UpOptions{AttachOnly: true})
+	assert.Error(t, err, `service "web" has no container, run up without attach to create it`)
+}
+
+func TestLogConfig(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name: "web",
+		Logging: &composetypes.LoggingConfig{
+			Driver:  "fluentd",
+			Options: map[string]string{"fluentd-address": "localhost:24224", "tag": "web"},
+		},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hostConfig.LogConfig, container.LogConfig{
+		Type:   "fluentd",
+		Config: map[string]string{"fluentd-address": "localhost:24224", "tag": "web"},
+	})
+
+	_, err = toLogConfig(&composetypes.LoggingConfig{Driver: "grafana/loki-docker-driver:latest"})
+	assert.NilError(t, err)
+	_, err = toLogConfig(&composetypes.LoggingConfig{Driver: "json file"})
+	assert.Error(t, err, `invalid logging driver "json file"`)
+}
+
+func TestContainerLabels(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+		Labels: composetypes.Labels{
+			"com.example.tier":   "front",
+			projectLabel:         "hijacked",
+			containerNumberLabel: "42",
+		},
+	}
+	project := &composetypes.Project{Name: "myproject", WorkingDir: "/src/myproject", Services: []composetypes.ServiceConfig{service}}
+	containerConfig, _, _, err := getContainerCreateOptions(project, service, 2, nil)
+	assert.NilError(t, err)
+	labels := containerConfig.Labels
+	assert.Equal(t, labels["com.example.tier"], "front")
+	assert.Equal(t, labels["com.docker.compose.project"], "myproject")
+	assert.Equal(t, labels["com.docker.compose.service"], "web")
+	assert.Equal(t, labels["com.docker.compose.container-number"], "2")
+	assert.Equal(t, labels["com.docker.compose.project.working_dir"], "/src/myproject")
+	assert.Equal(t, labels["com.docker.compose.oneoff"], "False")
+	assert.Equal(t, labels["com.docker.compose.version"], internal.Version)
+}
+
+func TestSysctls(t *testing.T) {
+	for _, sysctls := range []string{
+		`{net.core.somaxconn: "1024"}`,
+		`["net.core.somaxconn=1024"]`,
+	} {
+		dict, err := loader.ParseYAML([]byte(fmt.Sprintf(`
+services:
+  web:
+    image: nginx
+    sysctls: %s
+`, sysctls)))
+		assert.NilError(t, err)
+		project, err := loader.Load(composetypes.ConfigDetails{
+			ConfigFiles: []composetypes.ConfigFile{{Config: dict}},
+		}, func(options *loader.Options) {
+			options.Name = "myproject"
+		})
+		assert.NilError(t, err)
+
+		_, hostConfig, _, err := getContainerCreateOptions(project, project.Services[0], 1, nil)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, hostConfig.Sysctls, map[string]string{"net.core.somaxconn": "1024"})
+	}
+}
+
+func TestStopSignalAndGracePeriod(t *testing.T) {
+	gracePeriod := composetypes.Duration(30 * time.Second)
+	service := composetypes.ServiceConfig{
+		Name:            "web",
+		Image:           "nginx",
+		StopSignal:      "SIGQUIT",
+		StopGracePeriod: &gracePeriod,
+	}
+	containerConfig, _, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, containerConfig.StopSignal, "SIGQUIT")
+	assert.Equal(t, *containerConfig.StopTimeout, 30)
+
+	// without stop_signal nor stop_grace_period, engine defaults apply
+	service.StopSignal = ""
+	service.StopGracePeriod = nil
+	containerConfig, _, _, err = getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, containerConfig.StopSignal, "")
+	assert.Assert(t, containerConfig.StopTimeout == nil)
+}
+
+func TestContainerIdentity(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:       "web",
+		Image:      "nginx",
+		WorkingDir: "/app",
+		User:       "1000:1000",
+		Hostname:   "frontend",
+		DomainName: "example.com",
+	}
+	containerConfig, _, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, containerConfig.WorkingDir, "/app")
+	assert.Equal(t, containerConfig.User, "1000:1000")
+	assert.Equal(t, containerConfig.Hostname, "frontend")
+	assert.Equal(t, containerConfig.Domainname, "example.com")
+}
+
+func TestVolumesFrom(t *testing.T) {
+	project := &composetypes.Project{
+		Name: "myproject",
+		Services: []composetypes.ServiceConfig{
+			{Name: "backup", Image: "alpine", VolumesFrom: []string{"db:ro", "data", "container:legacy:rw"}},
+			{Name: "db", Image: "postgres"},
+			{Name: "data", Image: "busybox"},
+		},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(project, project.Services[0], 1, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hostConfig.VolumesFrom, []string{"db:ro", "data", "legacy:rw"})
+	assert.DeepEqual(t, getDependencies(project.Services[0]), []string{"db", "data"})
+
+	for volumesFrom, expected := range map[string]string{
+		"cache":        `service "backup" volumes_from refers to undefined service "cache"`,
+		"db:rx":        `service "backup" volumes_from "db:rx" has invalid mode "rx": must be ro or rw`,
+		"container:":   `service "backup" volumes_from "container:" doesn't set a service or container`,
+		"db:ro:nocopy": `service "backup" volumes_from "db:ro:nocopy": expected service[:mode] or container:name[:mode]`,
+	} {
+		_, err := getVolumesFrom(project, composetypes.ServiceConfig{Name: "backup", VolumesFrom: []string{volumesFrom}})
+		assert.Error(t, err, expected)
+	}
+}
+
+func TestDNS(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:      "web",
+		Image:     "nginx",
+		DNS:       composetypes.StringList{"8.8.8.8", "2001:4860:4860::8888"},
+		DNSSearch: composetypes.StringList{"example.com"},
+		DNSOpts:   []string{"ndots:2", "timeout:3"},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hostConfig.DNS, []string{"8.8.8.8", "2001:4860:4860::8888"})
+	assert.DeepEqual(t, hostConfig.DNSSearch, []string{"example.com"})
+	assert.DeepEqual(t, hostConfig.DNSOptions, []string{"ndots:2", "timeout:3"})
+
+	service.DNS = composetypes.StringList{"dns.example.com"}
+	_, _, _, err = getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.Error(t, err, `invalid dns "dns.example.com": not a valid IP address`)
+}
+
+func TestIpcAndPidModes(t *testing.T) {
+	project := &composetypes.Project{
+		Name: "myproject",
+		Services: []composetypes.ServiceConfig{
+			{Name: "db", Image: "postgres"},
+			{Name: "debug", Image: "busybox", Ipc: "service:db", Pid: "service:db"},
+			{Name: "monitor", Image: "busybox", Ipc: "host", Pid: "host"},
+		},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(project, project.Services[2], 1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, hostConfig.IpcMode, container.IpcMode("host"))
+	assert.Equal(t, hostConfig.PidMode, container.PidMode("host"))
+
+	_, hostConfig, _, err = getContainerCreateOptions(project, project.Services[1], 1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, hostConfig.IpcMode, container.IpcMode("service:db"))
+	assert.Equal(t, hostConfig.PidMode, container.PidMode("service:db"))
+	assert.DeepEqual(t, getDependencies(project.Services[1]), []string{"db"})
+
+	// db IPC namespace is used by debug, so it must be shareable
+	_, hostConfig, _, err = getContainerCreateOptions(project, project.Services[0], 1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, hostConfig.IpcMode, container.IpcMode("shareable"))
+	assert.Equal(t, hostConfig.PidMode, container.PidMode(""))
+
+	project.Services[1].Pid = "service:cache"
+	_, _, _, err = getContainerCreateOptions(project, project.Services[1], 1, nil)
+	assert.Error(t, err, `service "debug" pid refers to undefined service "cache"`)
+}
+
+func TestCgroupParent(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:         "web",
+		Image:        "nginx",
+		CgroupParent: "/compose/web",
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, hostConfig.CgroupParent, "/compose/web")
+	assert.Equal(t, hostConfig.Cgroup, container.CgroupSpec(""))
+}
+
+func TestCommandOverrides(t *testing.T) {
+	project := &composetypes.Project{
+		Name: "myproject",
+		Services: []composetypes.ServiceConfig{
+			{Name: "web", Image: "nginx", Command: composetypes.ShellCommand{"nginx", "-g", "daemon off;"}},
+		},
+	}
+	err := applyCommandOverrides(project, compose.UpOptions{
+		Command:    map[string][]string{"web": {"sleep", "infinity"}},
+		Entrypoint: map[string][]string{"web": {"/bin/sh", "-c"}},
+	})
+	assert.NilError(t, err)
+	containerConfig, _, _, err := getContainerCreateOptions(project, project.Services[0], 1, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, containerConfig.Cmd, strslice.StrSlice{"sleep", "infinity"})
+	assert.DeepEqual(t, containerConfig.Entrypoint, strslice.StrSlice{"/bin/sh", "-c"})
+
+	err = applyCommandOverrides(project, compose.UpOptions{Command: map[string][]string{"db": {"sh"}}})
+	assert.Error(t, err, `can't override command of service "db": no such service`)
+}
+
+func TestVolumeLongSyntaxOptions(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+		Volumes: []composetypes.ServiceVolumeConfig{
+			{
+				Type:        "bind",
+				Source:      "/mnt/shared",
+				Target:      "/shared",
+				Consistency: "cached",
+				Bind:        &composetypes.ServiceVolumeBind{Propagation: composetypes.PropagationRShared},
+			},
+			{
+				Type:   "volume",
+				Source: "myproject_data",
+				Target: "/data",
+				Volume: &composetypes.ServiceVolumeVolume{NoCopy: true},
+			},
+		},
+	}
+	expected := []mount.Mount{
+		{
+			Type:        mount.TypeBind,
+			Source:      "/mnt/shared",
+			Target:      "/shared",
+			Consistency: mount.ConsistencyCached,
+			BindOptions: &mount.BindOptions{Propagation: mount.PropagationRShared},
+		},
+		{
+			Type:          mount.TypeVolume,
+			Source:        "myproject_data",
+			Target:        "/data",
+			VolumeOptions: &mount.VolumeOptions{NoCopy: true},
+		},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hostConfig.Mounts, expected)
+
+	// options are preserved when a recreated container inherits mounts of the container it replaces
+	inherit := &types.Container{Mounts: []types.MountPoint{
+		{Type: mount.TypeBind, Source: "/mnt/shared", Destination: "/shared", RW: true, Propagation: mount.PropagationRShared},
+		{Type: mount.TypeVolume, Name: "myproject_data", Source: "/var/lib/docker/volumes/myproject_data/_data", Destination: "/data", RW: true},
+	}}
+	_, hostConfig, _, err = getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, inherit)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hostConfig.Mounts, expected)
+}
+
+func TestAnnotationsSetAsLabels(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:   "web",
+		Image:  "nginx",
+		Labels: composetypes.Labels{"team": "frontend"},
+		Extensions: map[string]interface{}{extAnnotations: map[string]interface{}{
+			"io.kubernetes.cri.runtime": "runc",
+			projectLabel:                "other",
+		}},
+	}
+	project := &composetypes.Project{Name: "myproject", Services: []composetypes.ServiceConfig{service}}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerCreate", mock.Anything, mock.MatchedBy(func(config *container.Config) bool {
+		return config.Labels["annotation.io.kubernetes.cri.runtime"] == "runc" &&
+			config.Labels["annotation."+projectLabel] == "other" &&
+			config.Labels[projectLabel] == "myproject" &&
+			config.Labels["team"] == "frontend"
+	}), mock.Anything, mock.Anything, "myproject_web_1").Return(container.ContainerCreateCreatedBody{ID: "abc"}, nil)
+	apiClient.On("ContainerStart", mock.Anything, "abc", types.ContainerStartOptions{}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	err := s.runContainer(context.TODO(), project, service, "myproject_web_1", 1, nil, compose.UpOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+
+	service.Labels["annotation.team"] = "backend"
+	service.Extensions[extAnnotations] = map[string]interface{}{"team": "frontend"}
+	_, err = getAnnotations(service)
+	assert.Error(t, err, `annotation "team" of service "web" conflicts with label "annotation.team"`)
+}
+
+func TestUsernsMode(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:       "web",
+		Image:      "nginx",
+		UserNSMode: "host",
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, hostConfig.UsernsMode, container.UsernsMode("host"))
+	assert.Assert(t, hostConfig.UsernsMode.IsHost())
+}
+
+func TestIsolation(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:      "web",
+		Image:     "nginx",
+		Isolation: "hyperv",
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, hostConfig.Isolation, container.IsolationHyperV)
+
+	service.Isolation = "sandbox"
+	_, _, _, err = getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.Error(t, err, `invalid isolation "sandbox" for service "web": must be one of default, process, hyperv`)
+}
+
+func TestGroupAdd(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:     "web",
+		Image:    "nginx",
+		GroupAdd: []string{"audio", "1001"},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hostConfig.GroupAdd, []string{"audio", "1001"})
+
+	dict, err := loader.ParseYAML([]byte(`
+services:
+  web:
+    image: nginx
+    x-group_add:
+      - audio
+      - 1001
+`))
+	assert.NilError(t, err)
+	project, err := loader.Load(composetypes.ConfigDetails{
+		ConfigFiles: []composetypes.ConfigFile{{Config: dict}},
+	}, func(options *loader.Options) {
+		options.Name = "myproject"
+	})
+	assert.NilError(t, err)
+	_, hostConfig, _, err = getContainerCreateOptions(project, project.Services[0], 1, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hostConfig.GroupAdd, []string{"audio", "1001"})
+
+	project.Services[0].Extensions[extGroupAdd] = []interface{}{-1}
+	_, err = getGroupAdd(project.Services[0])
+	assert.Error(t, err, `invalid x-group_add for service "web": GID -1 must be a positive integer`)
+	project.Services[0].Extensions[extGroupAdd] = []interface{}{true}
+	_, err = getGroupAdd(project.Services[0])
+	assert.Error(t, err, `invalid x-group_add for service "web": group true must be a name or a GID`)
+}
+
+func TestMacAddress(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:       "web",
+		Image:      "nginx",
+		MacAddress: "02:42:ac:11:00:02",
+	}
+	containerConfig, _, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, containerConfig.MacAddress, "02:42:ac:11:00:02")
+
+	for _, mac := range []string{"02:42:ac:11:00", "02:42:ac:11:00:zz", "02:00:5e:10:00:00:00:01"} {
+		service.MacAddress = mac
+		_, _, _, err = getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+		assert.ErrorContains(t, err, fmt.Sprintf(`invalid mac_address %q for service "web"`, mac))
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:    "web",
+		CapAdd:  []string{"NET_ADMIN", "cap_sys_time"},
+		CapDrop: []string{"ALL"},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hostConfig.CapAdd, strslice.StrSlice{"NET_ADMIN", "SYS_TIME"})
+	assert.DeepEqual(t, hostConfig.CapDrop, strslice.StrSlice{"ALL"})
+}
+
+func TestDevices(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name:    "web",
+		Devices: []string{"/dev/ttyUSB0:/dev/ttyUSB0:rw"},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hostConfig.Devices, []container.DeviceMapping{
+		{PathOnHost: "/dev/ttyUSB0", PathInContainer: "/dev/ttyUSB0", CgroupPermissions: "rw"},
+	})
+
+	devices, err := toDevices([]string{"/dev/sda", "/dev/sdb:r", "/dev/sdc:/dev/xvdc"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, devices, []container.DeviceMapping{
+		{PathOnHost: "/dev/sda", PathInContainer: "/dev/sda", CgroupPermissions: "rwm"},
+		{PathOnHost: "/dev/sdb", PathInContainer: "/dev/sdb", CgroupPermissions: "r"},
+		{PathOnHost: "/dev/sdc", PathInContainer: "/dev/xvdc", CgroupPermissions: "rwm"},
+	})
+
+	_, err = toDevices([]string{"ttyUSB0"})
+	assert.Error(t, err, `invalid device "ttyUSB0": device paths must be absolute`)
+	_, err = toDevices([]string{"/dev/ttyUSB0:/dev/ttyUSB0:rx"})
+	assert.Error(t, err, `invalid device "/dev/ttyUSB0:/dev/ttyUSB0:rx": permissions must be a combination of r, w and m`)
+}
+
+func TestDeviceCgroupRules(t *testing.T) {
+	service := composetypes.ServiceConfig{
+		Name: "web",
+		Extensions: map[string]interface{}{
+			extDeviceCgroupRules: []interface{}{"c 1:3 mr", "a 7:* rmw", "b *:* r"},
+		},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(&composetypes.Project{Name: "myproject"}, service, 1, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hostConfig.DeviceCgroupRules, []string{"c 1:3 mr", "a 7:* rmw", "b *:* r"})
+
+	for _, rule := range []string{"x 1:3 r", "c 1 r", "c 1:3", "c a:3 r", "c 1:3 rx"} {
+		service.Extensions[extDeviceCgroupRules] = []interface{}{rule}
+		_, err = getDeviceCgroupRules(service)
+		assert.Error(t, err, fmt.Sprintf("invalid x-device_cgroup_rules for service \"web\": rule %q must be set as `type major:minor permissions`", rule))
+	}
+	service.Extensions[extDeviceCgroupRules] = "c 1:3 mr"
+	_, err = getDeviceCgroupRules(service)
+	assert.ErrorContains(t, err, "invalid x-device_cgroup_rules for service \"web\"")
+}
+
+func TestUpRequiresMinimumAPIVersion(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ServerVersion", mock.Anything).Return(types.Version{APIVersion: "1.24"}, nil)
+	s := newMockBackend(apiClient)
+
+	project := &composetypes.Project{Name: "myproject", Services: []composetypes.ServiceConfig{{Name: "web", Image: "nginx"}}}
+	err := s.Up(context.TODO(), project, compose.UpOptions{})
+	assert.Error(t, err, "engine API version 1.24 is too old: API version 1.25 or later (Docker Engine 1.13+) is required, please upgrade Docker Engine")
+	apiClient.AssertNotCalled(t, "ContainerList", mock.Anything, mock.Anything)
+}
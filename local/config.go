@@ -0,0 +1,131 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/compose-spec/compose-go/types"
+
+	"github.com/docker/compose-cli/api/compose"
+)
+
+// Config validates project and returns it normalized, or only its services names or configuration hashes
+func (s *local) Config(ctx context.Context, project *types.Project, options compose.ConfigOptions) ([]byte, error) {
+	err := validateProject(project)
+	if err != nil {
+		return nil, err
+	}
+	if !options.Services && !options.Hash {
+		return s.Convert(ctx, project, options.Format)
+	}
+	var b bytes.Buffer
+	for _, service := range project.Services {
+		if !options.Hash {
+			fmt.Fprintln(&b, service.Name)
+			continue
+		}
+		hash, err := jsonHash(service)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "%s %s\n", service.Name, hash)
+	}
+	return b.Bytes(), nil
+}
+
+// validateProject checks resources referred to by services are declared, and that services settings which are
+// resolved when containers get created are valid. Projects are built without loader consistency checks by API
+// clients, so those are checked again
+func validateProject(project *types.Project) error {
+	err := checkExtendsResolved(project)
+	if err != nil {
+		return err
+	}
+	for _, service := range project.Services {
+		for network := range service.Networks {
+			if _, ok := project.Networks[network]; !ok {
+				return fmt.Errorf("service %q refers to undefined network %q", service.Name, network)
+			}
+		}
+		for _, volume := range service.Volumes {
+			if _, ok := project.Volumes[volume.Source]; volume.Type == types.VolumeTypeVolume && volume.Source != "" && !ok {
+				return fmt.Errorf("service %q refers to undefined volume %q", service.Name, volume.Source)
+			}
+		}
+		for _, secret := range service.Secrets {
+			if _, ok := project.Secrets[secret.Source]; !ok {
+				return fmt.Errorf("service %q refers to undefined secret %q", service.Name, secret.Source)
+			}
+		}
+		for _, config := range service.Configs {
+			if _, ok := project.Configs[config.Source]; !ok {
+				return fmt.Errorf("service %q refers to undefined config %q", service.Name, config.Source)
+			}
+		}
+		for _, dependency := range getDependencies(service) {
+			if _, err := project.GetService(dependency); err != nil {
+				return fmt.Errorf("service %q depends on undefined service %q", service.Name, dependency)
+			}
+		}
+		if err := checkDeployMode(service); err != nil {
+			return err
+		}
+		if _, err := getPlatform(service); err != nil {
+			return err
+		}
+		if _, err := getStartPriority(service); err != nil {
+			return err
+		}
+		if _, err := getIsolation(service); err != nil {
+			return err
+		}
+		if _, err := getDeviceCgroupRules(service); err != nil {
+			return err
+		}
+		if _, err := getGroupAdd(service); err != nil {
+			return err
+		}
+		if _, err := getPortDependencies(service); err != nil {
+			return err
+		}
+		if _, err := getAnnotations(service); err != nil {
+			return err
+		}
+		if _, err := getNetworkMode(project, service); err != nil {
+			return err
+		}
+		if _, err := getVolumesFrom(project, service); err != nil {
+			return err
+		}
+		if _, err := getIpcMode(project, service); err != nil {
+			return err
+		}
+		if _, err := getNamespaceMode(project, service, "pid", service.Pid); err != nil {
+			return err
+		}
+	}
+	if cycle := buildDependencyGraph(project.Services).findCycle(); cycle != nil {
+		return fmt.Errorf("dependency cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/compose-spec/compose-go/types"
+	"gotest.tools/v3/assert"
+
+	"github.com/docker/compose-cli/api/compose"
+)
+
+func TestConfigRejectsUndefinedNetwork(t *testing.T) {
+	project := &types.Project{
+		Name: "myproject",
+		Services: []types.ServiceConfig{{
+			Name:     "web",
+			Image:    "nginx",
+			Networks: map[string]*types.ServiceNetworkConfig{"front": nil},
+		}},
+	}
+	// backend has no engine client set, so that it fails if contacted
+	s := &local{}
+
+	_, err := s.Config(context.TODO(), project, compose.ConfigOptions{Format: "yaml"})
+	assert.Error(t, err, `service "web" refers to undefined network "front"`)
+
+	project.Networks = types.Networks{"front": types.NetworkConfig{}}
+	_, err = s.Config(context.TODO(), project, compose.ConfigOptions{Format: "yaml"})
+	assert.NilError(t, err)
+}
+
+func TestConfigRejectsUndefinedDependency(t *testing.T) {
+	project := &types.Project{
+		Name: "myproject",
+		Services: []types.ServiceConfig{{
+			Name:      "web",
+			Image:     "nginx",
+			DependsOn: types.DependsOnConfig{"db": {Condition: types.ServiceConditionStarted}},
+		}},
+	}
+	_, err := (&local{}).Config(context.TODO(), project, compose.ConfigOptions{Format: "yaml"})
+	assert.Error(t, err, `service "web" depends on undefined service "db"`)
+}
+
+func TestConfigServicesAndHash(t *testing.T) {
+	project := &types.Project{
+		Name: "myproject",
+		Services: []types.ServiceConfig{
+			{Name: "web", Image: "nginx"},
+			{Name: "db", Image: "postgres"},
+		},
+	}
+	s := &local{}
+
+	out, err := s.Config(context.TODO(), project, compose.ConfigOptions{Services: true})
+	assert.NilError(t, err)
+	assert.Equal(t, string(out), "web\ndb\n")
+
+	web, err := jsonHash(project.Services[0])
+	assert.NilError(t, err)
+	db, err := jsonHash(project.Services[1])
+	assert.NilError(t, err)
+	out, err = s.Config(context.TODO(), project, compose.ConfigOptions{Hash: true})
+	assert.NilError(t, err)
+	assert.Equal(t, string(out), fmt.Sprintf("web %s\ndb %s\n", web, db))
+}
@@ -39,7 +39,7 @@ import (
 )
 
 type containerService struct {
-	apiClient *client.Client
+	apiClient client.APIClient
 }
 
 func (cs *containerService) Inspect(ctx context.Context, id string) (containers.Container, error) {
@@ -243,6 +243,9 @@ func (cs *containerService) Logs(ctx context.Context, containerName string, requ
 		ShowStdout: true,
 		ShowStderr: true,
 		Follow:     request.Follow,
+		Timestamps: request.Timestamps,
+		Since:      request.Since,
+		Until:      request.Until,
 	})
 
 	if err != nil {
@@ -20,219 +20,1154 @@ package local
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/compose-spec/compose-go/types"
 	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 
+	"github.com/docker/compose-cli/api/compose"
 	"github.com/docker/compose-cli/api/containers"
 	"github.com/docker/compose-cli/progress"
 )
 
 const (
-	extLifecycle  = "x-lifecycle"
-	forceRecreate = "force_recreate"
+	extLifecycle           = "x-lifecycle"
+	extExternalDependsOn   = "x-external_depends_on"
+	forceRecreate          = "force_recreate"
+	extNetworkPriority     = "x-priority"
+	extStartPriority       = "x-start-priority"
+	extWaitForPorts        = "x-wait_for_ports"
+	defaultParallelLimit   = 32
+	envParallelLimit       = "COMPOSE_PARALLEL_LIMIT"
+	deployModeGlobal       = "global"
+	minPollInterval        = 100 * time.Millisecond
+	maxPollInterval        = 5 * time.Second
+	networkConnectAttempts = 5
 )
 
-func (s *local) ensureService(ctx context.Context, project *types.Project, service types.ServiceConfig) error {
-	err := s.waitDependencies(ctx, project, service)
+// networkConnectRetryDelay is the delay before connecting a container to a network is retried, doubling on each attempt
+var networkConnectRetryDelay = 100 * time.Millisecond
+
+func (s *local) ensureService(ctx context.Context, project *types.Project, service types.ServiceConfig, options compose.UpOptions) error {
+	if !options.NoDeps {
+		err := s.waitDependencies(ctx, project, service)
+		if err != nil {
+			return err
+		}
+	}
+	notifyTransition(options, service.Name, compose.ServiceCreating, nil)
+
+	actual, err := s.getServiceContainers(ctx, project, service)
+	if err != nil {
+		return err
+	}
+
+	actual, err = s.reconcileInterruptedRecreates(ctx, service, actual)
+	if err != nil {
+		return err
+	}
+
+	lifecycle, err := getLifecycle(service)
+	if err != nil {
+		return err
+	}
+
+	scale := getScale(service)
+	if replicas, ok := options.Scale[service.Name]; ok {
+		scale = replicas
+	}
+
+	limit, err := getParallelLimit(options.Parallel)
+	if err != nil {
+		return err
+	}
+	parent := ctx
+	eg, ctx := newLimitedGroup(ctx, limit)
+	missing := scale - len(actual)
+	if missing > 0 {
+		next, err := nextContainerNumber(actual)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < missing; i++ {
+			number := next + i
+			name := compose.GetContainerName(project.Name, service.Name, number)
+			eg.Go(func() error {
+				return s.createContainer(ctx, project, service, name, number, options)
+			})
+		}
+	}
+
+	actual, obsolete := splitReplicas(actual, scale)
+	for _, container := range obsolete {
+		container := container
+		eg.Go(func() error {
+			err := s.runLifecycleHook(ctx, service, container.ID, preStop, lifecycle.PreStop)
+			if err != nil {
+				return err
+			}
+			err = s.containerService.Stop(ctx, container.ID, nil)
+			if err != nil {
+				return err
+			}
+			return s.containerService.Delete(ctx, container.ID, containers.DeleteRequest{})
+		})
+	}
+
+	expected, err := jsonHash(service)
+	if err != nil {
+		return err
+	}
+
+	imageID, err := s.getImageID(ctx, project, service)
 	if err != nil {
 		return err
 	}
 
-	actual, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
+	var filesHash string
+	if options.RecreateChangedFiles {
+		filesHash, err = getFileReferencesHash(project, service)
+		if err != nil {
+			return err
+		}
+	}
+
+	// when scaling up, diverged replicas are only recreated once new ones are created, see recreateRolling
+	var rolling []moby.Container
+	for _, container := range actual {
+		container := container
+		action := getContainerAction(service, lifecycle, container, expected, imageID, options.Recreate)
+		if options.RecreateChangedFiles && options.Recreate != compose.RecreateNever &&
+			container.Labels[fileReferencesHashLabel] != filesHash {
+			action = compose.ActionRecreate
+		}
+		if action == compose.ActionRecreate && options.Adopt && options.Recreate != compose.RecreateForce &&
+			lifecycle.Strategy != forceRecreate && canAdopt(project, service, container, imageID) {
+			progress.ContextWriter(ctx).Event(progress.Event{
+				ID:         fmt.Sprintf("Service %q", service.Name),
+				Text:       fmt.Sprintf("Adopt container %s", getContainerName(container)),
+				Status:     progress.Working,
+				StatusText: "Adopt",
+			})
+			action = getContainerAction(service, lifecycle, container, expected, imageID, compose.RecreateNever)
+		}
+		switch action {
+		case compose.ActionRecreate:
+			if missing > 0 {
+				rolling = append(rolling, container)
+				continue
+			}
+			eg.Go(func() error {
+				return s.recreateContainer(ctx, project, service, container, options)
+			})
+		case compose.ActionRestart:
+			eg.Go(func() error {
+				return s.restartContainer(ctx, service, container)
+			})
+		case compose.ActionNone:
+			eg.Go(func() error {
+				if options.RecreateUnhealthy {
+					unhealthy, err := s.isContainerUnhealthy(ctx, container.ID)
+					if err != nil {
+						return err
+					}
+					if unhealthy {
+						return s.recreateContainer(ctx, project, service, container, options)
+					}
+				}
+				// already converged, only report container has been considered
+				progress.ContextWriter(ctx).Event(progress.Event{
+					ID:         fmt.Sprintf("Service %q", service.Name),
+					Status:     progress.Done,
+					StatusText: "Running",
+					Done:       true,
+				})
+				return nil
+			})
+		}
+	}
+	err = eg.Wait()
+	if err != nil || len(rolling) == 0 {
+		return err
+	}
+	return s.recreateRolling(parent, project, service, rolling, options)
+}
+
+// recreateRolling recreates diverged replicas one at a time, after missing replicas got created with the new
+// configuration, so that service never runs more than one replica above scale while being updated
+func (s *local) recreateRolling(ctx context.Context, project *types.Project, service types.ServiceConfig, diverged []moby.Container, options compose.UpOptions) error {
+	sort.Slice(diverged, func(i, j int) bool {
+		return getContainerNumber(diverged[i]) < getContainerNumber(diverged[j])
+	})
+	for _, container := range diverged {
+		err := s.recreateContainer(ctx, project, service, container, options)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyTransition reports a service state transition to the listener set by options, if any
+func notifyTransition(options compose.UpOptions, service string, state string, err error) {
+	if options.Listener == nil {
+		return
+	}
+	options.Listener(compose.ServiceTransition{
+		Service: service,
+		State:   state,
+		Error:   err,
+	})
+}
+
+// getParallelLimit returns the maximum number of container operations to run concurrently: parallel if set,
+// otherwise the limit set by COMPOSE_PARALLEL_LIMIT environment variable, or the default one
+func getParallelLimit(parallel int) (int, error) {
+	if parallel > 0 {
+		return parallel, nil
+	}
+	if env, ok := os.LookupEnv(envParallelLimit); ok {
+		limit, err := strconv.Atoi(env)
+		if err != nil || limit < 1 {
+			return 0, fmt.Errorf("invalid %s value %q: must be a positive integer", envParallelLimit, env)
+		}
+		return limit, nil
+	}
+	return defaultParallelLimit, nil
+}
+
+// limitedGroup is an errgroup running at most a limited number of functions concurrently,
+// so that scaling a service to many replicas doesn't overwhelm the engine
+type limitedGroup struct {
+	*errgroup.Group
+	ctx context.Context
+	sem *semaphore.Weighted
+}
+
+func newLimitedGroup(ctx context.Context, limit int) (*limitedGroup, context.Context) {
+	eg, ctx := errgroup.WithContext(ctx)
+	return &limitedGroup{
+		Group: eg,
+		ctx:   ctx,
+		sem:   semaphore.NewWeighted(int64(limit)),
+	}, ctx
+}
+
+func (g *limitedGroup) Go(fn func() error) {
+	g.Group.Go(func() error {
+		err := g.sem.Acquire(g.ctx, 1)
+		if err != nil {
+			return err
+		}
+		defer g.sem.Release(1)
+		return fn()
+	})
+}
+
+// serviceFailures collects errors of services which failed to converge, so that convergence carries on with other
+// services. Services depending on a failed one are skipped, as they would fail waiting for it
+type serviceFailures struct {
+	mtx    sync.Mutex
+	failed map[string]bool
+	errs   *multierror.Error
+}
+
+// run converges service using fn, unless one of its dependencies failed, and returns the recorded error if any
+func (f *serviceFailures) run(ctx context.Context, service types.ServiceConfig, fn func() error) error {
+	err := f.checkDependencies(service)
+	if err == nil {
+		err = fn()
+	}
+	if err == nil {
+		return nil
+	}
+	w := progress.ContextWriter(ctx)
+	w.Event(progress.Event{
+		ID:         fmt.Sprintf("Service %q", service.Name),
+		Status:     progress.Error,
+		StatusText: err.Error(),
+		Done:       true,
+	})
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if f.failed == nil {
+		f.failed = map[string]bool{}
+	}
+	f.failed[service.Name] = true
+	f.errs = multierror.Append(f.errs, errors.Wrapf(err, "service %q", service.Name))
+	return err
+}
+
+func (f *serviceFailures) checkDependencies(service types.ServiceConfig) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	for _, dep := range getDependencies(service) {
+		if f.failed[dep] {
+			return fmt.Errorf("skipped as dependency %q failed", dep)
+		}
+	}
+	return nil
+}
+
+func (f *serviceFailures) errorOrNil() error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.errs.ErrorOrNil()
+}
+
+// getServiceContainers returns the replicas of service, ignoring oneoff containers ran for it
+func (s *local) getServiceContainers(ctx context.Context, project *types.Project, service types.ServiceConfig) ([]moby.Container, error) {
+	containers, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
 		Filters: filters.NewArgs(
 			filters.Arg("label", fmt.Sprintf("%s=%s", projectLabel, project.Name)),
 			filters.Arg("label", fmt.Sprintf("%s=%s", serviceLabel, service.Name)),
 		),
+		All: true,
 	})
+	return withoutOneOffContainers(containers), err
+}
+
+// withoutOneOffContainers filters out containers created by run, which are not service replicas. Containers created
+// before the oneoff label was set are replicas
+func withoutOneOffContainers(containers []moby.Container) []moby.Container {
+	var replicas []moby.Container
+	for _, c := range containers {
+		if c.Labels[oneoffLabel] != "True" {
+			replicas = append(replicas, c)
+		}
+	}
+	return replicas
+}
+
+// getTemporaryName returns the name recreateContainer gives to a container while its replacement is created
+func getTemporaryName(container moby.Container, name string) string {
+	return fmt.Sprintf("%s_%s", container.ID[:12], name)
+}
+
+// getOriginalName returns the name a container had before recreateContainer renamed it, if container
+// has a temporary name
+func getOriginalName(container moby.Container) (string, bool) {
+	if len(container.ID) < 12 || len(container.Names) == 0 {
+		return "", false
+	}
+	prefix := container.ID[:12] + "_"
+	name := getContainerName(container)
+	if !strings.HasPrefix(name, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(name, prefix), true
+}
+
+// resolveInterruptedRecreates sorts out containers left with a temporary name by an interrupted recreate. Those
+// with a replacement container are returned as leftovers, others are returned among service containers with
+// the name they had before being renamed
+func resolveInterruptedRecreates(actual []moby.Container) ([]moby.Container, []moby.Container) {
+	names := map[string]bool{}
+	for _, c := range actual {
+		if _, ok := getOriginalName(c); !ok && len(c.Names) > 0 {
+			names[getContainerName(c)] = true
+		}
+	}
+
+	var containers, leftovers []moby.Container
+	for _, c := range actual {
+		name, ok := getOriginalName(c)
+		switch {
+		case !ok:
+			containers = append(containers, c)
+		case names[name]:
+			leftovers = append(leftovers, c)
+		default:
+			names[name] = true
+			c.Names = []string{"/" + name}
+			containers = append(containers, c)
+		}
+	}
+	return containers, leftovers
+}
+
+// reconcileInterruptedRecreates cleans up containers left with a temporary name by an interrupted recreate:
+// they get removed if a replacement container exists, otherwise they are renamed back so convergence applies
+// to them as to any other service container
+func (s *local) reconcileInterruptedRecreates(ctx context.Context, service types.ServiceConfig, actual []moby.Container) ([]moby.Container, error) {
+	w := progress.ContextWriter(ctx)
+	containers, leftovers := resolveInterruptedRecreates(actual)
+	removed := map[string]bool{}
+	for _, c := range leftovers {
+		err := s.containerService.apiClient.ContainerRemove(ctx, c.ID, moby.ContainerRemoveOptions{Force: true})
+		if err != nil {
+			return nil, err
+		}
+		removed[c.ID] = true
+		w.Event(progress.Event{
+			ID:         fmt.Sprintf("Service %q", service.Name),
+			Status:     progress.Done,
+			StatusText: fmt.Sprintf("Removed leftover container %s", getContainerName(c)),
+		})
+	}
+
+	for _, c := range actual {
+		name, ok := getOriginalName(c)
+		if !ok || removed[c.ID] {
+			continue
+		}
+		err := s.containerService.apiClient.ContainerRename(ctx, c.ID, name)
+		if err != nil {
+			return nil, err
+		}
+		w.Event(progress.Event{
+			ID:         fmt.Sprintf("Service %q", service.Name),
+			Status:     progress.Done,
+			StatusText: fmt.Sprintf("Restored container %s", name),
+		})
+	}
+	return containers, nil
+}
+
+// splitReplicas splits service containers into the ones to keep and the obsolete ones to be removed
+// to match scale, which are the replicas with the highest numbers
+func splitReplicas(actual []moby.Container, scale int) ([]moby.Container, []moby.Container) {
+	if len(actual) <= scale {
+		return actual, nil
+	}
+	sort.Slice(actual, func(i, j int) bool {
+		return getContainerNumber(actual[i]) < getContainerNumber(actual[j])
+	})
+	return actual[:scale], actual[scale:]
+}
+
+// getContainerAction computes the action required for an existing container to converge, according to recreate policy
+func getContainerAction(service types.ServiceConfig, lifecycle lifecycle, container moby.Container, expected string, imageID string, recreate string) string {
+	switch {
+	case recreate == compose.RecreateNever:
+	case recreate == compose.RecreateForce, lifecycle.Strategy == forceRecreate, mustRecreate(service, container, expected, imageID):
+		return compose.ActionRecreate
+	}
+	if container.State == "running" {
+		return compose.ActionNone
+	}
+	return compose.ActionRestart
+}
+
+// mustRecreate checks if container's configuration diverged from the expected service configuration hash,
+// or if container runs another image than imageID, the service image is currently resolved to, if known
+func mustRecreate(service types.ServiceConfig, container moby.Container, expected string, imageID string) bool {
+	if imageID != "" && container.ImageID != "" && container.ImageID != imageID {
+		// image tag got updated, typically by a pull, since container was created
+		return true
+	}
+	if getConfigHashVersion(container) != configHashVersion {
+		// config hash was computed by another algorithm and can't be compared,
+		// assume configuration is unchanged unless image diverged
+		return service.Image != "" && container.Image != service.Image
+	}
+	return container.Labels[configHashLabel] != expected
+}
+
+// canAdopt checks if a container created by another tool, as it has compose labels but no config hash version,
+// runs the essential configuration of the service: image and published ports. Engine doesn't support updating
+// labels of an existing container, so an adopted container is checked again on next convergence
+func canAdopt(project *types.Project, service types.ServiceConfig, container moby.Container, imageID string) bool {
+	if _, ok := container.Labels[configHashVersionLabel]; ok {
+		return false
+	}
+	if container.Image != getImageName(project, service) {
+		return false
+	}
+	if imageID != "" && container.ImageID != "" && container.ImageID != imageID {
+		return false
+	}
+	expected := map[string]bool{}
+	for _, p := range service.Ports {
+		if p.Published != 0 {
+			expected[fmt.Sprintf("%d:%d/%s", p.Published, p.Target, getProtocol(p.Protocol))] = true
+		}
+	}
+	actual := map[string]bool{}
+	for _, p := range container.Ports {
+		if p.PublicPort != 0 {
+			actual[fmt.Sprintf("%d:%d/%s", p.PublicPort, p.PrivatePort, getProtocol(p.Type))] = true
+		}
+	}
+	return reflect.DeepEqual(expected, actual)
+}
+
+func getProtocol(protocol string) string {
+	if protocol == "" {
+		return "tcp"
+	}
+	return protocol
+}
+
+// getImageID returns the ID of the image service containers are expected to run, or an empty string if image isn't
+// available locally
+func (s *local) getImageID(ctx context.Context, project *types.Project, service types.ServiceConfig) (string, error) {
+	image, _, err := s.containerService.apiClient.ImageInspectWithRaw(ctx, getImageName(project, service))
+	if errdefs.IsNotFound(err) {
+		return "", nil
+	}
 	if err != nil {
-		return err
+		return "", err
 	}
+	return image.ID, nil
+}
 
-	scale := getScale(service)
+func getConfigHashVersion(container moby.Container) string {
+	if version, ok := container.Labels[configHashVersionLabel]; ok {
+		return version
+	}
+	return "1"
+}
+
+func (s *local) waitDependencies(ctx context.Context, project *types.Project, service types.ServiceConfig) error {
+	external, err := getExternalDependencies(service)
+	if err != nil {
+		return err
+	}
+	ports, err := getPortDependencies(service)
+	if err != nil {
+		return err
+	}
 
 	eg, ctx := errgroup.WithContext(ctx)
-	if len(actual) < scale {
-		next, err := nextContainerNumber(actual)
+	for dep, config := range service.DependsOn {
+		dep := dep
+		switch config.Condition {
+		case types.ServiceConditionHealthy:
+			if isHealthCheckDisabled(project, dep) {
+				return fmt.Errorf("service %q depends on %q being healthy, but %q has its healthcheck disabled", service.Name, dep, dep)
+			}
+			eg.Go(func() error {
+				return waitUntil(ctx, getHealthCheckInterval(project, dep), func(ctx context.Context) (bool, string, error) {
+					return s.isServiceHealthy(ctx, project, dep)
+				})
+			})
+		case types.ServiceConditionStarted, "":
+			// service_started is the default condition, when depends_on is declared as a list
+			eg.Go(func() error {
+				return waitUntil(ctx, 0, func(ctx context.Context) (bool, string, error) {
+					return s.isServiceRunning(ctx, project, dep)
+				})
+			})
+		}
+	}
+	for dep, list := range ports {
+		for _, port := range list {
+			dep, port := dep, port
+			eg.Go(func() error {
+				return waitUntil(ctx, 0, func(ctx context.Context) (bool, string, error) {
+					return s.isServicePortOpen(ctx, project, dep, port)
+				})
+			})
+		}
+	}
+	for container, config := range external {
+		container := container
+		switch config.Condition {
+		case types.ServiceConditionHealthy:
+			eg.Go(func() error {
+				return waitUntil(ctx, 0, func(ctx context.Context) (bool, string, error) {
+					return s.isContainerHealthy(ctx, container)
+				})
+			})
+		case types.ServiceConditionStarted, "":
+			eg.Go(func() error {
+				return waitUntil(ctx, 0, func(ctx context.Context) (bool, string, error) {
+					return s.isContainerRunning(ctx, container)
+				})
+			})
+		}
+	}
+	return eg.Wait()
+}
+
+// getExternalDependencies returns containers managed outside of the compose project a service depends on,
+// declared by x-external_depends_on using the same syntax as depends_on
+func getExternalDependencies(service types.ServiceConfig) (types.DependsOnConfig, error) {
+	v, ok := service.Extensions[extExternalDependsOn]
+	if !ok {
+		return nil, nil
+	}
+	marshalled, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var external types.DependsOnConfig
+	err = json.Unmarshal(marshalled, &external)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s for service %q", extExternalDependsOn, service.Name)
+	}
+	return external, nil
+}
+
+// getPortDependencies returns TCP ports, declared by x-wait_for_ports per service, which must accept connections on
+// dependency containers before service is started. This is an alternative to service_healthy for images without a
+// healthcheck
+func getPortDependencies(service types.ServiceConfig) (map[string][]int, error) {
+	v, ok := service.Extensions[extWaitForPorts]
+	if !ok {
+		return nil, nil
+	}
+	marshalled, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var ports map[string][]int
+	err = json.Unmarshal(marshalled, &ports)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s for service %q", extWaitForPorts, service.Name)
+	}
+	for dep, list := range ports {
+		if _, ok := service.DependsOn[dep]; !ok {
+			return nil, fmt.Errorf("invalid %s for service %q: %q is not declared in depends_on", extWaitForPorts, service.Name, dep)
+		}
+		for _, port := range list {
+			if port <= 0 || port > 65535 {
+				return nil, fmt.Errorf("invalid %s for service %q: invalid port %d", extWaitForPorts, service.Name, port)
+			}
+		}
+	}
+	return ports, nil
+}
+
+// waitUntil polls condition until it is met. Condition reports the status it observed, polling backs off from floor
+// while status is unchanged
+func waitUntil(ctx context.Context, floor time.Duration, condition func(context.Context) (bool, string, error)) error {
+	b := newBackoff(floor)
+	for {
+		timer := time.NewTimer(b.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		ok, status, err := condition(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		b.next(status)
+	}
+}
+
+// backoff computes the interval between polls of a status, which doubles up to maxPollInterval while status is
+// unchanged, so slow starting services don't flood engine with requests, and is reset when status changes
+type backoff struct {
+	floor    time.Duration
+	interval time.Duration
+	status   string
+}
+
+func newBackoff(floor time.Duration) *backoff {
+	if floor < minPollInterval {
+		floor = minPollInterval
+	}
+	return &backoff{floor: floor, interval: floor}
+}
+
+func (b *backoff) next(status string) {
+	if status != b.status {
+		b.status = status
+		b.interval = b.floor
+		return
+	}
+	b.interval *= 2
+	if b.interval > maxPollInterval {
+		b.interval = maxPollInterval
+	}
+	if b.interval < b.floor {
+		b.interval = b.floor
+	}
+}
+
+// getHealthCheckInterval returns the interval service healthcheck is declared to run at, as its health can't change
+// more often, or 0 when not set
+func getHealthCheckInterval(project *types.Project, service string) time.Duration {
+	config, err := project.GetService(service)
+	if err != nil || config.HealthCheck == nil || config.HealthCheck.Interval == nil {
+		return 0
+	}
+	return time.Duration(*config.HealthCheck.Interval)
+}
+
+func nextContainerNumber(containers []moby.Container) (int, error) {
+	max := 0
+	for _, c := range containers {
+		n, err := strconv.Atoi(c.Labels[containerNumberLabel])
+		if err != nil {
+			return 0, err
+		}
+		if n > max {
+			max = n
+		}
+	}
+	return max + 1, nil
+
+}
+
+func getContainerNumber(c moby.Container) int {
+	n, _ := strconv.Atoi(c.Labels[containerNumberLabel])
+	return n
+}
+
+// getScale returns the number of replicas declared by service, which is 1 in global mode as local backend runs on
+// a single node
+func getScale(config types.ServiceConfig) int {
+	if isGlobal(config) {
+		return 1
+	}
+	if config.Deploy != nil && config.Deploy.Replicas != nil {
+		return int(*config.Deploy.Replicas)
+	}
+	if config.Scale != 0 {
+		return config.Scale
+	}
+	return 1
+}
+
+func isGlobal(config types.ServiceConfig) bool {
+	return config.Deploy != nil && config.Deploy.Mode == deployModeGlobal
+}
+
+// checkDeployMode checks service doesn't declare replicas in global mode, where it runs one container per node
+func checkDeployMode(config types.ServiceConfig) error {
+	if isGlobal(config) && (config.Deploy.Replicas != nil || config.Scale != 0) {
+		return fmt.Errorf("service %q can't set replicas in %s deploy mode", config.Name, deployModeGlobal)
+	}
+	return nil
+}
+
+func (s *local) createContainer(ctx context.Context, project *types.Project, service types.ServiceConfig, name string, number int, options compose.UpOptions) error {
+	w := progress.ContextWriter(ctx)
+	w.Event(progress.Event{
+		ID:         fmt.Sprintf("Service %q", service.Name),
+		Status:     progress.Working,
+		StatusText: "Create",
+		Done:       false,
+	})
+	err := s.runContainer(ctx, project, service, name, number, nil, options)
+	if isConflict(err) {
+		err = s.resolveNameConflict(ctx, project, service, name, number, options)
+	}
+	if err != nil {
+		return err
+	}
+	w.Event(progress.Event{
+		ID:         fmt.Sprintf("Service %q", service.Name),
+		Status:     progress.Done,
+		StatusText: "Created",
+		Done:       true,
+	})
+	return nil
+}
+
+// resolveNameConflict handles a container left with the name of the one to be created, but which isn't managed
+// by compose (typically after a crash), so it wasn't part of the service's containers
+func (s *local) resolveNameConflict(ctx context.Context, project *types.Project, service types.ServiceConfig, name string, number int, options compose.UpOptions) error {
+	policy := options.NameConflict
+	if policy == compose.NameConflictFail || policy == "" {
+		return fmt.Errorf("container name %q is already in use by a container compose doesn't manage: remove it, or set the name conflict policy to %q or %q",
+			name, compose.NameConflictReplace, compose.NameConflictAdopt)
+	}
+	w := progress.ContextWriter(ctx)
+	existing, err := s.containerService.apiClient.ContainerInspect(ctx, name)
+	if err != nil {
+		return err
+	}
+	switch policy {
+	case compose.NameConflictAdopt:
+		w.Event(progress.Event{
+			ID:         fmt.Sprintf("Service %q", service.Name),
+			Text:       fmt.Sprintf("Adopt existing container %s", name),
+			Status:     progress.Working,
+			StatusText: "Create",
+		})
+		if existing.State != nil && existing.State.Running {
+			return nil
+		}
+		return s.containerService.apiClient.ContainerStart(ctx, existing.ID, moby.ContainerStartOptions{})
+	case compose.NameConflictReplace:
+		w.Event(progress.Event{
+			ID:         fmt.Sprintf("Service %q", service.Name),
+			Text:       fmt.Sprintf("Remove conflicting container %s", name),
+			Status:     progress.Working,
+			StatusText: "Create",
+		})
+		err = s.containerService.apiClient.ContainerRemove(ctx, existing.ID, moby.ContainerRemoveOptions{Force: true})
+		if err != nil {
+			return err
+		}
+		return s.runContainer(ctx, project, service, name, number, nil, options)
+	default:
+		return fmt.Errorf("unsupported name conflict policy %q", policy)
+	}
+}
+
+// drainContainer reconnects the container to the networks it's reachable on by service name, without the service
+// alias, so it doesn't receive new connections, then waits for the drain period. Returns the original endpoint
+// settings of the drained networks
+func (s *local) drainContainer(ctx context.Context, service types.ServiceConfig, id string, period string) (map[string]*network.EndpointSettings, error) {
+	if period == "" {
+		return nil, nil
+	}
+	duration, err := time.ParseDuration(period)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid drain_period for service %q", service.Name)
+	}
+	inspect, err := s.containerService.apiClient.ContainerInspect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	w := progress.ContextWriter(ctx)
+	w.Event(progress.Event{
+		ID:         fmt.Sprintf("Service %q", service.Name),
+		Text:       fmt.Sprintf("Drain connections for %s", duration),
+		Status:     progress.Working,
+		StatusText: "Recreate",
+	})
+	drained := map[string]*network.EndpointSettings{}
+	if inspect.NetworkSettings != nil {
+		for name, endpoint := range inspect.NetworkSettings.Networks {
+			if endpoint == nil || !contains(endpoint.Aliases, service.Name) {
+				continue
+			}
+			err := s.reconnectContainer(ctx, name, id, &network.EndpointSettings{
+				Aliases: remove(endpoint.Aliases, service.Name),
+				Links:   endpoint.Links,
+			})
+			if err != nil {
+				return drained, err
+			}
+			drained[name] = endpoint
+		}
+	}
+	select {
+	case <-time.After(duration):
+		return drained, nil
+	case <-ctx.Done():
+		return drained, ctx.Err()
+	}
+}
+
+// restoreAliases sets back the aliases of the networks the container got drained from
+func (s *local) restoreAliases(ctx context.Context, id string, drained map[string]*network.EndpointSettings) error {
+	for n, endpoint := range drained {
+		err := s.reconnectContainer(ctx, n, id, &network.EndpointSettings{
+			Aliases: endpoint.Aliases,
+			Links:   endpoint.Links,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *local) reconnectContainer(ctx context.Context, n string, id string, endpoint *network.EndpointSettings) error {
+	err := s.containerService.apiClient.NetworkDisconnect(ctx, n, id, false)
+	if err != nil {
+		return classifyEngineError(err)
+	}
+	return classifyEngineError(s.containerService.apiClient.NetworkConnect(ctx, n, id, endpoint))
+}
+
+func (s *local) recreateContainer(ctx context.Context, project *types.Project, service types.ServiceConfig, container moby.Container, options compose.UpOptions) error {
+	w := progress.ContextWriter(ctx)
+	if logrus.IsLevelEnabled(logrus.DebugLevel) {
+		diff, err := getConfigDiff(container, service)
+		if err != nil {
+			return err
+		}
+		if len(diff) > 0 {
+			logrus.Debugf("recreating container %s of service %q, changed: %s", getContainerName(container), service.Name, strings.Join(diff, ", "))
+		}
+	}
+	number, err := strconv.Atoi(container.Labels[containerNumberLabel])
+	if err != nil {
+		return err
+	}
+	w.Event(progress.Event{
+		ID:         fmt.Sprintf("Service %q", service.Name),
+		Status:     progress.Working,
+		StatusText: "Recreate",
+		Done:       false,
+	})
+	lifecycle, err := getLifecycle(service)
+	if err != nil {
+		return err
+	}
+	err = s.runLifecycleHook(ctx, service, container.ID, preStop, lifecycle.PreStop)
+	if err != nil {
+		return err
+	}
+	drained, err := s.drainContainer(ctx, service, container.ID, lifecycle.DrainPeriod)
+	if err != nil {
+		return err
+	}
+	err = s.containerService.Stop(ctx, container.ID, nil)
+	if err != nil {
+		if restoreErr := s.restoreAliases(context.Background(), container.ID, drained); restoreErr != nil {
+			return errors.Wrapf(err, "failed to restore network aliases (%s)", restoreErr)
+		}
+		return err
+	}
+	name := getContainerName(container)
+	tmpName := getTemporaryName(container, name)
+	err = s.containerService.apiClient.ContainerRename(ctx, container.ID, tmpName)
+	if err != nil {
+		return err
+	}
+	inherit := container
+	if options.RenewAnonVolumes {
+		inherit.Mounts = withoutAnonymousVolumes(project, service, container.Mounts)
+	}
+	err = s.runContainer(ctx, project, service, name, number, &inherit, options)
+	if err != nil {
+		if rollbackErr := s.restoreContainer(container, name); rollbackErr != nil {
+			return errors.Wrapf(err, "failed to restore container %q (%s)", name, rollbackErr)
+		}
+		if rollbackErr := s.restoreAliases(context.Background(), container.ID, drained); rollbackErr != nil {
+			return errors.Wrapf(err, "failed to restore network aliases of container %q (%s)", name, rollbackErr)
+		}
+		return err
+	}
+	if !trackReplacedContainer(ctx, name, container, options) {
+		// engine only removes anonymous volumes, named volumes are preserved
+		err = s.containerService.apiClient.ContainerRemove(ctx, container.ID, moby.ContainerRemoveOptions{
+			RemoveVolumes: options.RenewAnonVolumes,
+		})
 		if err != nil {
 			return err
 		}
-		missing := scale - len(actual)
-		for i := 0; i < missing; i++ {
-			number := next + i
-			name := fmt.Sprintf("%s_%s_%d", project.Name, service.Name, number)
-			eg.Go(func() error {
-				return s.createContainer(ctx, project, service, name, number)
-			})
-		}
 	}
+	w.Event(progress.Event{
+		ID:         fmt.Sprintf("Service %q", service.Name),
+		Status:     progress.Done,
+		StatusText: "Recreated",
+		Done:       true,
+	})
+	setDependentLifecycle(project, service.Name, forceRecreate, options.RecreateDependents)
+	return nil
+}
 
-	if len(actual) > scale {
-		for i := scale; i < len(actual); i++ {
-			container := actual[i]
-			eg.Go(func() error {
-				err := s.containerService.Stop(ctx, container.ID, nil)
-				if err != nil {
-					return err
-				}
-				return s.containerService.Delete(ctx, container.ID, containers.DeleteRequest{})
-			})
+// withoutAnonymousVolumes filters out volumes mounts which don't refer to a named volume declared by service
+func withoutAnonymousVolumes(project *types.Project, service types.ServiceConfig, mounts []moby.MountPoint) []moby.MountPoint {
+	var named []string
+	for _, v := range service.Volumes {
+		if v.Type == "volume" && v.Source != "" {
+			name := v.Source
+			if volume, ok := project.Volumes[v.Source]; ok && volume.Name != "" {
+				name = volume.Name
+			}
+			named = append(named, name)
+		}
+	}
+	var result []moby.MountPoint
+	for _, m := range mounts {
+		if m.Type == "volume" && !contains(named, m.Name) {
+			continue
 		}
-		actual = actual[:scale]
+		result = append(result, m)
 	}
+	return result
+}
 
-	expected, err := jsonHash(service)
+// restoreContainer gives back its original name to a container renamed by recreateContainer and restarts it if it
+// was running, after removing the replacement container if it got created
+func (s *local) restoreContainer(container moby.Container, name string) error {
+	// recreate might have failed as context got cancelled, we still need to restore service in its prior state
+	ctx := context.Background()
+	err := s.containerService.apiClient.ContainerRemove(ctx, name, moby.ContainerRemoveOptions{Force: true})
+	if err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+	err = s.containerService.apiClient.ContainerRename(ctx, container.ID, name)
 	if err != nil {
 		return err
 	}
+	if container.State != "running" {
+		return nil
+	}
+	return s.containerService.apiClient.ContainerStart(ctx, container.ID, moby.ContainerStartOptions{})
+}
 
-	for _, container := range actual {
-		container := container
-		diverged := container.Labels[configHashLabel] != expected
-		if diverged || service.Extensions[extLifecycle] == forceRecreate {
-			eg.Go(func() error {
-				return s.recreateContainer(ctx, project, service, container)
-			})
+// setDependentLifecycle define the Lifecycle strategy for all services to depend on specified service, or only those
+// consuming its containers when dependents is RecreateDependentsConsumers
+func setDependentLifecycle(project *types.Project, service string, strategy string, dependents string) {
+	for i, s := range project.Services {
+		if !contains(getDependencies(s), service) {
 			continue
 		}
-
-		if container.State == "running" {
-			// already running, skip
+		if dependents == compose.RecreateDependentsConsumers && !consumes(s, service) {
 			continue
 		}
-
-		eg.Go(func() error {
-			return s.restartContainer(ctx, service, container)
-		})
+		// extensions are shared with concurrent convergence of other services, they are copied rather than updated
+		extensions := map[string]interface{}{}
+		for k, v := range s.Extensions {
+			extensions[k] = v
+		}
+		if hooks, ok := extensions[extLifecycle].(map[string]interface{}); ok {
+			lifecycle := map[string]interface{}{}
+			for k, v := range hooks {
+				lifecycle[k] = v
+			}
+			lifecycle["strategy"] = strategy
+			extensions[extLifecycle] = lifecycle
+		} else {
+			extensions[extLifecycle] = strategy
+		}
+		s.Extensions = extensions
+		project.Services[i] = s
 	}
-	return eg.Wait()
 }
 
-func (s *local) waitDependencies(ctx context.Context, project *types.Project, service types.ServiceConfig) error {
-	eg, ctx := errgroup.WithContext(ctx)
-	for dep, config := range service.DependsOn {
-		switch config.Condition {
-		case "service_healthy":
-			eg.Go(func() error {
-				ticker := time.NewTicker(500 * time.Millisecond)
-				defer ticker.Stop()
-				for {
-					<-ticker.C
-					healthy, err := s.isServiceHealthy(ctx, project, dep)
-					if err != nil {
-						return err
-					}
-					if healthy {
-						return nil
-					}
-				}
-			})
+// consumes tells if service is bound to the containers of dependency, so it has to be recreated along with those: links
+// and volumes_from refer to the containers, network_mode, ipc and pid join their namespaces. Other dependents reach
+// dependency by its service name, which resolves to the recreated containers
+func consumes(service types.ServiceConfig, dependency string) bool {
+	for _, link := range service.Links {
+		if name, _ := parseLink(link); name == dependency {
+			return true
 		}
 	}
-	return eg.Wait()
-}
-
-func nextContainerNumber(containers []moby.Container) (int, error) {
-	max := 0
-	for _, c := range containers {
-		n, err := strconv.Atoi(c.Labels[containerNumberLabel])
-		if err != nil {
-			return 0, err
+	for _, volumes := range service.VolumesFrom {
+		// volumes_from is set as `service[:mode]`, or `container:name[:mode]` for external containers
+		if strings.Split(volumes, ":")[0] == dependency {
+			return true
 		}
-		if n > max {
-			max = n
+	}
+	for _, mode := range []string{service.NetworkMode, service.Ipc, service.Pid} {
+		if mode == "service:"+dependency {
+			return true
 		}
 	}
-	return max + 1, nil
+	return false
+}
 
+// lifecycle is the x-lifecycle service extension. It is set either as a plain recreate strategy, or as a
+// mapping declaring commands to be ran inside service containers on lifecycle events:
+//
+//	x-lifecycle:
+//	  post_start: ["/ready.sh", "--wait"]
+//	  pre_stop: ["/drain.sh"]
+type lifecycle struct {
+	Strategy  string   `json:"strategy,omitempty"`
+	PostStart []string `json:"post_start,omitempty"`
+	PreStop   []string `json:"pre_stop,omitempty"`
+	// DrainPeriod is the duration a recreated container is kept running after it got removed from service
+	// aliases, so in-flight requests complete
+	DrainPeriod string `json:"drain_period,omitempty"`
 }
 
-func getScale(config types.ServiceConfig) int {
-	if config.Deploy != nil && config.Deploy.Replicas != nil {
-		return int(*config.Deploy.Replicas)
-	}
-	if config.Scale != 0 {
-		return config.Scale
+const (
+	postStart = "post_start"
+	preStop   = "pre_stop"
+)
+
+func getLifecycle(service types.ServiceConfig) (lifecycle, error) {
+	switch v := service.Extensions[extLifecycle].(type) {
+	case nil:
+		return lifecycle{}, nil
+	case string:
+		return lifecycle{Strategy: v}, nil
+	default:
+		var l lifecycle
+		marshalled, err := json.Marshal(v)
+		if err != nil {
+			return l, err
+		}
+		err = json.Unmarshal(marshalled, &l)
+		if err != nil {
+			return l, errors.Wrapf(err, "invalid %s for service %q", extLifecycle, service.Name)
+		}
+		return l, nil
 	}
-	return 1
 }
 
-func (s *local) createContainer(ctx context.Context, project *types.Project, service types.ServiceConfig, name string, number int) error {
+// runLifecycleHook runs the command declared for a lifecycle hook inside the container and waits for its completion
+func (s *local) runLifecycleHook(ctx context.Context, service types.ServiceConfig, containerID string, hook string, command []string) error {
+	if len(command) == 0 {
+		return nil
+	}
 	w := progress.ContextWriter(ctx)
+	eventName := fmt.Sprintf("Service %q", service.Name)
 	w.Event(progress.Event{
-		ID:         fmt.Sprintf("Service %q", service.Name),
+		ID:         eventName,
 		Status:     progress.Working,
-		StatusText: "Create",
+		StatusText: fmt.Sprintf("Run %s hook", hook),
 		Done:       false,
 	})
-	err := s.runContainer(ctx, project, service, name, number, nil)
+	err := s.execAndWait(ctx, containerID, command)
 	if err != nil {
-		return err
+		w.Event(progress.Event{
+			ID:         eventName,
+			Status:     progress.Error,
+			StatusText: fmt.Sprintf("%s hook failed", hook),
+			Done:       true,
+		})
+		return errors.Wrapf(err, "%s hook failed for service %q", hook, service.Name)
 	}
 	w.Event(progress.Event{
-		ID:         fmt.Sprintf("Service %q", service.Name),
+		ID:         eventName,
 		Status:     progress.Done,
-		StatusText: "Created",
+		StatusText: fmt.Sprintf("Ran %s hook", hook),
 		Done:       true,
 	})
 	return nil
 }
 
-func (s *local) recreateContainer(ctx context.Context, project *types.Project, service types.ServiceConfig, container moby.Container) error {
-	w := progress.ContextWriter(ctx)
-	w.Event(progress.Event{
-		ID:         fmt.Sprintf("Service %q", service.Name),
-		Status:     progress.Working,
-		StatusText: "Recreate",
-		Done:       false,
+func (s *local) execAndWait(ctx context.Context, containerID string, command []string) error {
+	exec, err := s.containerService.apiClient.ContainerExecCreate(ctx, containerID, moby.ExecConfig{
+		Cmd:    command,
+		Detach: true,
 	})
-	err := s.containerService.Stop(ctx, container.ID, nil)
 	if err != nil {
 		return err
 	}
-	name := getContainerName(container)
-	tmpName := fmt.Sprintf("%s_%s", container.ID[:12], name)
-	err = s.containerService.apiClient.ContainerRename(ctx, container.ID, tmpName)
-	if err != nil {
-		return err
-	}
-	number, err := strconv.Atoi(container.Labels[containerNumberLabel])
-	if err != nil {
-		return err
-	}
-	err = s.runContainer(ctx, project, service, name, number, &container)
-	if err != nil {
-		return err
-	}
-	err = s.containerService.Delete(ctx, container.ID, containers.DeleteRequest{})
+	err = s.containerService.apiClient.ContainerExecStart(ctx, exec.ID, moby.ExecStartCheck{
+		Detach: true,
+	})
 	if err != nil {
 		return err
 	}
-	w.Event(progress.Event{
-		ID:         fmt.Sprintf("Service %q", service.Name),
-		Status:     progress.Done,
-		StatusText: "Recreated",
-		Done:       true,
-	})
-	setDependentLifecycle(project, service.Name, forceRecreate)
-	return nil
-}
-
-// setDependentLifecycle define the Lifecycle strategy for all services to depend on specified service
-func setDependentLifecycle(project *types.Project, service string, strategy string) {
-	for i, s := range project.Services {
-		if contains(s.GetDependencies(), service) {
-			if s.Extensions == nil {
-				s.Extensions = map[string]interface{}{}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		inspect, err := s.containerService.apiClient.ContainerExecInspect(ctx, exec.ID)
+		if err != nil {
+			return err
+		}
+		if !inspect.Running {
+			if inspect.ExitCode != 0 {
+				return fmt.Errorf("command %q exited with code %d", strings.Join(command, " "), inspect.ExitCode)
 			}
-			s.Extensions[extLifecycle] = strategy
-			project.Services[i] = s
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
 		}
 	}
 }
@@ -258,65 +1193,355 @@ func (s *local) restartContainer(ctx context.Context, service types.ServiceConfi
 	return nil
 }
 
-func (s *local) runContainer(ctx context.Context, project *types.Project, service types.ServiceConfig, name string, number int, container *moby.Container) error {
+func (s *local) runContainer(ctx context.Context, project *types.Project, service types.ServiceConfig, name string, number int, container *moby.Container, options compose.UpOptions) error {
 	containerConfig, hostConfig, networkingConfig, err := getContainerCreateOptions(project, service, number, container)
 	if err != nil {
 		return err
 	}
-	id, err := s.containerService.create(ctx, containerConfig, hostConfig, networkingConfig, name)
-	if err != nil {
-		return err
-	}
-	for net := range service.Networks {
-		name := fmt.Sprintf("%s_%s", project.Name, net)
-		err = s.connectContainerToNetwork(ctx, id, service.Name, name)
+	if options.RecreateChangedFiles {
+		// only hashed when opted in, as all files defining secrets and configs are read
+		filesHash, err := getFileReferencesHash(project, service)
 		if err != nil {
 			return err
 		}
+		containerConfig.Labels[fileReferencesHashLabel] = filesHash
+	}
+	id, err := s.createServiceContainer(ctx, project, service, name, containerConfig, hostConfig, networkingConfig)
+	if err != nil {
+		return err
 	}
 	err = s.containerService.apiClient.ContainerStart(ctx, id, moby.ContainerStartOptions{})
+	if err != nil {
+		return classifyEngineError(err)
+	}
+	lifecycle, err := getLifecycle(service)
 	if err != nil {
 		return err
 	}
+	return s.runLifecycleHook(ctx, service, id, postStart, lifecycle.PostStart)
+}
+
+// createServiceContainer creates a container for service and connects it to the service networks
+func (s *local) createServiceContainer(ctx context.Context, project *types.Project, service types.ServiceConfig, name string,
+	containerConfig *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig) (string, error) {
+	links, err := s.getLinks(ctx, project, service)
+	if err != nil {
+		return "", err
+	}
+	err = s.resolveServiceReferences(ctx, project, service, hostConfig)
+	if err != nil {
+		return "", err
+	}
+	for _, endpoint := range networkingConfig.EndpointsConfig {
+		endpoint.Links = links
+	}
+	id, err := s.containerService.create(ctx, containerConfig, hostConfig, networkingConfig, name)
+	if err != nil {
+		return "", classifyEngineError(err)
+	}
+	trackCreatedContainer(ctx, id, name)
+	for _, net := range getNetworksByPriority(service) {
+		name := project.Networks[net].Name
+		if _, ok := networkingConfig.EndpointsConfig[name]; ok || len(service.Networks) == 0 || service.NetworkMode != "" {
+			// attached on create, as is the default network, or not using project networks
+			continue
+		}
+		err = s.connectContainerToNetwork(ctx, id, service.Name, name, links)
+		if err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+// connectContainerToNetwork connects container to network, retrying a bounded number of times on transient
+// failures, as a network being created or another container being connected concurrently can make engine fail
+func (s *local) connectContainerToNetwork(ctx context.Context, id string, service string, n string, links []string) error {
+	delay := networkConnectRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := s.containerService.apiClient.NetworkConnect(ctx, n, id, &network.EndpointSettings{
+			Aliases: []string{service},
+			Links:   links,
+		})
+		if err == nil || attempt == networkConnectAttempts || !isTransientNetworkError(err) {
+			return classifyEngineError(err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// getLinks resolves service's `links` and `external_links` as container:alias, so linked containers resolve by link alias
+func (s *local) getLinks(ctx context.Context, project *types.Project, service types.ServiceConfig) ([]string, error) {
+	var links []string
+	for _, l := range service.Links {
+		name, alias := parseLink(l)
+		if _, err := project.GetService(name); err != nil {
+			return nil, fmt.Errorf("service %q links to undefined service %q", service.Name, name)
+		}
+		linked, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
+			Filters: filters.NewArgs(
+				filters.Arg("label", fmt.Sprintf("%s=%s", projectLabel, project.Name)),
+				filters.Arg("label", fmt.Sprintf("%s=%s", serviceLabel, name)),
+			),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range linked {
+			links = append(links, fmt.Sprintf("%s:%s", getContainerName(c), alias))
+		}
+	}
+	for _, l := range service.ExternalLinks {
+		name, alias := parseLink(l)
+		links = append(links, fmt.Sprintf("%s:%s", name, alias))
+	}
+	return links, nil
+}
+
+// resolveServiceReferences replaces namespaces shared with a service, set as `service:name`, and services volumes are
+// mounted from by an existing container of that service, which replicas can't be assumed to be numbered from 1
+func (s *local) resolveServiceReferences(ctx context.Context, project *types.Project, service types.ServiceConfig, hostConfig *container.HostConfig) error {
+	if name := strings.TrimPrefix(service.NetworkMode, "service:"); name != service.NetworkMode {
+		id, err := s.getServiceContainerID(ctx, project, service, "network_mode", name)
+		if err != nil {
+			return err
+		}
+		hostConfig.NetworkMode = container.NetworkMode("container:" + id)
+	}
+	if name := strings.TrimPrefix(service.Ipc, "service:"); name != service.Ipc {
+		id, err := s.getServiceContainerID(ctx, project, service, "ipc", name)
+		if err != nil {
+			return err
+		}
+		hostConfig.IpcMode = container.IpcMode("container:" + id)
+	}
+	if name := strings.TrimPrefix(service.Pid, "service:"); name != service.Pid {
+		id, err := s.getServiceContainerID(ctx, project, service, "pid", name)
+		if err != nil {
+			return err
+		}
+		hostConfig.PidMode = container.PidMode("container:" + id)
+	}
+	// getVolumesFrom sets an entry for each volumes_from, services being kept as `service[:mode]`
+	for i, v := range service.VolumesFrom {
+		name := strings.Split(v, ":")[0]
+		if name == "container" {
+			continue
+		}
+		id, err := s.getServiceContainerID(ctx, project, service, "volumes_from", name)
+		if err != nil {
+			return err
+		}
+		hostConfig.VolumesFrom[i] = id + strings.TrimPrefix(hostConfig.VolumesFrom[i], name)
+	}
 	return nil
 }
 
-func (s *local) connectContainerToNetwork(ctx context.Context, id string, service string, n string) error {
-	err := s.containerService.apiClient.NetworkConnect(ctx, n, id, &network.EndpointSettings{
-		Aliases: []string{service},
+// getServiceContainerID returns the container of the service referred to by service kind setting, preferring running
+// ones and then the lowest replica number
+func (s *local) getServiceContainerID(ctx context.Context, project *types.Project, service types.ServiceConfig, kind string, name string) (string, error) {
+	target, err := project.GetService(name)
+	if err != nil {
+		return "", fmt.Errorf("service %q %s refers to undefined service %q", service.Name, kind, name)
+	}
+	containers, err := s.getServiceContainers(ctx, project, target)
+	if err != nil {
+		return "", err
+	}
+	if len(containers) == 0 {
+		return "", fmt.Errorf("service %q %s refers to service %q, which has no container", service.Name, kind, name)
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		if running := containers[i].State == "running"; running != (containers[j].State == "running") {
+			return running
+		}
+		return getContainerNumber(containers[i]) < getContainerNumber(containers[j])
+	})
+	return containers[0].ID, nil
+}
+
+// parseLink splits a `name[:alias]` link definition, alias defaulting to name
+func parseLink(link string) (string, string) {
+	parts := strings.SplitN(link, ":", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return link, link
+}
+
+// isServiceHealthy checks service containers are all healthy, and returns their health status
+func (s *local) isServiceHealthy(ctx context.Context, project *types.Project, service string) (bool, string, error) {
+	containers, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", projectLabel, project.Name)),
+			filters.Arg("label", fmt.Sprintf("%s=%s", serviceLabel, service)),
+		),
 	})
 	if err != nil {
-		return err
+		return false, "", err
 	}
-	return nil
+
+	var statuses []string
+	healthy := true
+	for _, c := range withoutOneOffContainers(containers) {
+		container, err := s.containerService.apiClient.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			return false, "", err
+		}
+		ok, err := isHealthy(container)
+		if err != nil {
+			return false, "", errors.Wrapf(err, "container for service %q", service)
+		}
+		healthy = healthy && ok
+		statuses = append(statuses, container.State.Health.Status)
+	}
+	return healthy, strings.Join(statuses, ","), nil
 }
 
-func (s *local) isServiceHealthy(ctx context.Context, project *types.Project, service string) (bool, error) {
+// isServiceRunning checks service has containers and they are all running, and returns their state
+func (s *local) isServiceRunning(ctx context.Context, project *types.Project, service string) (bool, string, error) {
 	containers, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
 		Filters: filters.NewArgs(
 			filters.Arg("label", fmt.Sprintf("%s=%s", projectLabel, project.Name)),
 			filters.Arg("label", fmt.Sprintf("%s=%s", serviceLabel, service)),
 		),
+		All: true,
 	})
 	if err != nil {
-		return false, err
+		return false, "", err
+	}
+	containers = withoutOneOffContainers(containers)
+	var states []string
+	running := len(containers) > 0
+	for _, c := range containers {
+		running = running && c.State == "running"
+		states = append(states, c.State)
 	}
+	return running, strings.Join(states, ","), nil
+}
 
+// isServicePortOpen checks service has running containers and a TCP connection can be established to port on all of
+// them, dialing containers address on the first network they are attached to
+func (s *local) isServicePortOpen(ctx context.Context, project *types.Project, service string, port int) (bool, string, error) {
+	containers, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", projectLabel, project.Name)),
+			filters.Arg("label", fmt.Sprintf("%s=%s", serviceLabel, service)),
+		),
+	})
+	if err != nil {
+		return false, "", err
+	}
+	containers = withoutOneOffContainers(containers)
+	if len(containers) == 0 {
+		return false, "not running", nil
+	}
 	for _, c := range containers {
 		container, err := s.containerService.apiClient.ContainerInspect(ctx, c.ID)
 		if err != nil {
-			return false, err
+			return false, "", err
 		}
-		if container.State == nil || container.State.Health == nil {
-			return false, fmt.Errorf("container for service %q has no healthcheck configured", service)
+		address := getContainerAddress(container)
+		if address == "" {
+			return false, "no address", nil
 		}
-		switch container.State.Health.Status {
-		case "starting":
-			return false, nil
-		case "unhealthy":
-			return false, nil
+		if !isPortOpen(ctx, net.JoinHostPort(address, strconv.Itoa(port))) {
+			return false, "closed", nil
 		}
 	}
-	return true, nil
+	return true, "open", nil
+}
+
+// getContainerAddress returns the IP address container can be reached at, containers using host network being
+// reached on loopback
+func getContainerAddress(container moby.ContainerJSON) string {
+	if container.HostConfig != nil && container.HostConfig.NetworkMode.IsHost() {
+		return "127.0.0.1"
+	}
+	if container.NetworkSettings == nil {
+		return ""
+	}
+	var names []string
+	for name := range container.NetworkSettings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if endpoint := container.NetworkSettings.Networks[name]; endpoint != nil && endpoint.IPAddress != "" {
+			return endpoint.IPAddress
+		}
+	}
+	return container.NetworkSettings.IPAddress
+}
+
+func isPortOpen(ctx context.Context, address string) bool {
+	dialer := net.Dialer{Timeout: time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+func (s *local) isContainerRunning(ctx context.Context, name string) (bool, string, error) {
+	container, err := s.containerService.apiClient.ContainerInspect(ctx, name)
+	if err != nil {
+		return false, "", err
+	}
+	if container.State == nil {
+		return false, "", nil
+	}
+	return container.State.Running, container.State.Status, nil
+}
+
+func (s *local) isContainerHealthy(ctx context.Context, name string) (bool, string, error) {
+	container, err := s.containerService.apiClient.ContainerInspect(ctx, name)
+	if err != nil {
+		return false, "", err
+	}
+	healthy, err := isHealthy(container)
+	if err != nil {
+		return false, "", errors.Wrapf(err, "container %q", name)
+	}
+	return healthy, container.State.Health.Status, nil
+}
+
+// isContainerUnhealthy checks container healthcheck reported a failure. Containers without healthcheck are never unhealthy
+func (s *local) isContainerUnhealthy(ctx context.Context, id string) (bool, error) {
+	container, err := s.containerService.apiClient.ContainerInspect(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return container.State != nil && container.State.Health != nil && container.State.Health.Status == "unhealthy", nil
+}
+
+// isHealthCheckDisabled checks service healthcheck is explicitly disabled, so health can't be waited on
+func isHealthCheckDisabled(project *types.Project, service string) bool {
+	config, err := project.GetService(service)
+	return err == nil && config.HealthCheck != nil && config.HealthCheck.Disable
+}
 
+func isHealthy(container moby.ContainerJSON) (bool, error) {
+	if container.Config != nil && container.Config.Healthcheck != nil &&
+		len(container.Config.Healthcheck.Test) > 0 && container.Config.Healthcheck.Test[0] == "NONE" {
+		return false, fmt.Errorf("healthcheck is disabled")
+	}
+	if container.State == nil || container.State.Health == nil {
+		return false, fmt.Errorf("no healthcheck configured")
+	}
+	switch container.State.Health.Status {
+	case "starting":
+		return false, nil
+	case "unhealthy":
+		return false, nil
+	}
+	return true, nil
 }
@@ -0,0 +1,1322 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/compose-spec/compose-go/types"
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/progress"
+)
+
+func TestMustRecreate(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:  "test",
+		Image: "nginx",
+	}
+	expected, err := jsonHash(service)
+	assert.NilError(t, err)
+
+	assert.Assert(t, !mustRecreate(service, moby.Container{
+		Image:  "nginx",
+		Labels: map[string]string{configHashLabel: expected, configHashVersionLabel: configHashVersion},
+	}, expected, ""))
+	assert.Assert(t, mustRecreate(service, moby.Container{
+		Image:  "nginx",
+		Labels: map[string]string{configHashLabel: "sha256:diverged", configHashVersionLabel: configHashVersion},
+	}, expected, ""))
+	// containers created before version label was introduced use the current algorithm
+	assert.Assert(t, mustRecreate(service, moby.Container{
+		Image:  "nginx",
+		Labels: map[string]string{configHashLabel: "sha256:diverged"},
+	}, expected, ""))
+}
+
+func TestMustRecreateIgnoresHashFromAnotherVersion(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:  "test",
+		Image: "nginx",
+	}
+	expected, err := jsonHash(service)
+	assert.NilError(t, err)
+
+	old := moby.Container{
+		Image:  "nginx",
+		Labels: map[string]string{configHashLabel: "md5:computed-by-legacy-algorithm", configHashVersionLabel: "0"},
+	}
+	assert.Assert(t, !mustRecreate(service, old, expected, ""))
+
+	old.Image = "nginx:old"
+	assert.Assert(t, mustRecreate(service, old, expected, ""))
+}
+
+func TestGetLinks(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, moby.ContainerListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", projectLabel, "myproject")),
+			filters.Arg("label", fmt.Sprintf("%s=%s", serviceLabel, "db")),
+		),
+	}).Return([]moby.Container{
+		{ID: "123", Names: []string{"/myproject_db_1"}},
+	}, nil)
+	s := newMockBackend(apiClient)
+
+	project := &types.Project{
+		Name: "myproject",
+		Services: []types.ServiceConfig{
+			{
+				Name:          "web",
+				Links:         []string{"db:database"},
+				ExternalLinks: []string{"shared_redis:redis", "shared_cache"},
+			},
+			{
+				Name: "db",
+			},
+		},
+	}
+	links, err := s.getLinks(context.TODO(), project, project.Services[0])
+	assert.NilError(t, err)
+	assert.DeepEqual(t, links, []string{"myproject_db_1:database", "shared_redis:redis", "shared_cache:shared_cache"})
+	apiClient.AssertExpectations(t)
+}
+
+func TestGetLinksToUndefinedService(t *testing.T) {
+	s := newMockBackend(&mockAPIClient{})
+	project := &types.Project{
+		Name: "myproject",
+		Services: []types.ServiceConfig{
+			{
+				Name:  "web",
+				Links: []string{"db:database"},
+			},
+		},
+	}
+	_, err := s.getLinks(context.TODO(), project, project.Services[0])
+	assert.Error(t, err, `service "web" links to undefined service "db"`)
+}
+
+func TestWaitExternalDependency(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerInspect", mock.Anything, "shared_db").Return(withHealth("starting"), nil).Once()
+	apiClient.On("ContainerInspect", mock.Anything, "shared_db").Return(withHealth("healthy"), nil).Once()
+	s := newMockBackend(apiClient)
+
+	service := types.ServiceConfig{
+		Name: "web",
+		Extensions: map[string]interface{}{
+			extExternalDependsOn: map[string]interface{}{
+				"shared_db": map[string]interface{}{"condition": types.ServiceConditionHealthy},
+			},
+		},
+	}
+	err := s.waitDependencies(context.TODO(), &types.Project{Name: "myproject"}, service)
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+}
+
+func withHealth(status string) moby.ContainerJSON {
+	return moby.ContainerJSON{
+		ContainerJSONBase: &moby.ContainerJSONBase{
+			State: &moby.ContainerState{
+				Health: &moby.Health{Status: status},
+			},
+		},
+	}
+}
+
+func TestGetLifecycle(t *testing.T) {
+	l, err := getLifecycle(types.ServiceConfig{
+		Extensions: map[string]interface{}{extLifecycle: forceRecreate},
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, l, lifecycle{Strategy: forceRecreate})
+
+	l, err = getLifecycle(types.ServiceConfig{
+		Extensions: map[string]interface{}{extLifecycle: map[string]interface{}{
+			"post_start": []interface{}{"/ready.sh", "--wait"},
+			"pre_stop":   []interface{}{"/drain.sh"},
+		}},
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, l, lifecycle{PostStart: []string{"/ready.sh", "--wait"}, PreStop: []string{"/drain.sh"}})
+}
+
+func TestSetDependentLifecyclePreservesHooks(t *testing.T) {
+	hooks := map[string]interface{}{
+		"post_start": []interface{}{"/ready.sh"},
+	}
+	project := &types.Project{
+		Services: []types.ServiceConfig{
+			{
+				Name:       "web",
+				DependsOn:  types.DependsOnConfig{"db": {}},
+				Extensions: map[string]interface{}{extLifecycle: hooks},
+			},
+			{
+				Name: "db",
+			},
+		},
+	}
+	setDependentLifecycle(project, "db", forceRecreate, compose.RecreateDependentsAll)
+	l, err := getLifecycle(project.Services[0])
+	assert.NilError(t, err)
+	assert.DeepEqual(t, l, lifecycle{Strategy: forceRecreate, PostStart: []string{"/ready.sh"}})
+	// lifecycle set by user isn't modified
+	assert.DeepEqual(t, hooks, map[string]interface{}{"post_start": []interface{}{"/ready.sh"}})
+}
+
+func TestSetDependentLifecycleOnlyForConsumers(t *testing.T) {
+	project := &types.Project{
+		Services: []types.ServiceConfig{
+			{Name: "web", DependsOn: types.DependsOnConfig{"db": {}}},
+			{Name: "legacy", Links: []string{"db:database"}},
+			{Name: "backup", DependsOn: types.DependsOnConfig{"db": {}}, VolumesFrom: []string{"db:ro"}},
+			{Name: "exporter", NetworkMode: "service:db"},
+			{Name: "db"},
+		},
+	}
+	setDependentLifecycle(project, "db", forceRecreate, compose.RecreateDependentsConsumers)
+	var recreated []string
+	for _, service := range project.Services {
+		l, err := getLifecycle(service)
+		assert.NilError(t, err)
+		if l.Strategy == forceRecreate {
+			recreated = append(recreated, service.Name)
+		}
+	}
+	assert.DeepEqual(t, recreated, []string{"legacy", "backup", "exporter"})
+
+	setDependentLifecycle(project, "db", forceRecreate, compose.RecreateDependentsAll)
+	l, err := getLifecycle(project.Services[0])
+	assert.NilError(t, err)
+	assert.Equal(t, l.Strategy, forceRecreate)
+}
+
+func TestRunPostStartHook(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerExecCreate", mock.Anything, "123", moby.ExecConfig{Cmd: []string{"/ready.sh"}, Detach: true}).
+		Return(moby.IDResponse{ID: "exec1"}, nil)
+	apiClient.On("ContainerExecStart", mock.Anything, "exec1", moby.ExecStartCheck{Detach: true}).Return(nil)
+	apiClient.On("ContainerExecInspect", mock.Anything, "exec1").Return(moby.ContainerExecInspect{Running: true}, nil).Once()
+	apiClient.On("ContainerExecInspect", mock.Anything, "exec1").Return(moby.ContainerExecInspect{ExitCode: 0}, nil).Once()
+	s := newMockBackend(apiClient)
+
+	err := s.runLifecycleHook(context.TODO(), types.ServiceConfig{Name: "web"}, "123", postStart, []string{"/ready.sh"})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+}
+
+func TestRunPostStartHookFailure(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerExecCreate", mock.Anything, "123", moby.ExecConfig{Cmd: []string{"/ready.sh"}, Detach: true}).
+		Return(moby.IDResponse{ID: "exec1"}, nil)
+	apiClient.On("ContainerExecStart", mock.Anything, "exec1", moby.ExecStartCheck{Detach: true}).Return(nil)
+	apiClient.On("ContainerExecInspect", mock.Anything, "exec1").Return(moby.ContainerExecInspect{ExitCode: 1}, nil)
+	s := newMockBackend(apiClient)
+
+	err := s.runLifecycleHook(context.TODO(), types.ServiceConfig{Name: "web"}, "123", postStart, []string{"/ready.sh"})
+	assert.Error(t, err, `post_start hook failed for service "web": command "/ready.sh" exited with code 1`)
+}
+
+func TestRecreateContainerRestoresContainerOnFailure(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerStop", mock.Anything, "123456789012345", mock.Anything).Return(nil)
+	apiClient.On("ContainerRename", mock.Anything, "123456789012345", "123456789012_myproject_web_1").Return(nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+		Return(container.ContainerCreateCreatedBody{}, errors.New("create failed"))
+	apiClient.On("ContainerRemove", mock.Anything, "myproject_web_1", moby.ContainerRemoveOptions{Force: true}).
+		Return(errdefs.NotFound(errors.New("no such container")))
+	apiClient.On("ContainerRename", mock.Anything, "123456789012345", "myproject_web_1").Return(nil)
+	apiClient.On("ContainerStart", mock.Anything, "123456789012345", moby.ContainerStartOptions{}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	project := &types.Project{
+		Name: "myproject",
+		Services: []types.ServiceConfig{
+			{
+				Name:  "web",
+				Image: "nginx",
+			},
+		},
+	}
+	err := s.recreateContainer(context.TODO(), project, project.Services[0], moby.Container{
+		ID:     "123456789012345",
+		Names:  []string{"/myproject_web_1"},
+		State:  "running",
+		Labels: map[string]string{containerNumberLabel: "1"},
+	}, compose.UpOptions{})
+	assert.Error(t, err, "create failed")
+	apiClient.AssertExpectations(t)
+}
+
+func TestRecreateContainerRestoresStoppedContainerOnFailure(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerStop", mock.Anything, "123456789012345", mock.Anything).Return(nil)
+	apiClient.On("ContainerRename", mock.Anything, "123456789012345", "123456789012_myproject_web_1").Return(nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+		Return(container.ContainerCreateCreatedBody{}, errors.New("create failed"))
+	apiClient.On("ContainerRemove", mock.Anything, "myproject_web_1", moby.ContainerRemoveOptions{Force: true}).
+		Return(errdefs.NotFound(errors.New("no such container")))
+	apiClient.On("ContainerRename", mock.Anything, "123456789012345", "myproject_web_1").Return(nil)
+	s := newMockBackend(apiClient)
+
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{{Name: "web", Image: "nginx"}}}
+	err := s.recreateContainer(context.TODO(), project, project.Services[0], moby.Container{
+		ID:     "123456789012345",
+		Names:  []string{"/myproject_web_1"},
+		State:  "exited",
+		Labels: map[string]string{containerNumberLabel: "1"},
+	}, compose.UpOptions{})
+	assert.Error(t, err, "create failed")
+	apiClient.AssertExpectations(t)
+	// container was stopped before recreate, it is left stopped
+	apiClient.AssertNotCalled(t, "ContainerStart", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRecreateContainerWithInvalidNumber(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	s := newMockBackend(apiClient)
+
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{{Name: "web", Image: "nginx"}}}
+	err := s.recreateContainer(context.TODO(), project, project.Services[0], moby.Container{
+		ID:     "123456789012345",
+		Names:  []string{"/myproject_web_1"},
+		State:  "running",
+		Labels: map[string]string{containerNumberLabel: "one"},
+	}, compose.UpOptions{})
+	assert.ErrorContains(t, err, "invalid syntax")
+	// container is left untouched
+	apiClient.AssertNotCalled(t, "ContainerStop", mock.Anything, mock.Anything, mock.Anything)
+	apiClient.AssertNotCalled(t, "ContainerRename", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRecreateDrainsConnections(t *testing.T) {
+	var (
+		mtx   sync.Mutex
+		calls []string
+	)
+	record := func(call string) func(mock.Arguments) {
+		return func(mock.Arguments) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			calls = append(calls, call)
+		}
+	}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerInspect", mock.Anything, "123456789012345").Return(moby.ContainerJSON{
+		NetworkSettings: &moby.NetworkSettings{Networks: map[string]*network.EndpointSettings{
+			"myproject_default": {Aliases: []string{"web", "123456789012"}},
+			"bridge":            {},
+		}},
+	}, nil)
+	apiClient.On("NetworkDisconnect", mock.Anything, "myproject_default", "123456789012345", false).Run(record("disconnect")).Return(nil).Once()
+	apiClient.On("NetworkConnect", mock.Anything, "myproject_default", "123456789012345", &network.EndpointSettings{Aliases: []string{"123456789012"}}).
+		Run(record("connect")).Return(nil).Once()
+	apiClient.On("ContainerStop", mock.Anything, "123456789012345", mock.Anything).Run(record("stop")).Return(nil)
+	apiClient.On("ContainerRename", mock.Anything, "123456789012345", "123456789012_myproject_web_1").Return(nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+		Return(container.ContainerCreateCreatedBody{ID: "abc"}, nil)
+	apiClient.On("ContainerStart", mock.Anything, "abc", moby.ContainerStartOptions{}).Return(nil)
+	apiClient.On("ContainerRemove", mock.Anything, "123456789012345", moby.ContainerRemoveOptions{}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	service := types.ServiceConfig{
+		Name:       "web",
+		Image:      "nginx",
+		Extensions: map[string]interface{}{extLifecycle: map[string]interface{}{"drain_period": "50ms"}},
+	}
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+	start := time.Now()
+	err := s.recreateContainer(context.TODO(), project, service, moby.Container{
+		ID:     "123456789012345",
+		Names:  []string{"/myproject_web_1"},
+		Labels: map[string]string{containerNumberLabel: "1"},
+	}, compose.UpOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	assert.DeepEqual(t, calls, []string{"disconnect", "connect", "stop"})
+	assert.Assert(t, time.Since(start) >= 50*time.Millisecond)
+}
+
+func TestWaitDependencyWithoutCondition(t *testing.T) {
+	listOptions := moby.ContainerListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", projectLabel, "myproject")),
+			filters.Arg("label", fmt.Sprintf("%s=%s", serviceLabel, "db")),
+		),
+		All: true,
+	}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, listOptions).Return([]moby.Container{{ID: "c1", State: "created"}}, nil).Once()
+	apiClient.On("ContainerList", mock.Anything, listOptions).Return([]moby.Container{{ID: "c1", State: "running"}}, nil).Once()
+	s := newMockBackend(apiClient)
+
+	// depends_on declared as a list, without condition
+	service := types.ServiceConfig{
+		Name: "web",
+		DependsOn: types.DependsOnConfig{
+			"db": {},
+		},
+	}
+	err := s.waitDependencies(context.TODO(), &types.Project{Name: "myproject"}, service)
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+}
+
+func TestScaleDownRemovesHighestReplicas(t *testing.T) {
+	replicas := uint64(1)
+	service := types.ServiceConfig{
+		Name:   "web",
+		Image:  "nginx",
+		Deploy: &types.DeployConfig{Replicas: &replicas},
+	}
+	hash, err := jsonHash(service)
+	assert.NilError(t, err)
+	replica := func(number string) moby.Container {
+		return moby.Container{
+			ID:     "c" + number,
+			Image:  "nginx",
+			State:  "running",
+			Labels: map[string]string{containerNumberLabel: number, configHashLabel: hash},
+		}
+	}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{replica("3"), replica("1"), replica("2")}, nil)
+	apiClient.On("ContainerStop", mock.Anything, "c2", mock.Anything).Return(nil).Once()
+	apiClient.On("ContainerRemove", mock.Anything, "c2", moby.ContainerRemoveOptions{}).Return(nil).Once()
+	apiClient.On("ContainerStop", mock.Anything, "c3", mock.Anything).Return(nil).Once()
+	apiClient.On("ContainerRemove", mock.Anything, "c3", moby.ContainerRemoveOptions{}).Return(nil).Once()
+	s := newMockBackend(apiClient)
+
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+	err = s.ensureService(context.TODO(), project, service, compose.UpOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	apiClient.AssertNotCalled(t, "ContainerStop", mock.Anything, "c1", mock.Anything)
+}
+
+func TestScaleOverrideWinsOverDeclaredReplicas(t *testing.T) {
+	replicas := uint64(1)
+	service := types.ServiceConfig{
+		Name:   "web",
+		Image:  "nginx",
+		Deploy: &types.DeployConfig{Replicas: &replicas},
+	}
+	hash, err := jsonHash(service)
+	assert.NilError(t, err)
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{{
+		ID:     "c1",
+		Image:  "nginx",
+		State:  "running",
+		Labels: map[string]string{containerNumberLabel: "1", configHashLabel: hash},
+	}}, nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_2").
+		Return(container.ContainerCreateCreatedBody{ID: "c2"}, nil).Once()
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_3").
+		Return(container.ContainerCreateCreatedBody{ID: "c3"}, nil).Once()
+	apiClient.On("ContainerStart", mock.Anything, mock.Anything, moby.ContainerStartOptions{}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+	err = s.ensureService(context.TODO(), project, service, compose.UpOptions{Scale: map[string]int{"web": 3}})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	apiClient.AssertNotCalled(t, "ContainerStop", mock.Anything, "c1", mock.Anything)
+	assert.Equal(t, *project.Services[0].Deploy.Replicas, uint64(1))
+}
+
+func TestScaleUpBoundsReplicasWhileRecreating(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+	}
+	replica := func(number string) moby.Container {
+		return moby.Container{
+			ID:     "12345678901" + number,
+			Names:  []string{"/myproject_web_" + number},
+			Image:  "nginx",
+			State:  "running",
+			Labels: map[string]string{containerNumberLabel: number, configHashLabel: "outdated"},
+		}
+	}
+
+	var (
+		mtx     sync.Mutex
+		running = 2
+		max     = 2
+		calls   []string
+	)
+	track := func(call string, delta int) func(mock.Arguments) {
+		return func(args mock.Arguments) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			running += delta
+			if running > max {
+				max = running
+			}
+			calls = append(calls, call)
+		}
+	}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{replica("2"), replica("1")}, nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(container.ContainerCreateCreatedBody{ID: "new"}, nil)
+	apiClient.On("ContainerStart", mock.Anything, "new", moby.ContainerStartOptions{}).Run(track("start", 1)).Return(nil)
+	for _, id := range []string{"123456789011", "123456789012"} {
+		apiClient.On("ContainerStop", mock.Anything, id, mock.Anything).Run(track("stop "+id, -1)).Return(nil).Once()
+		apiClient.On("ContainerRename", mock.Anything, id, mock.Anything).Return(nil).Once()
+		apiClient.On("ContainerRemove", mock.Anything, id, moby.ContainerRemoveOptions{}).Return(nil).Once()
+	}
+	s := newMockBackend(apiClient)
+
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+	err := s.ensureService(context.TODO(), project, service, compose.UpOptions{Scale: map[string]int{"web": 3}})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	apiClient.AssertCalled(t, "ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_3")
+	// new replica is created first, then diverged ones are recreated one at a time
+	assert.DeepEqual(t, calls, []string{"start", "stop 123456789011", "start", "stop 123456789012", "start"})
+	assert.Equal(t, running, 3)
+	assert.Assert(t, max <= 4)
+}
+
+func TestPollBackoff(t *testing.T) {
+	b := newBackoff(0)
+	assert.Equal(t, b.interval, minPollInterval)
+	b.next("starting")
+	assert.Equal(t, b.interval, minPollInterval)
+
+	// interval grows while status is unchanged, up to a cap
+	var intervals []time.Duration
+	for i := 0; i < 8; i++ {
+		b.next("starting")
+		intervals = append(intervals, b.interval)
+	}
+	assert.DeepEqual(t, intervals[:3], []time.Duration{200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond})
+	assert.Equal(t, b.interval, maxPollInterval)
+
+	b.next("unhealthy")
+	assert.Equal(t, b.interval, minPollInterval)
+
+	// healthcheck interval is a floor, even above cap
+	b = newBackoff(10 * time.Second)
+	b.next("starting")
+	b.next("starting")
+	assert.Equal(t, b.interval, 10*time.Second)
+}
+
+func TestWaitForPortOpen(t *testing.T) {
+	// reserve a free port, then release it so nothing listens until we decide to
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	address := l.Addr().String()
+	port := l.Addr().(*net.TCPAddr).Port
+	assert.NilError(t, l.Close())
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{
+		{ID: "c1", State: "running", Labels: map[string]string{serviceLabel: "db"}},
+	}, nil)
+	apiClient.On("ContainerInspect", mock.Anything, "c1").Return(moby.ContainerJSON{
+		ContainerJSONBase: &moby.ContainerJSONBase{State: &moby.ContainerState{Running: true}},
+		NetworkSettings: &moby.NetworkSettings{Networks: map[string]*network.EndpointSettings{
+			"myproject_default": {IPAddress: "127.0.0.1"},
+		}},
+	}, nil)
+	s := newMockBackend(apiClient)
+
+	web := types.ServiceConfig{
+		Name:       "web",
+		DependsOn:  types.DependsOnConfig{"db": {}},
+		Extensions: map[string]interface{}{extWaitForPorts: map[string]interface{}{"db": []interface{}{port}}},
+	}
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{web, {Name: "db"}}}
+	done := make(chan error)
+	go func() {
+		done <- s.waitDependencies(context.TODO(), project, web)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("wait returned while port is closed")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	l, err = net.Listen("tcp", address)
+	assert.NilError(t, err)
+	defer l.Close() //nolint:errcheck
+	select {
+	case err := <-done:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait didn't return once port opened")
+	}
+}
+
+func TestPortDependenciesMustBeDeclared(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:       "web",
+		Extensions: map[string]interface{}{extWaitForPorts: map[string]interface{}{"db": []interface{}{5432}}},
+	}
+	_, err := getPortDependencies(service)
+	assert.Error(t, err, `invalid x-wait_for_ports for service "web": "db" is not declared in depends_on`)
+}
+
+func TestUpRejectsInvalidScale(t *testing.T) {
+	s := newMockBackend(&mockAPIClient{})
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{{Name: "web", Image: "nginx"}}}
+
+	err := s.Up(context.TODO(), project, compose.UpOptions{Scale: map[string]int{"db": 2}})
+	assert.Error(t, err, `can't scale service "db": no such service`)
+
+	err = s.Up(context.TODO(), project, compose.UpOptions{Scale: map[string]int{"web": -1}})
+	assert.Error(t, err, `can't scale service "web" to -1 replicas`)
+}
+
+func TestGlobalDeployMode(t *testing.T) {
+	replicas := uint64(3)
+	service := types.ServiceConfig{
+		Name:   "agent",
+		Image:  "datadog/agent",
+		Deploy: &types.DeployConfig{Mode: "global"},
+	}
+	assert.Equal(t, getScale(service), 1)
+	assert.NilError(t, checkDeployMode(service))
+
+	service.Deploy.Replicas = &replicas
+	assert.Error(t, checkDeployMode(service), `service "agent" can't set replicas in global deploy mode`)
+
+	s := newMockBackend(&mockAPIClient{})
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+	err := s.Up(context.TODO(), project, compose.UpOptions{})
+	assert.Error(t, err, `service "agent" can't set replicas in global deploy mode`)
+
+	project.Services[0].Deploy.Replicas = nil
+	err = s.Up(context.TODO(), project, compose.UpOptions{Scale: map[string]int{"agent": 2}})
+	assert.Error(t, err, `can't scale service "agent" to 2 replicas`)
+}
+
+// convergeWithNameConflict converges a service which container can't be created as an unmanaged container
+// already uses its name
+func convergeWithNameConflict(t *testing.T, policy string, existing moby.ContainerJSON, expect func(apiClient *mockAPIClient)) {
+	service := types.ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+	}
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{}, nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+		Return(container.ContainerCreateCreatedBody{}, errdefs.Conflict(errors.New(`the container name "/myproject_web_1" is already in use`))).Once()
+	apiClient.On("ContainerInspect", mock.Anything, "myproject_web_1").Return(existing, nil)
+	expect(apiClient)
+	s := newMockBackend(apiClient)
+
+	err := s.ensureService(context.TODO(), project, service, compose.UpOptions{NameConflict: policy})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+}
+
+func TestNameConflictFailsByDefault(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+	}
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{}, nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+		Return(container.ContainerCreateCreatedBody{}, errdefs.Conflict(errors.New(`the container name "/myproject_web_1" is already in use`)))
+	s := newMockBackend(apiClient)
+
+	for _, policy := range []string{"", compose.NameConflictFail} {
+		err := s.ensureService(context.TODO(), project, service, compose.UpOptions{NameConflict: policy})
+		assert.Error(t, err, `container name "myproject_web_1" is already in use by a container compose doesn't manage: remove it, or set the name conflict policy to "replace" or "adopt"`)
+	}
+	apiClient.AssertNotCalled(t, "ContainerRemove", mock.Anything, mock.Anything, mock.Anything)
+	apiClient.AssertNotCalled(t, "ContainerStart", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestNameConflictReplacesExistingContainer(t *testing.T) {
+	existing := moby.ContainerJSON{ContainerJSONBase: &moby.ContainerJSONBase{ID: "leftover", State: &moby.ContainerState{}}}
+	convergeWithNameConflict(t, compose.NameConflictReplace, existing, func(apiClient *mockAPIClient) {
+		apiClient.On("ContainerRemove", mock.Anything, "leftover", moby.ContainerRemoveOptions{Force: true}).Return(nil).Once()
+		apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+			Return(container.ContainerCreateCreatedBody{ID: "abc"}, nil).Once()
+		apiClient.On("ContainerStart", mock.Anything, "abc", moby.ContainerStartOptions{}).Return(nil).Once()
+	})
+}
+
+func TestNameConflictAdoptsExistingContainer(t *testing.T) {
+	existing := moby.ContainerJSON{ContainerJSONBase: &moby.ContainerJSONBase{ID: "leftover", State: &moby.ContainerState{Status: "exited"}}}
+	convergeWithNameConflict(t, compose.NameConflictAdopt, existing, func(apiClient *mockAPIClient) {
+		apiClient.On("ContainerStart", mock.Anything, "leftover", moby.ContainerStartOptions{}).Return(nil).Once()
+	})
+
+	existing.State = &moby.ContainerState{Status: "running", Running: true}
+	convergeWithNameConflict(t, compose.NameConflictAdopt, existing, func(apiClient *mockAPIClient) {})
+}
+
+func TestAdoptExternallyCreatedContainer(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+		Ports: []types.ServicePortConfig{{Target: 80, Published: 8080}},
+	}
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+	external := moby.Container{
+		ID:     "123456789012345",
+		Names:  []string{"/myproject_web_1"},
+		Image:  "nginx",
+		State:  "running",
+		Ports:  []moby.Port{{PrivatePort: 80, PublicPort: 8080, Type: "tcp"}},
+		Labels: map[string]string{projectLabel: "myproject", serviceLabel: "web", containerNumberLabel: "1", configHashLabel: "legacy"},
+	}
+	assert.Assert(t, canAdopt(project, service, external, ""))
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{external}, nil)
+	s := newMockBackend(apiClient)
+
+	w := &recordingWriter{}
+	err := s.ensureService(progress.WithContextWriter(context.TODO(), w), project, service, compose.UpOptions{Adopt: true})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, w.statuses(`Service "web"`), []string{"Adopt", "Running"})
+	apiClient.AssertNotCalled(t, "ContainerStop", mock.Anything, mock.Anything, mock.Anything)
+	apiClient.AssertNotCalled(t, "ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// containers not running service image or ports, or created by this tool, are not adopted
+	moved := external
+	moved.Ports = []moby.Port{{PrivatePort: 80, PublicPort: 9090, Type: "tcp"}}
+	assert.Assert(t, !canAdopt(project, service, moved, ""))
+	other := external
+	other.Image = "httpd"
+	assert.Assert(t, !canAdopt(project, service, other, ""))
+	managed := external
+	managed.Labels = map[string]string{configHashLabel: "legacy", configHashVersionLabel: configHashVersion}
+	assert.Assert(t, !canAdopt(project, service, managed, ""))
+}
+
+func TestRecreateUnhealthyContainer(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+	}
+	hash, err := jsonHash(service)
+	assert.NilError(t, err)
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{{
+		ID:     "123456789012345",
+		Names:  []string{"/myproject_web_1"},
+		Image:  "nginx",
+		State:  "running",
+		Labels: map[string]string{containerNumberLabel: "1", configHashLabel: hash},
+	}}, nil)
+	apiClient.On("ContainerInspect", mock.Anything, "123456789012345").Return(withHealth("unhealthy"), nil)
+	apiClient.On("ContainerStop", mock.Anything, "123456789012345", mock.Anything).Return(nil)
+	apiClient.On("ContainerRename", mock.Anything, "123456789012345", "123456789012_myproject_web_1").Return(nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+		Return(container.ContainerCreateCreatedBody{ID: "abc"}, nil)
+	apiClient.On("ContainerStart", mock.Anything, "abc", moby.ContainerStartOptions{}).Return(nil)
+	apiClient.On("ContainerRemove", mock.Anything, "123456789012345", moby.ContainerRemoveOptions{}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	err = s.ensureService(context.TODO(), project, service, compose.UpOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertNotCalled(t, "ContainerStop", mock.Anything, mock.Anything, mock.Anything)
+
+	err = s.ensureService(context.TODO(), project, service, compose.UpOptions{RecreateUnhealthy: true})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+}
+
+func TestNetworksAttachedByPriority(t *testing.T) {
+	priority := func(p int) *types.ServiceNetworkConfig {
+		return &types.ServiceNetworkConfig{Extensions: map[string]interface{}{extNetworkPriority: p}}
+	}
+	service := types.ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+		Networks: map[string]*types.ServiceNetworkConfig{
+			"back":  priority(10),
+			"front": priority(100),
+			"admin": nil,
+		},
+	}
+	project := &types.Project{
+		Name:     "myproject",
+		Services: []types.ServiceConfig{service},
+		Networks: types.Networks{
+			"back":  types.NetworkConfig{Name: "myproject_back"},
+			"front": types.NetworkConfig{Name: "myproject_front"},
+			"admin": types.NetworkConfig{Name: "myproject_admin"},
+		},
+	}
+	assert.DeepEqual(t, getNetworksByPriority(service), []string{"front", "back", "admin"})
+
+	var connected []string
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything,
+		mock.MatchedBy(func(hostConfig *container.HostConfig) bool {
+			return hostConfig.NetworkMode == "myproject_front"
+		}), mock.Anything, "myproject_web_1").
+		Return(container.ContainerCreateCreatedBody{ID: "abc"}, nil)
+	apiClient.On("NetworkConnect", mock.Anything, mock.Anything, "abc", mock.Anything).Run(func(args mock.Arguments) {
+		connected = append(connected, args.String(1))
+	}).Return(nil)
+	apiClient.On("ContainerStart", mock.Anything, "abc", moby.ContainerStartOptions{}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	err := s.runContainer(context.TODO(), project, service, "myproject_web_1", 1, nil, compose.UpOptions{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, connected, []string{"myproject_back", "myproject_admin"})
+	apiClient.AssertExpectations(t)
+}
+
+func TestRecreateRenewAnonymousVolumes(t *testing.T) {
+	project := &types.Project{
+		Name: "myproject",
+		Services: []types.ServiceConfig{
+			{
+				Name:    "db",
+				Image:   "mysql",
+				Volumes: []types.ServiceVolumeConfig{{Type: "volume", Source: "data", Target: "/var/lib/mysql"}},
+			},
+		},
+		Volumes: types.Volumes{"data": types.VolumeConfig{Name: "myproject_data"}},
+	}
+	old := moby.Container{
+		ID:     "123456789012345",
+		Names:  []string{"/myproject_db_1"},
+		Labels: map[string]string{containerNumberLabel: "1"},
+		Mounts: []moby.MountPoint{
+			{Type: "volume", Name: "myproject_data", Destination: "/var/lib/mysql", RW: true},
+			{Type: "volume", Name: "0123456789abcdef", Destination: "/cache", RW: true},
+		},
+	}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerStop", mock.Anything, "123456789012345", mock.Anything).Return(nil)
+	apiClient.On("ContainerRename", mock.Anything, "123456789012345", "123456789012_myproject_db_1").Return(nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.MatchedBy(func(hostConfig *container.HostConfig) bool {
+		return len(hostConfig.Mounts) == 1 && hostConfig.Mounts[0].Source == "myproject_data"
+	}), mock.Anything, "myproject_db_1").Return(container.ContainerCreateCreatedBody{ID: "abc"}, nil)
+	apiClient.On("ContainerStart", mock.Anything, "abc", moby.ContainerStartOptions{}).Return(nil)
+	apiClient.On("ContainerRemove", mock.Anything, "123456789012345", moby.ContainerRemoveOptions{RemoveVolumes: true}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	err := s.recreateContainer(context.TODO(), project, project.Services[0], old, compose.UpOptions{RenewAnonVolumes: true})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+
+	// by default, anonymous volumes are attached to the replacement container, and kept when old one is removed
+	assert.DeepEqual(t, withoutAnonymousVolumes(project, project.Services[0], old.Mounts), old.Mounts[:1])
+	apiClient = &mockAPIClient{}
+	apiClient.On("ContainerStop", mock.Anything, "123456789012345", mock.Anything).Return(nil)
+	apiClient.On("ContainerRename", mock.Anything, "123456789012345", "123456789012_myproject_db_1").Return(nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.MatchedBy(func(hostConfig *container.HostConfig) bool {
+		return len(hostConfig.Mounts) == 2 && hostConfig.Mounts[1].Source == "0123456789abcdef"
+	}), mock.Anything, "myproject_db_1").Return(container.ContainerCreateCreatedBody{ID: "abc"}, nil)
+	apiClient.On("ContainerStart", mock.Anything, "abc", moby.ContainerStartOptions{}).Return(nil)
+	apiClient.On("ContainerRemove", mock.Anything, "123456789012345", moby.ContainerRemoveOptions{RemoveVolumes: false}).Return(nil)
+	s = newMockBackend(apiClient)
+
+	err = s.recreateContainer(context.TODO(), project, project.Services[0], old, compose.UpOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+}
+
+func TestRecreatePolicy(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+	}
+	hash, err := jsonHash(service)
+	assert.NilError(t, err)
+	diverged := moby.Container{Image: "nginx", State: "running", Labels: map[string]string{configHashLabel: "outdated"}}
+	upToDate := moby.Container{Image: "nginx", State: "running", Labels: map[string]string{configHashLabel: hash}}
+
+	assert.Equal(t, getContainerAction(service, lifecycle{}, diverged, hash, "", compose.RecreateDiverged), compose.ActionRecreate)
+	assert.Equal(t, getContainerAction(service, lifecycle{}, upToDate, hash, "", compose.RecreateDiverged), compose.ActionNone)
+	assert.Equal(t, getContainerAction(service, lifecycle{}, upToDate, hash, "", compose.RecreateForce), compose.ActionRecreate)
+	assert.Equal(t, getContainerAction(service, lifecycle{}, diverged, hash, "", compose.RecreateNever), compose.ActionNone)
+	assert.Equal(t, getContainerAction(service, lifecycle{Strategy: forceRecreate}, diverged, hash, "", compose.RecreateNever), compose.ActionNone)
+}
+
+func TestNoRecreateStartsDivergedContainer(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+	}
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{{
+		ID:     "123456789012345",
+		Names:  []string{"/myproject_web_1"},
+		Image:  "nginx",
+		State:  "exited",
+		Labels: map[string]string{containerNumberLabel: "1", configHashLabel: "outdated"},
+	}}, nil)
+	apiClient.On("ContainerStart", mock.Anything, "123456789012345", moby.ContainerStartOptions{}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	err := s.ensureService(context.TODO(), project, service, compose.UpOptions{Recreate: compose.RecreateNever})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	apiClient.AssertNotCalled(t, "ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpToDateContainerReportsRunning(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+	}
+	hash, err := jsonHash(service)
+	assert.NilError(t, err)
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{{
+		ID:     "123456789012345",
+		Names:  []string{"/myproject_web_1"},
+		Image:  "nginx",
+		State:  "running",
+		Labels: map[string]string{containerNumberLabel: "1", configHashLabel: hash},
+	}}, nil)
+	s := newMockBackend(apiClient)
+
+	w := &recordingWriter{}
+	err = s.ensureService(progress.WithContextWriter(context.TODO(), w), project, service, compose.UpOptions{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, w.statuses(`Service "web"`), []string{"Running"})
+	apiClient.AssertNotCalled(t, "ContainerStart", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRecreateChangedFiles(t *testing.T) {
+	dir := fs.NewDir(t, "configs", fs.WithFile("nginx.conf", "worker_processes 1;"))
+	defer dir.Remove()
+	defer removeFileReferences("myproject") //nolint:errcheck
+
+	service := types.ServiceConfig{
+		Name:    "web",
+		Image:   "nginx",
+		Configs: []types.ServiceConfigObjConfig{{Source: "nginx", Target: "/etc/nginx/nginx.conf"}},
+	}
+	project := &types.Project{
+		Name:       "myproject",
+		WorkingDir: dir.Path(),
+		Services:   []types.ServiceConfig{service},
+		Configs:    map[string]types.ConfigObjConfig{"nginx": {File: "nginx.conf"}},
+	}
+	config, _, _, err := getContainerCreateOptions(project, service, 1, nil)
+	assert.NilError(t, err)
+	_, ok := config.Labels[fileReferencesHashLabel]
+	assert.Assert(t, !ok)
+	config.Labels[fileReferencesHashLabel], err = getFileReferencesHash(project, service)
+	assert.NilError(t, err)
+	running := moby.Container{
+		ID:     "123456789012345",
+		Names:  []string{"/myproject_web_1"},
+		Image:  "nginx",
+		State:  "running",
+		Labels: config.Labels,
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir.Path(), "nginx.conf"), []byte("worker_processes 4;"), 0644)
+	assert.NilError(t, err)
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{running}, nil)
+	s := newMockBackend(apiClient)
+
+	// configuration is unchanged, so file content is ignored by default
+	err = s.ensureService(context.TODO(), project, service, compose.UpOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertNotCalled(t, "ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	apiClient.On("ContainerStop", mock.Anything, "123456789012345", mock.Anything).Return(nil)
+	apiClient.On("ContainerRename", mock.Anything, "123456789012345", "123456789012_myproject_web_1").Return(nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.MatchedBy(func(config *container.Config) bool {
+		return config.Labels[fileReferencesHashLabel] != running.Labels[fileReferencesHashLabel]
+	}), mock.Anything, mock.Anything, "myproject_web_1").Return(container.ContainerCreateCreatedBody{ID: "abc"}, nil)
+	apiClient.On("ContainerStart", mock.Anything, "abc", moby.ContainerStartOptions{}).Return(nil)
+	apiClient.On("ContainerRemove", mock.Anything, "123456789012345", mock.Anything).Return(nil)
+
+	err = s.ensureService(context.TODO(), project, service, compose.UpOptions{RecreateChangedFiles: true})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+}
+
+func TestNoDepsSkipsDependencies(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:      "web",
+		Image:     "nginx",
+		DependsOn: types.DependsOnConfig{"db": types.ServiceDependency{Condition: types.ServiceConditionHealthy}},
+	}
+	hash, err := jsonHash(service)
+	assert.NilError(t, err)
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service, {Name: "db", Image: "mysql"}}}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.MatchedBy(func(options moby.ContainerListOptions) bool {
+		return options.Filters.ExactMatch("label", fmt.Sprintf("%s=%s", serviceLabel, "web"))
+	})).Return([]moby.Container{{
+		ID:     "123456789012345",
+		Names:  []string{"/myproject_web_1"},
+		Image:  "nginx",
+		State:  "running",
+		Labels: map[string]string{containerNumberLabel: "1", configHashLabel: hash},
+	}}, nil)
+	s := newMockBackend(apiClient)
+
+	err = s.ensureService(context.TODO(), project, service, compose.UpOptions{NoDeps: true})
+	assert.NilError(t, err)
+	apiClient.AssertNumberOfCalls(t, "ContainerList", 1)
+	apiClient.AssertNotCalled(t, "ContainerInspect", mock.Anything, mock.Anything)
+}
+
+func TestRemoveLeftoverOfInterruptedRecreate(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+	}
+	hash, err := jsonHash(service)
+	assert.NilError(t, err)
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{
+		{
+			ID:     "123456789012345",
+			Names:  []string{"/123456789012_myproject_web_1"},
+			Image:  "nginx",
+			State:  "exited",
+			Labels: map[string]string{containerNumberLabel: "1", configHashLabel: "outdated"},
+		},
+		{
+			ID:     "abcdefabcdefabc",
+			Names:  []string{"/myproject_web_1"},
+			Image:  "nginx",
+			State:  "running",
+			Labels: map[string]string{containerNumberLabel: "1", configHashLabel: hash},
+		},
+	}, nil)
+	apiClient.On("ContainerRemove", mock.Anything, "123456789012345", moby.ContainerRemoveOptions{Force: true}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	err = s.ensureService(context.TODO(), project, service, compose.UpOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	apiClient.AssertNotCalled(t, "ContainerRename", mock.Anything, mock.Anything, mock.Anything)
+	apiClient.AssertNotCalled(t, "ContainerStart", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRestoreLeftoverOfInterruptedRecreate(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+	}
+	hash, err := jsonHash(service)
+	assert.NilError(t, err)
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{{
+		ID:     "123456789012345",
+		Names:  []string{"/123456789012_myproject_web_1"},
+		Image:  "nginx",
+		State:  "exited",
+		Labels: map[string]string{containerNumberLabel: "1", configHashLabel: hash},
+	}}, nil)
+	apiClient.On("ContainerRename", mock.Anything, "123456789012345", "myproject_web_1").Return(nil)
+	apiClient.On("ContainerStart", mock.Anything, "123456789012345", moby.ContainerStartOptions{}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	err = s.ensureService(context.TODO(), project, service, compose.UpOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	apiClient.AssertNotCalled(t, "ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWaitDependencyWithDisabledHealthcheck(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	s := newMockBackend(apiClient)
+
+	project := &types.Project{
+		Name: "myproject",
+		Services: []types.ServiceConfig{
+			{
+				Name:  "web",
+				Image: "nginx",
+				DependsOn: types.DependsOnConfig{
+					"db": types.ServiceDependency{Condition: types.ServiceConditionHealthy},
+				},
+			},
+			{
+				Name:        "db",
+				Image:       "mysql",
+				HealthCheck: &types.HealthCheckConfig{Disable: true},
+			},
+		},
+	}
+	err := s.waitDependencies(context.TODO(), project, project.Services[0])
+	assert.Error(t, err, `service "web" depends on "db" being healthy, but "db" has its healthcheck disabled`)
+	apiClient.AssertNotCalled(t, "ContainerList", mock.Anything, mock.Anything)
+}
+
+func TestExternalDependencyWithDisabledHealthcheck(t *testing.T) {
+	disabled := withHealth("")
+	disabled.State.Health = nil
+	disabled.Config = &container.Config{Healthcheck: &container.HealthConfig{Test: []string{"NONE"}}}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerInspect", mock.Anything, "shared_db").Return(disabled, nil)
+	s := newMockBackend(apiClient)
+
+	_, _, err := s.isContainerHealthy(context.TODO(), "shared_db")
+	assert.Error(t, err, `container "shared_db": healthcheck is disabled`)
+}
+
+// convergeConcurrently scales up a service to 50 replicas, and returns the maximum number of concurrent creates
+func convergeConcurrently(t *testing.T, options compose.UpOptions) (int, error) {
+	replicas := uint64(50)
+	service := types.ServiceConfig{
+		Name:   "web",
+		Image:  "nginx",
+		Deploy: &types.DeployConfig{Replicas: &replicas},
+	}
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+
+	var (
+		mtx     sync.Mutex
+		running int
+		max     int
+	)
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{}, nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mtx.Lock()
+		running++
+		if running > max {
+			max = running
+		}
+		mtx.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mtx.Lock()
+		running--
+		mtx.Unlock()
+	}).Return(container.ContainerCreateCreatedBody{ID: "abc"}, nil)
+	apiClient.On("ContainerStart", mock.Anything, "abc", moby.ContainerStartOptions{}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	err := s.ensureService(context.TODO(), project, service, options)
+	if err == nil {
+		apiClient.AssertNumberOfCalls(t, "ContainerCreate", 50)
+	}
+	return max, err
+}
+
+func TestScaleUpLimitsParallelCreates(t *testing.T) {
+	max, err := convergeConcurrently(t, compose.UpOptions{Parallel: 5})
+	assert.NilError(t, err)
+	assert.Assert(t, max <= 5, "%d concurrent creates", max)
+}
+
+func TestParallelLimitFromEnvironment(t *testing.T) {
+	defer os.Unsetenv(envParallelLimit) // nolint errcheck
+
+	os.Setenv(envParallelLimit, "3") // nolint errcheck
+	max, err := convergeConcurrently(t, compose.UpOptions{})
+	assert.NilError(t, err)
+	assert.Assert(t, max <= 3, "%d concurrent creates", max)
+
+	// explicit option takes precedence
+	max, err = convergeConcurrently(t, compose.UpOptions{Parallel: 1})
+	assert.NilError(t, err)
+	assert.Equal(t, max, 1)
+
+	os.Setenv(envParallelLimit, "none") // nolint errcheck
+	_, err = convergeConcurrently(t, compose.UpOptions{})
+	assert.Error(t, err, `invalid COMPOSE_PARALLEL_LIMIT value "none": must be a positive integer`)
+}
+
+func TestRecreateWhenImageChanged(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:  "web",
+		Image: "nginx",
+	}
+	hash, err := jsonHash(service)
+	assert.NilError(t, err)
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+	running := moby.Container{
+		ID:      "123456789012345",
+		Names:   []string{"/myproject_web_1"},
+		Image:   "nginx",
+		ImageID: "sha256:previous",
+		State:   "running",
+		Labels:  map[string]string{containerNumberLabel: "1", configHashLabel: hash},
+	}
+	assert.Equal(t, getContainerAction(service, lifecycle{}, running, hash, "sha256:previous", compose.RecreateDiverged), compose.ActionNone)
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, "nginx").Return(moby.ImageInspect{ID: "sha256:pulled"}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{running}, nil)
+	apiClient.On("ContainerStop", mock.Anything, "123456789012345", mock.Anything).Return(nil)
+	apiClient.On("ContainerRename", mock.Anything, "123456789012345", "123456789012_myproject_web_1").Return(nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+		Return(container.ContainerCreateCreatedBody{ID: "abc"}, nil)
+	apiClient.On("ContainerStart", mock.Anything, "abc", moby.ContainerStartOptions{}).Return(nil)
+	apiClient.On("ContainerRemove", mock.Anything, "123456789012345", moby.ContainerRemoveOptions{}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	err = s.ensureService(context.TODO(), project, service, compose.UpOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+}
+
+func TestResolveSharedNamespaces(t *testing.T) {
+	project := &types.Project{
+		Name: "myproject",
+		Services: []types.ServiceConfig{
+			{Name: "db", Image: "postgres"},
+			{Name: "debug", Image: "busybox", Ipc: "service:db", Pid: "service:db"},
+		},
+	}
+	replica := func(number string, state string) moby.Container {
+		return moby.Container{ID: "c" + number, State: state, Labels: map[string]string{containerNumberLabel: number}}
+	}
+	apiClient := &mockAPIClient{}
+	// replica 1 was removed by scaling down, replica 3 is stopped
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).
+		Return([]moby.Container{replica("3", "exited"), replica("2", "running")}, nil).Twice()
+	s := newMockBackend(apiClient)
+
+	hostConfig := &container.HostConfig{}
+	err := s.resolveServiceReferences(context.TODO(), project, project.Services[1], hostConfig)
+	assert.NilError(t, err)
+	assert.Equal(t, hostConfig.IpcMode, container.IpcMode("container:c2"))
+	assert.Equal(t, hostConfig.PidMode, container.PidMode("container:c2"))
+
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{}, nil).Once()
+	err = s.resolveServiceReferences(context.TODO(), project, project.Services[1], &container.HostConfig{})
+	assert.Error(t, err, `service "debug" ipc refers to service "db", which has no container`)
+	apiClient.AssertExpectations(t)
+}
+
+func TestResolveNetworkModeService(t *testing.T) {
+	project := &types.Project{
+		Name: "myproject",
+		Services: []types.ServiceConfig{
+			{Name: "app", Image: "myapp"},
+			{Name: "sidecar", Image: "envoy", NetworkMode: "service:app"},
+		},
+	}
+	apiClient := &mockAPIClient{}
+	// replica 1 was removed by scaling down
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{
+		{ID: "c2", State: "running", Labels: map[string]string{containerNumberLabel: "2"}},
+	}, nil).Once()
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{}, nil).Once()
+	s := newMockBackend(apiClient)
+
+	hostConfig := &container.HostConfig{}
+	err := s.resolveServiceReferences(context.TODO(), project, project.Services[1], hostConfig)
+	assert.NilError(t, err)
+	assert.Equal(t, hostConfig.NetworkMode, container.NetworkMode("container:c2"))
+
+	err = s.resolveServiceReferences(context.TODO(), project, project.Services[1], &container.HostConfig{})
+	assert.Error(t, err, `service "sidecar" network_mode refers to service "app", which has no container`)
+	apiClient.AssertExpectations(t)
+}
+
+func TestResolveVolumesFromServices(t *testing.T) {
+	project := &types.Project{
+		Name: "myproject",
+		Services: []types.ServiceConfig{
+			{Name: "backup", Image: "alpine", VolumesFrom: []string{"db:ro", "container:legacy:rw", "data"}},
+			{Name: "db", Image: "postgres"},
+			{Name: "data", Image: "busybox"},
+		},
+	}
+	_, hostConfig, _, err := getContainerCreateOptions(project, project.Services[0], 1, nil)
+	assert.NilError(t, err)
+
+	apiClient := &mockAPIClient{}
+	// replica 1 of db was removed by scaling down
+	apiClient.On("ContainerList", mock.Anything, moby.ContainerListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", "com.docker.compose.project=myproject"),
+			filters.Arg("label", "com.docker.compose.service=db"),
+		),
+		All: true,
+	}).Return([]moby.Container{{ID: "c2", State: "running", Labels: map[string]string{containerNumberLabel: "2"}}}, nil)
+	apiClient.On("ContainerList", mock.Anything, moby.ContainerListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", "com.docker.compose.project=myproject"),
+			filters.Arg("label", "com.docker.compose.service=data"),
+		),
+		All: true,
+	}).Return([]moby.Container{{ID: "d1", State: "exited", Labels: map[string]string{containerNumberLabel: "1"}}}, nil)
+	s := newMockBackend(apiClient)
+
+	err = s.resolveServiceReferences(context.TODO(), project, project.Services[0], hostConfig)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hostConfig.VolumesFrom, []string{"c2:ro", "legacy:rw", "d1"})
+}
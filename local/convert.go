@@ -111,6 +111,10 @@ func toMobyHealthCheck(check *compose.HealthCheckConfig) *container.HealthConfig
 	if check == nil {
 		return nil
 	}
+	if check.Disable {
+		// NONE disables any healthcheck set by image
+		return &container.HealthConfig{Test: []string{"NONE"}}
+	}
 	var (
 		interval time.Duration
 		timeout  time.Duration
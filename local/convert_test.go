@@ -21,6 +21,7 @@ package local
 import (
 	"testing"
 
+	compose "github.com/compose-spec/compose-go/types"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"gotest.tools/v3/assert"
@@ -101,3 +102,12 @@ func TestToRestartPolicy(t *testing.T) {
 		assert.Equal(t, toRestartPolicy(p), moby[i])
 	}
 }
+
+func TestToMobyHealthCheckDisabled(t *testing.T) {
+	t.Parallel()
+	check := toMobyHealthCheck(&compose.HealthCheckConfig{
+		Test:    []string{"CMD", "true"},
+		Disable: true,
+	})
+	assert.DeepEqual(t, check, &container.HealthConfig{Test: []string{"NONE"}})
+}
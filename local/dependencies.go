@@ -20,24 +20,48 @@ package local
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/compose-spec/compose-go/types"
 	"golang.org/x/sync/errgroup"
 )
 
 func inDependencyOrder(ctx context.Context, project *types.Project, fn func(context.Context, types.ServiceConfig) error) error {
-	graph := buildDependencyGraph(project.Services)
+	return visit(ctx, project.Services, false, fn)
+}
+
+// inReverseDependencyOrder applies fn to services, a service being only visited after all the services depending on it
+func inReverseDependencyOrder(ctx context.Context, services types.Services, fn func(context.Context, types.ServiceConfig) error) error {
+	return visit(ctx, services, true, fn)
+}
+
+func visit(ctx context.Context, services types.Services, reverse bool, fn func(context.Context, types.ServiceConfig) error) error {
+	graph := buildDependencyGraph(services)
+	if cycle := graph.findCycle(); cycle != nil {
+		// services in a cycle would wait for each other forever
+		return fmt.Errorf("dependency cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+
+	priorities := map[string]int{}
+	if !reverse {
+		for _, service := range services {
+			priority, err := getStartPriority(service)
+			if err != nil {
+				return err
+			}
+			priorities[service.Name] = priority
+		}
+	}
 
 	eg, ctx := errgroup.WithContext(ctx)
-	results := make(chan string)
-	errors := make(chan error)
+	results := make(chan string, len(graph))
+	errors := make(chan error, len(graph))
 	scheduled := map[string]bool{}
 	for len(graph) > 0 {
-		for _, n := range graph.independents() {
+		for _, n := range graph.startable(graph.independents(reverse), scheduled, priorities) {
 			service := n.service
-			if scheduled[service.Name] {
-				continue
-			}
 			eg.Go(func() error {
 				err := fn(ctx, service)
 				if err != nil {
@@ -51,9 +75,10 @@ func inDependencyOrder(ctx context.Context, project *types.Project, fn func(cont
 		}
 		select {
 		case result := <-results:
-			graph.resolved(result)
-		case err := <-errors:
-			return err
+			graph.resolved(result, reverse)
+		case <-errors:
+			// wait for running tasks to complete, so caller can safely cleanup
+			return eg.Wait()
 		}
 	}
 	return eg.Wait()
@@ -67,25 +92,145 @@ type node struct {
 	dependent    []string
 }
 
-func (graph dependencyGraph) independents() []node {
+// independents returns nodes with no pending dependency, or no pending dependent when visiting in reverse order
+func (graph dependencyGraph) independents(reverse bool) []node {
 	var nodes []node
 	for _, node := range graph {
-		if len(node.dependencies) == 0 {
+		pending := node.dependencies
+		if reverse {
+			pending = node.dependent
+		}
+		if len(pending) == 0 {
 			nodes = append(nodes, node)
 		}
 	}
 	return nodes
 }
 
-func (graph dependencyGraph) resolved(result string) {
-	for _, parent := range graph[result].dependent {
+// startable filters nodes not yet scheduled down to those with the lowest start priority, also deferring them while a
+// running service has a lower priority. This only orders services depends_on leaves independent
+func (graph dependencyGraph) startable(nodes []node, scheduled map[string]bool, priorities map[string]int) []node {
+	var pending []node
+	for _, n := range nodes {
+		if !scheduled[n.service.Name] {
+			pending = append(pending, n)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	lowest := priorities[pending[0].service.Name]
+	for _, n := range pending {
+		if p := priorities[n.service.Name]; p < lowest {
+			lowest = p
+		}
+	}
+	for name := range graph {
+		if scheduled[name] && priorities[name] < lowest {
+			return nil
+		}
+	}
+	var startable []node
+	for _, n := range pending {
+		if priorities[n.service.Name] == lowest {
+			startable = append(startable, n)
+		}
+	}
+	return startable
+}
+
+func (graph dependencyGraph) resolved(result string, reverse bool) {
+	parents := graph[result].dependent
+	if reverse {
+		parents = graph[result].dependencies
+	}
+	for _, parent := range parents {
 		node := graph[parent]
-		node.dependencies = remove(node.dependencies, result)
+		if reverse {
+			node.dependent = remove(node.dependent, result)
+		} else {
+			node.dependencies = remove(node.dependencies, result)
+		}
 		graph[parent] = node
 	}
 	delete(graph, result)
 }
 
+// findCycle returns services forming a dependency cycle, starting and ending with the same service, or nil if the
+// graph has none
+func (graph dependencyGraph) findCycle() []string {
+	var names []string
+	for name := range graph {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	visited := map[string]bool{}
+	var path []string
+	var walk func(name string) []string
+	walk = func(name string) []string {
+		for i, n := range path {
+			if n == name {
+				return append(append([]string{}, path[i:]...), name)
+			}
+		}
+		if visited[name] {
+			return nil
+		}
+		visited[name] = true
+		path = append(path, name)
+		dependencies := append([]string{}, graph[name].dependencies...)
+		sort.Strings(dependencies)
+		for _, dependency := range dependencies {
+			if cycle := walk(dependency); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		return nil
+	}
+	for _, name := range names {
+		if cycle := walk(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// getStartPriority reads the `x-start-priority` extension, services with a lower value being started first among
+// those depends_on doesn't order
+func getStartPriority(service types.ServiceConfig) (int, error) {
+	switch priority := service.Extensions[extStartPriority].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return priority, nil
+	case float64:
+		if priority == float64(int(priority)) {
+			return int(priority), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid %s for service %q: expected an integer", extStartPriority, service.Name)
+}
+
+// checkExtendsResolved checks services extending another service of the project refer to an existing one. Loader
+// keeps `extends` set on services once merged with their base, and bases declared in another file can't be checked
+func checkExtendsResolved(project *types.Project) error {
+	for _, service := range project.Services {
+		if len(service.Extends) == 0 || service.Extends["file"] != nil {
+			continue
+		}
+		base := service.Extends["service"]
+		if base == nil {
+			return fmt.Errorf("service %q extends a service which was not resolved", service.Name)
+		}
+		if _, err := project.GetService(*base); err != nil {
+			return fmt.Errorf("service %q extends service %q, which was not resolved", service.Name, *base)
+		}
+	}
+	return nil
+}
+
 func buildDependencyGraph(services types.Services) dependencyGraph {
 	graph := dependencyGraph{}
 	for _, s := range services {
@@ -96,8 +241,12 @@ func buildDependencyGraph(services types.Services) dependencyGraph {
 
 	for _, s := range services {
 		node := graph[s.Name]
-		for _, name := range s.GetDependencies() {
-			dependency := graph[name]
+		for _, name := range getDependencies(s) {
+			dependency, ok := graph[name]
+			if !ok {
+				// not part of the services being visited
+				continue
+			}
 			node.dependencies = append(node.dependencies, name)
 			dependency.dependent = append(dependency.dependent, s.Name)
 			graph[name] = dependency
@@ -107,6 +256,63 @@ func buildDependencyGraph(services types.Services) dependencyGraph {
 	return graph
 }
 
+// getSelectedServices returns the names of the services to consider, with their dependencies unless noDeps is set.
+// All project services are selected when none is named
+func getSelectedServices(project *types.Project, names []string, noDeps bool) (map[string]bool, error) {
+	if len(names) == 0 {
+		names = project.ServiceNames()
+	}
+	services, err := project.GetServices(names)
+	if err != nil {
+		return nil, err
+	}
+	selected := map[string]bool{}
+	for _, service := range services {
+		selectWithDependencies(project, service, noDeps, selected)
+	}
+	return selected, nil
+}
+
+func selectWithDependencies(project *types.Project, service types.ServiceConfig, noDeps bool, selected map[string]bool) {
+	if selected[service.Name] {
+		return
+	}
+	selected[service.Name] = true
+	if noDeps {
+		return
+	}
+	for _, name := range getDependencies(service) {
+		if dependency, err := project.GetService(name); err == nil {
+			selectWithDependencies(project, dependency, noDeps, selected)
+		}
+	}
+}
+
+// getDependencies returns names of the services this service depends on, stripping aliases from `links`. Services
+// set by volumes_from are included, as their containers must exist to mount their volumes
+func getDependencies(service types.ServiceConfig) []string {
+	var dependencies []string
+	for _, d := range service.GetDependencies() {
+		// links are declared as `service[:alias]`
+		name, _ := parseLink(d)
+		if !contains(dependencies, name) {
+			dependencies = append(dependencies, name)
+		}
+	}
+	for _, v := range service.VolumesFrom {
+		name := strings.Split(v, ":")[0]
+		if name != "container" && !contains(dependencies, name) {
+			dependencies = append(dependencies, name)
+		}
+	}
+	for _, mode := range []string{service.Ipc, service.Pid} {
+		if name := strings.TrimPrefix(mode, "service:"); name != mode && !contains(dependencies, name) {
+			dependencies = append(dependencies, name)
+		}
+	}
+	return dependencies
+}
+
 func remove(slice []string, item string) []string {
 	var s []string
 	for _, i := range slice {
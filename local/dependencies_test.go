@@ -24,6 +24,7 @@ import (
 
 	"gotest.tools/v3/assert"
 
+	"github.com/compose-spec/compose-go/loader"
 	"github.com/compose-spec/compose-go/types"
 )
 
@@ -57,3 +58,150 @@ func TestInDependencyOrder(t *testing.T) {
 	assert.Equal(t, <-order, "test2")
 	assert.Equal(t, <-order, "test1")
 }
+
+func TestBuildDependencyGraphWithLinkAlias(t *testing.T) {
+	graph := buildDependencyGraph([]types.ServiceConfig{
+		{
+			Name:  "web",
+			Links: []string{"db:database"},
+		},
+		{
+			Name: "db",
+		},
+	})
+	assert.Equal(t, len(graph), 2)
+	assert.DeepEqual(t, graph["web"].dependencies, []string{"db"})
+	assert.DeepEqual(t, graph["db"].dependent, []string{"web"})
+}
+
+func TestGetSelectedServices(t *testing.T) {
+	project := &types.Project{
+		Services: []types.ServiceConfig{
+			{
+				Name:      "web",
+				DependsOn: map[string]types.ServiceDependency{"api": {}},
+			},
+			{
+				Name:  "api",
+				Links: []string{"db:database"},
+			},
+			{
+				Name: "db",
+			},
+			{
+				Name: "admin",
+			},
+		},
+	}
+	selected, err := getSelectedServices(project, []string{"web"}, false)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, selected, map[string]bool{"web": true, "api": true, "db": true})
+
+	selected, err = getSelectedServices(project, []string{"web"}, true)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, selected, map[string]bool{"web": true})
+
+	selected, err = getSelectedServices(project, nil, true)
+	assert.NilError(t, err)
+	assert.Equal(t, len(selected), 4)
+
+	_, err = getSelectedServices(project, []string{"unknown"}, false)
+	assert.ErrorContains(t, err, "unknown")
+}
+
+func TestDependencyCycle(t *testing.T) {
+	project := types.Project{
+		Services: []types.ServiceConfig{
+			{
+				Name:      "web",
+				DependsOn: map[string]types.ServiceDependency{"api": {}},
+			},
+			{
+				Name:      "api",
+				DependsOn: map[string]types.ServiceDependency{"db": {}},
+			},
+			{
+				Name:  "db",
+				Links: []string{"web:frontend"},
+			},
+			{
+				Name: "cache",
+			},
+		},
+	}
+	err := inDependencyOrder(context.TODO(), &project, func(ctx context.Context, config types.ServiceConfig) error {
+		return nil
+	})
+	assert.Error(t, err, "dependency cycle detected: api -> db -> web -> api")
+}
+
+func TestInStartPriorityOrder(t *testing.T) {
+	project := types.Project{
+		Services: []types.ServiceConfig{
+			{Name: "worker", Extensions: map[string]interface{}{extStartPriority: 2}},
+			{Name: "cache", Extensions: map[string]interface{}{extStartPriority: 1}},
+			{
+				Name:       "web",
+				DependsOn:  map[string]types.ServiceDependency{"worker": {}},
+				Extensions: map[string]interface{}{extStartPriority: 0},
+			},
+		},
+	}
+	var order []string
+	err := inDependencyOrder(context.TODO(), &project, func(ctx context.Context, config types.ServiceConfig) error {
+		order = append(order, config.Name)
+		return nil
+	})
+	assert.NilError(t, err)
+	// priority doesn't let web start before worker it depends on
+	assert.DeepEqual(t, order, []string{"cache", "worker", "web"})
+
+	project.Services[0].Extensions[extStartPriority] = "first"
+	err = inDependencyOrder(context.TODO(), &project, func(ctx context.Context, config types.ServiceConfig) error {
+		return nil
+	})
+	assert.Error(t, err, `invalid x-start-priority for service "worker": expected an integer`)
+}
+
+func TestUnresolvedExtends(t *testing.T) {
+	base := "base"
+	project := types.Project{
+		Services: []types.ServiceConfig{
+			{Name: "web", Extends: types.ExtendsConfig{"service": &base}},
+		},
+	}
+	assert.Error(t, checkExtendsResolved(&project), `service "web" extends service "base", which was not resolved`)
+
+	project.Services[0].Extends = nil
+	assert.NilError(t, checkExtendsResolved(&project))
+}
+
+func TestLoadedExtendsAreResolved(t *testing.T) {
+	dict, err := loader.ParseYAML([]byte(`
+services:
+  base:
+    image: nginx
+    environment:
+      LEVEL: debug
+  web:
+    extends:
+      service: base
+    ports:
+      - "8080:80"
+`))
+	assert.NilError(t, err)
+	project, err := loader.Load(types.ConfigDetails{
+		ConfigFiles: []types.ConfigFile{{Config: dict}},
+	}, func(options *loader.Options) {
+		options.Name = "myproject"
+	})
+	assert.NilError(t, err)
+	web, err := project.GetService("web")
+	assert.NilError(t, err)
+	// loader merges base configuration but keeps extends set
+	assert.Equal(t, web.Image, "nginx")
+	assert.Assert(t, web.Extends != nil)
+
+	assert.NilError(t, checkExtendsResolved(project))
+	assert.NilError(t, validateProject(project))
+}
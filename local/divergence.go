@@ -0,0 +1,135 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/compose-spec/compose-go/types"
+	moby "github.com/docker/docker/api/types"
+	"github.com/opencontainers/go-digest"
+)
+
+const environmentField = "environment"
+
+// getRecordedConfig serializes service configuration as recorded by configLabel. Environment values are replaced by
+// their hash, as labels can be read by anyone having access to engine and values may be secrets
+func getRecordedConfig(service types.ServiceConfig) ([]byte, error) {
+	if len(service.Environment) > 0 {
+		environment := types.MappingWithEquals{}
+		for name, value := range service.Environment {
+			if value != nil {
+				hash := digest.SHA256.FromString(*value).String()
+				value = &hash
+			}
+			environment[name] = value
+		}
+		service.Environment = environment
+	}
+	return json.Marshal(service)
+}
+
+// getConfigDiff lists the service configuration fields which changed since container was created, as recorded by
+// configLabel. Changed environment variables are detailed by name, as `environment (FOO, BAR)`
+func getConfigDiff(container moby.Container, service types.ServiceConfig) ([]string, error) {
+	stored, ok := container.Labels[configLabel]
+	if !ok {
+		// container was created before configuration got recorded
+		return nil, nil
+	}
+	var previous map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(stored), &previous); err != nil {
+		return nil, err
+	}
+	b, err := getRecordedConfig(service)
+	if err != nil {
+		return nil, err
+	}
+	var current map[string]json.RawMessage
+	if err := json.Unmarshal(b, &current); err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for field := range previous {
+		if _, ok := current[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	for field, value := range current {
+		if !bytes.Equal(previous[field], value) {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	for i, field := range fields {
+		if field != environmentField {
+			continue
+		}
+		variables, err := getEnvironmentDiff(previous[field], current[field])
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = fmt.Sprintf("%s (%s)", field, strings.Join(variables, ", "))
+	}
+	return fields, nil
+}
+
+// getEnvironmentDiff lists the names of the variables added, removed or updated between two recorded environments
+func getEnvironmentDiff(previous, current json.RawMessage) ([]string, error) {
+	before := types.MappingWithEquals{}
+	after := types.MappingWithEquals{}
+	if len(previous) > 0 {
+		if err := json.Unmarshal(previous, &before); err != nil {
+			return nil, err
+		}
+	}
+	if len(current) > 0 {
+		if err := json.Unmarshal(current, &after); err != nil {
+			return nil, err
+		}
+	}
+
+	var variables []string
+	for name, value := range after {
+		old, ok := before[name]
+		if !ok || !sameValue(old, value) {
+			variables = append(variables, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			variables = append(variables, name)
+		}
+	}
+	sort.Strings(variables)
+	return variables, nil
+}
+
+func sameValue(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,66 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/types"
+	moby "github.com/docker/docker/api/types"
+	"gotest.tools/v3/assert"
+)
+
+func TestConfigDiff(t *testing.T) {
+	value := func(s string) *string {
+		return &s
+	}
+	service := types.ServiceConfig{
+		Name:        "web",
+		Image:       "nginx",
+		Environment: types.MappingWithEquals{"FOO": value("1"), "DEBUG": nil, "OBSOLETE": value("x")},
+	}
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+	config, _, _, err := getContainerCreateOptions(project, service, 1, nil)
+	assert.NilError(t, err)
+	container := moby.Container{Labels: config.Labels}
+	assert.Assert(t, !strings.Contains(config.Labels[configLabel], `"FOO":"1"`))
+	assert.Assert(t, strings.Contains(config.Labels[configLabel], `"DEBUG":null`))
+
+	diff, err := getConfigDiff(container, service)
+	assert.NilError(t, err)
+	assert.Equal(t, len(diff), 0)
+
+	service.Environment = types.MappingWithEquals{"FOO": value("2"), "DEBUG": nil, "BAR": value("new")}
+	diff, err = getConfigDiff(container, service)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, diff, []string{"environment (BAR, FOO, OBSOLETE)"})
+
+	service.Image = "nginx:alpine"
+	service.Ports = []types.ServicePortConfig{{Target: 80, Published: 8080}}
+	diff, err = getConfigDiff(container, service)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, diff, []string{"environment (BAR, FOO, OBSOLETE)", "image", "ports"})
+}
+
+func TestConfigDiffWithoutRecordedConfig(t *testing.T) {
+	diff, err := getConfigDiff(moby.Container{Labels: map[string]string{}}, types.ServiceConfig{Name: "web"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(diff), 0)
+}
@@ -0,0 +1,88 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/compose-spec/compose-go/types"
+
+	"github.com/docker/compose-cli/progress"
+)
+
+// auditEnvironment reports environment variables and build args of selected services which resolved to no value,
+// as those are silently passed empty to the container. Reported as warnings, or as an error when strict
+func auditEnvironment(ctx context.Context, project *types.Project, selected map[string]bool, strict bool) error {
+	w := progress.ContextWriter(ctx)
+	var unresolved []string
+	for _, service := range project.Services {
+		if !selected[service.Name] {
+			continue
+		}
+		variables := map[string]string{}
+		for key, value := range getUnresolvedVariables(service.Environment) {
+			variables["environment variable "+key] = value
+		}
+		if service.Build != nil {
+			for key, value := range getUnresolvedVariables(service.Build.Args) {
+				variables["build arg "+key] = value
+			}
+		}
+
+		var keys []string
+		for key := range variables {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			status := progress.Warning
+			if strict {
+				status = progress.Error
+			}
+			w.Event(progress.Event{
+				ID:         fmt.Sprintf("Service %q %s", service.Name, key),
+				Status:     status,
+				StatusText: variables[key],
+				Done:       true,
+			})
+			unresolved = append(unresolved, fmt.Sprintf("service %q %s is %s", service.Name, key, variables[key]))
+		}
+	}
+	if strict && len(unresolved) > 0 {
+		return fmt.Errorf("unresolved environment: %s", strings.Join(unresolved, ", "))
+	}
+	return nil
+}
+
+// getUnresolvedVariables returns entries of the mapping without a value, as "not set", or with an empty one, as "empty"
+func getUnresolvedVariables(mapping types.MappingWithEquals) map[string]string {
+	unresolved := map[string]string{}
+	for key, value := range mapping {
+		switch {
+		case value == nil:
+			unresolved[key] = "not set"
+		case *value == "":
+			unresolved[key] = "empty"
+		}
+	}
+	return unresolved
+}
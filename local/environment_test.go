@@ -0,0 +1,67 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"testing"
+
+	"github.com/compose-spec/compose-go/types"
+	"gotest.tools/v3/assert"
+
+	"github.com/docker/compose-cli/progress"
+)
+
+func TestAuditEnvironment(t *testing.T) {
+	empty := ""
+	value := "1"
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{
+		{
+			Name:  "web",
+			Image: "nginx",
+			Environment: types.MappingWithEquals{
+				// `TOKEN` is passed from an unset shell variable, `${DEBUG}` got interpolated from an unset one
+				"TOKEN": nil,
+				"DEBUG": &empty,
+				"LEVEL": &value,
+			},
+		},
+		{
+			Name:        "db",
+			Image:       "postgres",
+			Environment: types.MappingWithEquals{"PASSWORD": nil},
+		},
+	}}
+	selected := map[string]bool{"web": true}
+
+	w := &recordingWriter{}
+	err := auditEnvironment(progress.WithContextWriter(context.TODO(), w), project, selected, false)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, w.statuses(`Service "web" environment variable TOKEN`), []string{"not set"})
+	assert.DeepEqual(t, w.statuses(`Service "web" environment variable DEBUG`), []string{"empty"})
+	assert.Equal(t, len(w.events), 2)
+	for _, e := range w.events {
+		assert.Equal(t, e.Status, progress.Warning)
+	}
+
+	w = &recordingWriter{}
+	err = auditEnvironment(progress.WithContextWriter(context.TODO(), w), project, selected, true)
+	assert.Error(t, err, `unresolved environment: service "web" environment variable DEBUG is empty, service "web" environment variable TOKEN is not set`)
+	assert.Equal(t, w.events[0].Status, progress.Error)
+}
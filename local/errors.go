@@ -0,0 +1,64 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/docker/docker/client"
+	mobyerrdefs "github.com/docker/docker/errdefs"
+
+	"github.com/docker/compose-cli/errdefs"
+)
+
+// classifyEngineError wraps an error returned by the engine API, so callers can tell a misconfiguration
+// from an infrastructure failure. Errors which can't be classified are returned unchanged
+func classifyEngineError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case client.IsErrConnectionFailed(err), mobyerrdefs.IsUnavailable(err), mobyerrdefs.IsSystem(err):
+		return &errdefs.DaemonError{Err: err}
+	case mobyerrdefs.IsNotFound(err), mobyerrdefs.IsInvalidParameter(err), mobyerrdefs.IsConflict(err), mobyerrdefs.IsUnauthorized(err):
+		return &errdefs.ConfigError{Err: err}
+	}
+	return err
+}
+
+// isConflict returns true if the error, or the engine error a ConfigError wraps, is a conflict
+func isConflict(err error) bool {
+	var e *errdefs.ConfigError
+	if errors.As(err, &e) {
+		err = e.Err
+	}
+	return mobyerrdefs.IsConflict(err)
+}
+
+// isTransientNetworkError returns true if connecting to a network failed as it is not visible yet, or busy with a
+// concurrent operation, so that connecting again may succeed. Invalid endpoint configurations are not transient
+func isTransientNetworkError(err error) bool {
+	switch {
+	case mobyerrdefs.IsInvalidParameter(err):
+		return false
+	case mobyerrdefs.IsNotFound(err), mobyerrdefs.IsUnavailable(err):
+		return true
+	}
+	return strings.Contains(err.Error(), "busy")
+}
@@ -0,0 +1,102 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/compose-spec/compose-go/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	mobyerrdefs "github.com/docker/docker/errdefs"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"gotest.tools/v3/assert"
+
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/errdefs"
+)
+
+func TestImageNotFoundIsConfigError(t *testing.T) {
+	pullErr := mobyerrdefs.NotFound(errors.New("repository unknown/image not found"))
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+		Return(container.ContainerCreateCreatedBody{}, mobyerrdefs.NotFound(errors.New("no such image")))
+	apiClient.On("ImagePull", mock.Anything, "unknown/image", mock.Anything).Return(nil, pullErr)
+	s := newMockBackend(apiClient)
+
+	service := types.ServiceConfig{Name: "web", Image: "unknown/image"}
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+	err := s.runContainer(context.TODO(), project, service, "myproject_web_1", 1, nil, compose.UpOptions{})
+	assert.Assert(t, errdefs.IsConfigError(err))
+	assert.Assert(t, !errdefs.IsDaemonError(err))
+	assert.Equal(t, errors.Unwrap(err), pullErr)
+}
+
+func TestConnectionFailedIsDaemonError(t *testing.T) {
+	connErr := client.ErrorConnectionFailed("unix:///var/run/docker.sock")
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+		Return(container.ContainerCreateCreatedBody{}, connErr)
+	s := newMockBackend(apiClient)
+
+	service := types.ServiceConfig{Name: "web", Image: "nginx"}
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+	err := s.runContainer(context.TODO(), project, service, "myproject_web_1", 1, nil, compose.UpOptions{})
+	assert.Assert(t, errdefs.IsDaemonError(err))
+	assert.Assert(t, !errdefs.IsConfigError(err))
+	assert.Equal(t, errors.Unwrap(err), connErr)
+}
+
+func TestNetworkConnectRetriesUntilNetworkExists(t *testing.T) {
+	defer func(delay time.Duration) { networkConnectRetryDelay = delay }(networkConnectRetryDelay)
+	networkConnectRetryDelay = time.Millisecond
+	notFound := mobyerrdefs.NotFound(errors.New("network myproject_back not found"))
+	apiClient := &mockAPIClient{}
+	apiClient.On("NetworkConnect", mock.Anything, "myproject_back", "abc", mock.Anything).Return(notFound).Once()
+	apiClient.On("NetworkConnect", mock.Anything, "myproject_back", "abc", mock.Anything).Return(nil).Once()
+	s := newMockBackend(apiClient)
+
+	err := s.connectContainerToNetwork(context.TODO(), "abc", "web", "myproject_back", nil)
+	assert.NilError(t, err)
+	apiClient.AssertNumberOfCalls(t, "NetworkConnect", 2)
+}
+
+func TestNetworkConnectFailsFastOnInvalidConfig(t *testing.T) {
+	defer func(delay time.Duration) { networkConnectRetryDelay = delay }(networkConnectRetryDelay)
+	networkConnectRetryDelay = time.Millisecond
+	invalid := mobyerrdefs.InvalidParameter(errors.New("invalid alias"))
+	apiClient := &mockAPIClient{}
+	apiClient.On("NetworkConnect", mock.Anything, "myproject_back", "abc", mock.Anything).Return(invalid)
+	s := newMockBackend(apiClient)
+
+	err := s.connectContainerToNetwork(context.TODO(), "abc", "web", "myproject_back", nil)
+	assert.Assert(t, errdefs.IsConfigError(err))
+	apiClient.AssertNumberOfCalls(t, "NetworkConnect", 1)
+
+	// transient failures are retried a bounded number of times
+	apiClient = &mockAPIClient{}
+	apiClient.On("NetworkConnect", mock.Anything, "myproject_back", "abc", mock.Anything).Return(errors.New("endpoint is busy"))
+	s = newMockBackend(apiClient)
+	err = s.connectContainerToNetwork(context.TODO(), "abc", "web", "myproject_back", nil)
+	assert.Error(t, err, "endpoint is busy")
+	apiClient.AssertNumberOfCalls(t, "NetworkConnect", networkConnectAttempts)
+}
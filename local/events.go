@@ -0,0 +1,71 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/docker/compose-cli/api/compose"
+)
+
+// Events streams lifecycle events of the project containers, as reported by engine, until context is cancelled
+func (s *local) Events(ctx context.Context, projectName string, options compose.EventsOptions) error {
+	events, errs := s.containerService.apiClient.Events(ctx, moby.EventsOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", "container"),
+			projectFilter(projectName),
+		),
+	})
+	for {
+		select {
+		case event := <-events:
+			service := event.Actor.Attributes[serviceLabel]
+			if len(options.Services) > 0 && !contains(options.Services, service) {
+				continue
+			}
+			// compose labels are implementation details, only user defined labels and container attributes are reported
+			attributes := map[string]string{}
+			for k, v := range event.Actor.Attributes {
+				if !strings.HasPrefix(k, "com.docker.compose.") {
+					attributes[k] = v
+				}
+			}
+			err := options.Consumer(compose.Event{
+				Timestamp:  time.Unix(0, event.TimeNano),
+				Service:    service,
+				Container:  event.Actor.ID,
+				Status:     event.Action,
+				Attributes: attributes,
+			})
+			if err != nil {
+				return err
+			}
+		case err := <-errs:
+			if err == context.Canceled {
+				return nil
+			}
+			return err
+		}
+	}
+}
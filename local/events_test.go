@@ -0,0 +1,87 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/stretchr/testify/mock"
+	"gotest.tools/v3/assert"
+
+	"github.com/docker/compose-cli/api/compose"
+)
+
+func TestEvents(t *testing.T) {
+	messages := make(chan events.Message)
+	errs := make(chan error, 1)
+	apiClient := &mockAPIClient{}
+	apiClient.On("Events", mock.Anything, moby.EventsOptions{
+		Filters: filters.NewArgs(filters.Arg("type", "container"), projectFilter("myproject")),
+	}).Return(messages, errs)
+	s := newMockBackend(apiClient)
+
+	event := func(id string, service string, action string) events.Message {
+		return events.Message{
+			Type:   "container",
+			Action: action,
+			Actor: events.Actor{
+				ID: id,
+				Attributes: map[string]string{
+					projectLabel: "myproject",
+					serviceLabel: service,
+					"name":       "myproject_" + service + "_1",
+					"image":      "nginx",
+				},
+			},
+			TimeNano: time.Date(2020, 11, 20, 10, 0, 0, 0, time.UTC).UnixNano(),
+		}
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	go func() {
+		messages <- event("c1", "web", "start")
+		messages <- event("c2", "db", "start")
+		messages <- event("c1", "web", "die")
+		cancel()
+		errs <- context.Canceled
+	}()
+
+	var received []compose.Event
+	err := s.Events(ctx, "myproject", compose.EventsOptions{
+		Services: []string{"web"},
+		Consumer: func(event compose.Event) error {
+			received = append(received, event)
+			return nil
+		},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(received), 2)
+	assert.DeepEqual(t, received[0], compose.Event{
+		Timestamp:  time.Date(2020, 11, 20, 10, 0, 0, 0, time.UTC),
+		Service:    "web",
+		Container:  "c1",
+		Status:     "start",
+		Attributes: map[string]string{"name": "myproject_web_1", "image": "nginx"},
+	})
+	assert.Equal(t, received[1].Status, "die")
+}
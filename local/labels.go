@@ -19,18 +19,48 @@
 package local
 
 import (
+	"encoding/json"
 	"fmt"
 
+	"github.com/compose-spec/compose-go/types"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/pkg/errors"
+
+	"github.com/docker/compose-cli/api/compose"
 )
 
 const (
-	projectLabel         = "com.docker.compose.project"
-	serviceLabel         = "com.docker.compose.service"
-	configHashLabel      = "com.docker.compose.config-hash"
-	containerNumberLabel = "com.docker.compose.container-number"
+	projectLabel           = "com.docker.compose.project"
+	serviceLabel           = "com.docker.compose.service"
+	configHashLabel        = "com.docker.compose.config-hash"
+	configHashVersionLabel = "com.docker.compose.config-hash-version"
+	containerNumberLabel   = compose.ContainerNumberTag
+	replicaLabel           = compose.ReplicaTag
+	// dependenciesLabel records services a container's service depends on, as a comma separated list
+	dependenciesLabel = "com.docker.compose.depends_on"
+	// fileReferencesHashLabel records the hash of the content of files defining secrets and configs container uses,
+	// when created with UpOptions.RecreateChangedFiles
+	fileReferencesHashLabel = "com.docker.compose.files-hash"
+	// configLabel records the service configuration container was created from, serialized as JSON with environment
+	// values hashed
+	configLabel = "com.docker.compose.config"
+	// workingDirLabel, oneoffLabel and versionLabel are set by docker-compose as well, so other tools recognize
+	// containers of a compose project
+	workingDirLabel = "com.docker.compose.project.working_dir"
+	oneoffLabel     = "com.docker.compose.oneoff"
+	versionLabel    = "com.docker.compose.version"
+	// annotationLabelPrefix is prepended to the keys of annotations set as labels, so they can't override labels
+	// set by compose or declared by the service
+	annotationLabelPrefix = "annotation."
+	extAnnotations        = "x-annotations"
 )
 
+// configHashVersion identifies the algorithm used to compute configHashLabel.
+// It MUST be incremented whenever jsonHash or the serialized form of ServiceConfig changes, so that
+// hashes computed by distinct versions are never compared. Containers created before this label was
+// introduced don't have it set and are considered version "1".
+const configHashVersion = "1"
+
 func projectFilter(projectName string) filters.KeyValuePair {
 	return filters.Arg("label", fmt.Sprintf("%s=%s", projectLabel, projectName))
 }
@@ -38,3 +68,30 @@ func projectFilter(projectName string) filters.KeyValuePair {
 func hasProjectLabelFilter() filters.KeyValuePair {
 	return filters.Arg("label", projectLabel)
 }
+
+// getAnnotations returns annotations declared by x-annotations, as the compose model doesn't retain `annotations`,
+// keyed by the label they are set on containers as
+func getAnnotations(service types.ServiceConfig) (map[string]string, error) {
+	v, ok := service.Extensions[extAnnotations]
+	if !ok {
+		return nil, nil
+	}
+	marshalled, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var annotations map[string]string
+	err = json.Unmarshal(marshalled, &annotations)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s for service %q", extAnnotations, service.Name)
+	}
+	labels := map[string]string{}
+	for k, v := range annotations {
+		label := annotationLabelPrefix + k
+		if _, ok := service.Labels[label]; ok {
+			return nil, fmt.Errorf("annotation %q of service %q conflicts with label %q", k, service.Name, label)
+		}
+		labels[label] = v
+	}
+	return labels, nil
+}
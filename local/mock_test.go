@@ -0,0 +1,238 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/docker/compose-cli/progress"
+)
+
+// mockAPIClient mocks the docker engine API. Methods not explicitly mocked panic when invoked.
+type mockAPIClient struct {
+	client.APIClient
+	mock.Mock
+}
+
+func newMockBackend(apiClient *mockAPIClient) *local {
+	return &local{
+		containerService: &containerService{apiClient},
+		volumeService:    &volumeService{apiClient},
+	}
+}
+
+func (m *mockAPIClient) ContainerList(ctx context.Context, options moby.ContainerListOptions) ([]moby.Container, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).([]moby.Container), args.Error(1)
+}
+
+func (m *mockAPIClient) ContainerInspect(ctx context.Context, container string) (moby.ContainerJSON, error) {
+	args := m.Called(ctx, container)
+	return args.Get(0).(moby.ContainerJSON), args.Error(1)
+}
+
+func (m *mockAPIClient) ContainerExecCreate(ctx context.Context, container string, config moby.ExecConfig) (moby.IDResponse, error) {
+	args := m.Called(ctx, container, config)
+	return args.Get(0).(moby.IDResponse), args.Error(1)
+}
+
+func (m *mockAPIClient) ContainerExecStart(ctx context.Context, execID string, config moby.ExecStartCheck) error {
+	args := m.Called(ctx, execID, config)
+	return args.Error(0)
+}
+
+func (m *mockAPIClient) ContainerExecInspect(ctx context.Context, execID string) (moby.ContainerExecInspect, error) {
+	args := m.Called(ctx, execID)
+	return args.Get(0).(moby.ContainerExecInspect), args.Error(1)
+}
+
+func (m *mockAPIClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, containerName string) (container.ContainerCreateCreatedBody, error) {
+	args := m.Called(ctx, config, hostConfig, networkingConfig, containerName)
+	return args.Get(0).(container.ContainerCreateCreatedBody), args.Error(1)
+}
+
+func (m *mockAPIClient) ContainerStart(ctx context.Context, container string, options moby.ContainerStartOptions) error {
+	args := m.Called(ctx, container, options)
+	return args.Error(0)
+}
+
+func (m *mockAPIClient) ContainerStop(ctx context.Context, container string, timeout *time.Duration) error {
+	args := m.Called(ctx, container, timeout)
+	return args.Error(0)
+}
+
+func (m *mockAPIClient) ContainerRename(ctx context.Context, container, newContainerName string) error {
+	args := m.Called(ctx, container, newContainerName)
+	return args.Error(0)
+}
+
+func (m *mockAPIClient) ContainerRemove(ctx context.Context, container string, options moby.ContainerRemoveOptions) error {
+	args := m.Called(ctx, container, options)
+	return args.Error(0)
+}
+
+func (m *mockAPIClient) NetworkInspect(ctx context.Context, network string, options moby.NetworkInspectOptions) (moby.NetworkResource, error) {
+	args := m.Called(ctx, network, options)
+	return args.Get(0).(moby.NetworkResource), args.Error(1)
+}
+
+func (m *mockAPIClient) NetworkCreate(ctx context.Context, name string, options moby.NetworkCreate) (moby.NetworkCreateResponse, error) {
+	args := m.Called(ctx, name, options)
+	return args.Get(0).(moby.NetworkCreateResponse), args.Error(1)
+}
+
+func (m *mockAPIClient) NetworkList(ctx context.Context, options moby.NetworkListOptions) ([]moby.NetworkResource, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).([]moby.NetworkResource), args.Error(1)
+}
+
+func (m *mockAPIClient) NetworkConnect(ctx context.Context, network, container string, config *network.EndpointSettings) error {
+	args := m.Called(ctx, network, container, config)
+	return args.Error(0)
+}
+
+func (m *mockAPIClient) NetworkDisconnect(ctx context.Context, network, container string, force bool) error {
+	args := m.Called(ctx, network, container, force)
+	return args.Error(0)
+}
+
+func (m *mockAPIClient) NetworkRemove(ctx context.Context, network string) error {
+	args := m.Called(ctx, network)
+	return args.Error(0)
+}
+
+// recordingWriter is a progress.Writer collecting events
+type recordingWriter struct {
+	events []progress.Event
+	mtx    sync.Mutex
+}
+
+func (w *recordingWriter) Start(context.Context) error {
+	return nil
+}
+
+func (w *recordingWriter) Stop() {
+}
+
+func (w *recordingWriter) Event(e progress.Event) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.events = append(w.events, e)
+}
+
+func (w *recordingWriter) statuses(id string) []string {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	var statuses []string
+	for _, e := range w.events {
+		if e.ID == id {
+			statuses = append(statuses, e.StatusText)
+		}
+	}
+	return statuses
+}
+
+func (m *mockAPIClient) VolumeCreate(ctx context.Context, options volume.VolumeCreateBody) (moby.Volume, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).(moby.Volume), args.Error(1)
+}
+
+func (m *mockAPIClient) VolumeInspect(ctx context.Context, volumeID string) (moby.Volume, error) {
+	args := m.Called(ctx, volumeID)
+	return args.Get(0).(moby.Volume), args.Error(1)
+}
+
+func (m *mockAPIClient) VolumeList(ctx context.Context, filter filters.Args) (volume.VolumeListOKBody, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(volume.VolumeListOKBody), args.Error(1)
+}
+
+func (m *mockAPIClient) VolumeRemove(ctx context.Context, volumeID string, force bool) error {
+	args := m.Called(ctx, volumeID, force)
+	return args.Error(0)
+}
+
+func (m *mockAPIClient) ImagePull(ctx context.Context, ref string, options moby.ImagePullOptions) (io.ReadCloser, error) {
+	args := m.Called(ctx, ref, options)
+	stream, _ := args.Get(0).(io.ReadCloser)
+	return stream, args.Error(1)
+}
+
+func (m *mockAPIClient) ImageBuild(ctx context.Context, buildContext io.Reader, options moby.ImageBuildOptions) (moby.ImageBuildResponse, error) {
+	// drain the build context before recording the call, as the archive is written by another goroutine
+	// which would race with the mock formatting its arguments
+	content, err := ioutil.ReadAll(buildContext)
+	if err != nil {
+		return moby.ImageBuildResponse{}, err
+	}
+	if closer, ok := buildContext.(io.Closer); ok {
+		_ = closer.Close()
+	}
+	args := m.Called(ctx, bytes.NewReader(content), options)
+	return args.Get(0).(moby.ImageBuildResponse), args.Error(1)
+}
+
+func (m *mockAPIClient) ContainerLogs(ctx context.Context, container string, options moby.ContainerLogsOptions) (io.ReadCloser, error) {
+	args := m.Called(ctx, container, options)
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *mockAPIClient) ContainerPause(ctx context.Context, container string) error {
+	args := m.Called(ctx, container)
+	return args.Error(0)
+}
+
+func (m *mockAPIClient) ContainerUnpause(ctx context.Context, container string) error {
+	args := m.Called(ctx, container)
+	return args.Error(0)
+}
+
+func (m *mockAPIClient) ServerVersion(ctx context.Context) (moby.Version, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(moby.Version), args.Error(1)
+}
+
+func (m *mockAPIClient) ImageInspectWithRaw(ctx context.Context, image string) (moby.ImageInspect, []byte, error) {
+	args := m.Called(ctx, image)
+	return args.Get(0).(moby.ImageInspect), nil, args.Error(1)
+}
+
+func (m *mockAPIClient) Events(ctx context.Context, options moby.EventsOptions) (<-chan events.Message, <-chan error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).(chan events.Message), args.Get(1).(chan error)
+}
+
+func (m *mockAPIClient) ContainerTop(ctx context.Context, id string, arguments []string) (container.ContainerTopOKBody, error) {
+	args := m.Called(ctx, id, arguments)
+	return args.Get(0).(container.ContainerTopOKBody), args.Error(1)
+}
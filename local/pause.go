@@ -0,0 +1,91 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/progress"
+)
+
+// Pause suspends processes of the running containers of the project, paused or stopped ones are left unchanged
+func (s *local) Pause(ctx context.Context, projectName string, options compose.PauseOptions) error {
+	return s.toggleContainers(ctx, projectName, options, "running", "Pausing", "Paused", s.containerService.apiClient.ContainerPause)
+}
+
+// Unpause resumes processes of the paused containers of the project
+func (s *local) Unpause(ctx context.Context, projectName string, options compose.PauseOptions) error {
+	return s.toggleContainers(ctx, projectName, options, "paused", "Unpausing", "Unpaused", s.containerService.apiClient.ContainerUnpause)
+}
+
+// toggleContainers applies fn to the containers of selected services being in state
+func (s *local) toggleContainers(ctx context.Context, projectName string, options compose.PauseOptions, state string, working string, done string, fn func(ctx context.Context, container string) error) error {
+	list, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
+		Filters: filters.NewArgs(
+			projectFilter(projectName),
+		),
+		All: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	selected, err := getSelectedServicesFromContainers(list, options.Services)
+	if err != nil {
+		return err
+	}
+
+	limit, err := getParallelLimit(0)
+	if err != nil {
+		return err
+	}
+
+	w := progress.ContextWriter(ctx)
+	eg, ctx := newLimitedGroup(ctx, limit)
+	for _, c := range list {
+		if !selected[c.Labels[serviceLabel]] || c.State != state {
+			continue
+		}
+		container := c
+		eg.Go(func() error {
+			w.Event(progress.Event{
+				ID:     getContainerName(container),
+				Text:   working,
+				Status: progress.Working,
+				Done:   false,
+			})
+			err := fn(ctx, container.ID)
+			if err != nil {
+				return classifyEngineError(err)
+			}
+			w.Event(progress.Event{
+				ID:     getContainerName(container),
+				Text:   done,
+				Status: progress.Done,
+				Done:   true,
+			})
+			return nil
+		})
+	}
+	return eg.Wait()
+}
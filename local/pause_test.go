@@ -0,0 +1,80 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"testing"
+
+	moby "github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/mock"
+	"gotest.tools/v3/assert"
+
+	"github.com/docker/compose-cli/api/compose"
+)
+
+func projectContainers() []moby.Container {
+	return []moby.Container{
+		{ID: "web1", Names: []string{"/myproject_web_1"}, State: "running", Labels: map[string]string{serviceLabel: "web"}},
+		{ID: "web2", Names: []string{"/myproject_web_2"}, State: "paused", Labels: map[string]string{serviceLabel: "web"}},
+		{ID: "db1", Names: []string{"/myproject_db_1"}, State: "running", Labels: map[string]string{serviceLabel: "db"}},
+		{ID: "cache1", Names: []string{"/myproject_cache_1"}, State: "exited", Labels: map[string]string{serviceLabel: "cache"}},
+	}
+}
+
+func TestPause(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return(projectContainers(), nil)
+	apiClient.On("ContainerPause", mock.Anything, "web1").Return(nil).Once()
+	apiClient.On("ContainerPause", mock.Anything, "db1").Return(nil).Once()
+	s := newMockBackend(apiClient)
+
+	err := s.Pause(context.TODO(), "myproject", compose.PauseOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	apiClient.AssertNotCalled(t, "ContainerPause", mock.Anything, "web2")
+	apiClient.AssertNotCalled(t, "ContainerPause", mock.Anything, "cache1")
+}
+
+func TestPauseSelectedServices(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return(projectContainers(), nil)
+	apiClient.On("ContainerPause", mock.Anything, "db1").Return(nil).Once()
+	s := newMockBackend(apiClient)
+
+	err := s.Pause(context.TODO(), "myproject", compose.PauseOptions{Services: []string{"db"}})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	apiClient.AssertNotCalled(t, "ContainerPause", mock.Anything, "web1")
+
+	err = s.Pause(context.TODO(), "myproject", compose.PauseOptions{Services: []string{"queue"}})
+	assert.Error(t, err, `no container found for service "queue"`)
+}
+
+func TestUnpause(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return(projectContainers(), nil)
+	apiClient.On("ContainerUnpause", mock.Anything, "web2").Return(nil).Once()
+	s := newMockBackend(apiClient)
+
+	err := s.Unpause(context.TODO(), "myproject", compose.PauseOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	apiClient.AssertNumberOfCalls(t, "ContainerUnpause", 1)
+}
@@ -0,0 +1,121 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"sync"
+
+	"github.com/compose-spec/compose-go/types"
+	moby "github.com/docker/docker/api/types"
+
+	"github.com/docker/compose-cli/api/compose"
+)
+
+// Plan computes the actions Up would apply for project services to converge, without applying them
+func (s *local) Plan(ctx context.Context, project *types.Project) ([]compose.ServicePlan, error) {
+	var mtx sync.Mutex
+	plans := map[string]compose.ServicePlan{}
+	recreated := map[string]bool{}
+	err := inDependencyOrder(ctx, project, func(ctx context.Context, service types.ServiceConfig) error {
+		actual, err := s.getServiceContainers(ctx, project, service)
+		if err != nil {
+			return err
+		}
+		// Up first reconciles containers left by an interrupted recreate
+		actual, _ = resolveInterruptedRecreates(actual)
+		imageID, err := s.getImageID(ctx, project, service)
+		if err != nil {
+			return err
+		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		// Up recreates services depending on a recreated one
+		dependencyRecreated := false
+		for _, dep := range getDependencies(service) {
+			dependencyRecreated = dependencyRecreated || recreated[dep]
+		}
+		plan, err := planService(project, service, actual, imageID, dependencyRecreated)
+		if err != nil {
+			return err
+		}
+		plans[service.Name] = plan
+		for _, c := range plan.Containers {
+			recreated[service.Name] = recreated[service.Name] || c.Action == compose.ActionRecreate
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []compose.ServicePlan
+	for _, service := range project.Services {
+		result = append(result, plans[service.Name])
+	}
+	return result, nil
+}
+
+// planService computes the actions required for actual containers to converge to the service configuration
+func planService(project *types.Project, service types.ServiceConfig, actual []moby.Container, imageID string, recreate bool) (compose.ServicePlan, error) {
+	plan := compose.ServicePlan{Name: service.Name}
+
+	lifecycle, err := getLifecycle(service)
+	if err != nil {
+		return plan, err
+	}
+	if recreate {
+		lifecycle.Strategy = forceRecreate
+	}
+
+	scale := getScale(service)
+	if len(actual) < scale {
+		next, err := nextContainerNumber(actual)
+		if err != nil {
+			return plan, err
+		}
+		for i := 0; i < scale-len(actual); i++ {
+			plan.Containers = append(plan.Containers, compose.ContainerPlan{
+				Name:   compose.GetContainerName(project.Name, service.Name, next+i),
+				Action: compose.ActionCreate,
+			})
+		}
+	}
+
+	actual, obsolete := splitReplicas(actual, scale)
+	for _, container := range obsolete {
+		plan.Containers = append(plan.Containers, compose.ContainerPlan{
+			Name:   getContainerName(container),
+			Action: compose.ActionRemove,
+		})
+	}
+
+	expected, err := jsonHash(service)
+	if err != nil {
+		return plan, err
+	}
+	for _, container := range actual {
+		plan.Containers = append(plan.Containers, compose.ContainerPlan{
+			Name:   getContainerName(container),
+			Action: getContainerAction(service, lifecycle, container, expected, imageID, compose.RecreateDiverged),
+		})
+	}
+	return plan, nil
+}
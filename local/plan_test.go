@@ -0,0 +1,118 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/compose-spec/compose-go/types"
+	moby "github.com/docker/docker/api/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/mock"
+	"gotest.tools/v3/assert"
+
+	"github.com/docker/compose-cli/api/compose"
+)
+
+func planContainer(name string, number string, state string, hash string) moby.Container {
+	return moby.Container{
+		ID:     digest.FromString(name).Encoded(),
+		Names:  []string{"/" + name},
+		Image:  "nginx",
+		State:  state,
+		Labels: map[string]string{containerNumberLabel: number, configHashLabel: hash},
+	}
+}
+
+func TestPlanService(t *testing.T) {
+	replicas := uint64(3)
+	service := types.ServiceConfig{
+		Name:   "web",
+		Image:  "nginx",
+		Deploy: &types.DeployConfig{Replicas: &replicas},
+	}
+	hash, err := jsonHash(service)
+	assert.NilError(t, err)
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+
+	plan, err := planService(project, service, []moby.Container{
+		planContainer("myproject_web_1", "1", "running", hash),
+		planContainer("myproject_web_2", "2", "exited", hash),
+	}, "", false)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, plan, compose.ServicePlan{
+		Name: "web",
+		Containers: []compose.ContainerPlan{
+			{Name: "myproject_web_3", Action: compose.ActionCreate},
+			{Name: "myproject_web_1", Action: compose.ActionNone},
+			{Name: "myproject_web_2", Action: compose.ActionRestart},
+		},
+	})
+
+	replicas = 1
+	plan, err = planService(project, service, []moby.Container{
+		planContainer("myproject_web_2", "2", "running", hash),
+		planContainer("myproject_web_1", "1", "running", "outdated"),
+	}, "", false)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, plan, compose.ServicePlan{
+		Name: "web",
+		Containers: []compose.ContainerPlan{
+			{Name: "myproject_web_2", Action: compose.ActionRemove},
+			{Name: "myproject_web_1", Action: compose.ActionRecreate},
+		},
+	})
+}
+
+func TestPlanRecreatesDependents(t *testing.T) {
+	db := types.ServiceConfig{Name: "db", Image: "nginx"}
+	web := types.ServiceConfig{
+		Name:      "web",
+		Image:     "nginx",
+		DependsOn: types.DependsOnConfig{"db": types.ServiceDependency{}},
+	}
+	webHash, err := jsonHash(web)
+	assert.NilError(t, err)
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{web, db}}
+
+	forService := func(name string) interface{} {
+		return mock.MatchedBy(func(options moby.ContainerListOptions) bool {
+			return options.Filters.ExactMatch("label", fmt.Sprintf("%s=%s", serviceLabel, name))
+		})
+	}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, forService("db")).Return([]moby.Container{
+		planContainer("myproject_db_1", "1", "running", "outdated"),
+	}, nil)
+	apiClient.On("ContainerList", mock.Anything, forService("web")).Return([]moby.Container{
+		planContainer("myproject_web_1", "1", "running", webHash),
+	}, nil)
+	s := newMockBackend(apiClient)
+
+	plans, err := s.Plan(context.TODO(), project)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, plans, []compose.ServicePlan{
+		{Name: "web", Containers: []compose.ContainerPlan{{Name: "myproject_web_1", Action: compose.ActionRecreate}}},
+		{Name: "db", Containers: []compose.ContainerPlan{{Name: "myproject_db_1", Action: compose.ActionRecreate}}},
+	})
+	apiClient.AssertNotCalled(t, "ContainerStop", mock.Anything, mock.Anything, mock.Anything)
+}
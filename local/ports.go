@@ -0,0 +1,95 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/compose-spec/compose-go/types"
+	moby "github.com/docker/docker/api/types"
+)
+
+// checkPortConflicts collects host ports published by selected services, and fails before any container is created
+// when the same port is requested twice within the project, or is already bound by a container of another project
+func (s *local) checkPortConflicts(ctx context.Context, project *types.Project, selected map[string]bool, scale map[string]int) error {
+	requested := map[string]string{}
+	var conflicts []string
+	for _, service := range project.Services {
+		if !selected[service.Name] {
+			continue
+		}
+		replicas := getScale(service)
+		if r, ok := scale[service.Name]; ok {
+			replicas = r
+		}
+		if replicas == 0 {
+			continue
+		}
+		for _, port := range service.Ports {
+			if port.Published == 0 {
+				continue
+			}
+			key := fmt.Sprintf("%d/%s", port.Published, getProtocol(port.Protocol))
+			if replicas > 1 {
+				conflicts = append(conflicts, fmt.Sprintf("port %s is published by the %d replicas of service %q", key, replicas, service.Name))
+			}
+			if other, ok := requested[key]; ok && other != service.Name {
+				conflicts = append(conflicts, fmt.Sprintf("port %s is published by both services %q and %q", key, other, service.Name))
+				continue
+			}
+			requested[key] = service.Name
+		}
+	}
+	if len(requested) == 0 || len(conflicts) > 0 {
+		return conflictsError(conflicts)
+	}
+
+	containers, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		if c.Labels[projectLabel] == project.Name {
+			// ports bound by the project containers are released as those get recreated
+			continue
+		}
+		bound := map[string]bool{}
+		for _, port := range c.Ports {
+			key := fmt.Sprintf("%d/%s", port.PublicPort, getProtocol(port.Type))
+			service, ok := requested[key]
+			if port.PublicPort == 0 || !ok || bound[key] {
+				continue
+			}
+			bound[key] = true
+			conflicts = append(conflicts, fmt.Sprintf("port %s requested by service %q is already bound by container %s", key, service, getContainerName(c)))
+		}
+	}
+	return conflictsError(conflicts)
+}
+
+func conflictsError(conflicts []string) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Strings(conflicts)
+	return fmt.Errorf("port conflict: %s", strings.Join(conflicts, ", "))
+}
@@ -0,0 +1,79 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"testing"
+
+	"github.com/compose-spec/compose-go/types"
+	moby "github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/mock"
+	"gotest.tools/v3/assert"
+
+	"github.com/docker/compose-cli/api/compose"
+)
+
+func TestUpReportsPortConflictsBeforeCreate(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ServerVersion", mock.Anything).Return(moby.Version{APIVersion: "1.41"}, nil)
+	s := newMockBackend(apiClient)
+
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{
+		{Name: "web", Image: "nginx", Ports: []types.ServicePortConfig{{Target: 80, Published: 8080}}},
+		{Name: "api", Image: "nginx", Ports: []types.ServicePortConfig{{Target: 8000, Published: 8080, Protocol: "tcp"}}},
+	}}
+	err := s.Up(context.TODO(), project, compose.UpOptions{})
+	assert.Error(t, err, `port conflict: port 8080/tcp is published by both services "web" and "api"`)
+	apiClient.AssertNotCalled(t, "ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPortConflictsWithBoundPorts(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, moby.ContainerListOptions{}).Return([]moby.Container{
+		{
+			ID:     "c1",
+			Names:  []string{"/proxy"},
+			Labels: map[string]string{projectLabel: "other"},
+			Ports: []moby.Port{
+				{IP: "0.0.0.0", PrivatePort: 80, PublicPort: 8080, Type: "tcp"},
+				{IP: "::", PrivatePort: 80, PublicPort: 8080, Type: "tcp"},
+				{IP: "0.0.0.0", PrivatePort: 53, PublicPort: 8080, Type: "udp"},
+			},
+		},
+		{
+			ID:     "c2",
+			Names:  []string{"/myproject_web_1"},
+			Labels: map[string]string{projectLabel: "myproject", serviceLabel: "web"},
+			Ports:  []moby.Port{{IP: "0.0.0.0", PrivatePort: 80, PublicPort: 9090, Type: "tcp"}},
+		},
+	}, nil)
+	s := newMockBackend(apiClient)
+
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{
+		{Name: "web", Image: "nginx", Ports: []types.ServicePortConfig{{Target: 80, Published: 9090}}},
+		{Name: "api", Image: "nginx", Ports: []types.ServicePortConfig{{Target: 8000, Published: 8080}}},
+	}}
+	selected := map[string]bool{"web": true, "api": true}
+	err := s.checkPortConflicts(context.TODO(), project, selected, nil)
+	assert.Error(t, err, `port conflict: port 8080/tcp requested by service "api" is already bound by container proxy`)
+
+	err = s.checkPortConflicts(context.TODO(), project, selected, map[string]int{"api": 0})
+	assert.NilError(t, err)
+}
@@ -0,0 +1,122 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"fmt"
+
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/pkg/errors"
+
+	"github.com/docker/compose-cli/progress"
+)
+
+// Prune removes stopped containers of the project, typically left by scaling down or removing services, then the
+// project networks no container is attached to anymore
+func (s *local) Prune(ctx context.Context, projectName string) error {
+	list, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
+		Filters: filters.NewArgs(
+			projectFilter(projectName),
+		),
+		All: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	w := progress.ContextWriter(ctx)
+	for _, c := range list {
+		// filtering is applied by engine, but better safe than sorry when deleting resources
+		if c.Labels[projectLabel] != projectName || !isStopped(c) {
+			continue
+		}
+		name := getContainerName(c)
+		w.Event(progress.Event{
+			ID:     name,
+			Text:   "Removing",
+			Status: progress.Working,
+		})
+		err := s.containerService.apiClient.ContainerRemove(ctx, c.ID, moby.ContainerRemoveOptions{})
+		if err != nil {
+			return classifyEngineError(err)
+		}
+		w.Event(progress.Event{
+			ID:     name,
+			Text:   "Removed",
+			Status: progress.Done,
+			Done:   true,
+		})
+	}
+
+	networks, err := s.containerService.apiClient.NetworkList(ctx, moby.NetworkListOptions{
+		Filters: filters.NewArgs(
+			projectFilter(projectName),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	for _, n := range networks {
+		if n.Labels[projectLabel] != projectName {
+			continue
+		}
+		// network list doesn't report attached containers
+		inspect, err := s.containerService.apiClient.NetworkInspect(ctx, n.ID, moby.NetworkInspectOptions{})
+		if err != nil {
+			return err
+		}
+		if len(inspect.Containers) > 0 {
+			continue
+		}
+		eventName := fmt.Sprintf("Network %q", n.Name)
+		w.Event(progress.Event{
+			ID:         eventName,
+			Status:     progress.Working,
+			StatusText: "Remove",
+		})
+		err = s.containerService.apiClient.NetworkRemove(ctx, n.ID)
+		if err != nil {
+			w.Event(progress.Event{
+				ID:         eventName,
+				Status:     progress.Error,
+				StatusText: "Error",
+				Done:       true,
+			})
+			return errors.Wrapf(err, "failed to remove network %s", n.Name)
+		}
+		w.Event(progress.Event{
+			ID:         eventName,
+			Status:     progress.Done,
+			StatusText: "Removed",
+			Done:       true,
+		})
+	}
+	return nil
+}
+
+// isStopped checks container exited or was never started. Paused and restarting containers are not stopped
+func isStopped(c moby.Container) bool {
+	switch c.State {
+	case "created", "exited", "dead":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"testing"
+
+	moby "github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/mock"
+	"gotest.tools/v3/assert"
+
+	"github.com/docker/compose-cli/progress"
+)
+
+func TestPrune(t *testing.T) {
+	labels := map[string]string{projectLabel: "myproject", serviceLabel: "web"}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{
+		{ID: "c1", Names: []string{"/myproject_web_1"}, State: "running", Labels: labels},
+		{ID: "c2", Names: []string{"/myproject_web_2"}, State: "exited", Labels: labels},
+		{ID: "c3", Names: []string{"/myproject_web_3"}, State: "paused", Labels: labels},
+	}, nil)
+	apiClient.On("ContainerRemove", mock.Anything, "c2", moby.ContainerRemoveOptions{}).Return(nil).Once()
+	apiClient.On("NetworkList", mock.Anything, mock.Anything).Return([]moby.NetworkResource{
+		{ID: "n1", Name: "myproject_front", Labels: map[string]string{projectLabel: "myproject"}},
+		{ID: "n2", Name: "myproject_back", Labels: map[string]string{projectLabel: "myproject"}},
+	}, nil)
+	apiClient.On("NetworkInspect", mock.Anything, "n1", moby.NetworkInspectOptions{}).Return(moby.NetworkResource{
+		Containers: map[string]moby.EndpointResource{"c1": {Name: "myproject_web_1"}},
+	}, nil)
+	apiClient.On("NetworkInspect", mock.Anything, "n2", moby.NetworkInspectOptions{}).Return(moby.NetworkResource{}, nil)
+	apiClient.On("NetworkRemove", mock.Anything, "n2").Return(nil).Once()
+	s := newMockBackend(apiClient)
+
+	w := &recordingWriter{}
+	err := s.Prune(progress.WithContextWriter(context.TODO(), w), "myproject")
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	apiClient.AssertNumberOfCalls(t, "ContainerRemove", 1)
+	apiClient.AssertNotCalled(t, "NetworkRemove", mock.Anything, "n1")
+	assert.DeepEqual(t, w.statuses(`Network "myproject_back"`), []string{"Remove", "Removed"})
+}
+
+func TestPruneIgnoresOtherProjects(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{
+		{ID: "c1", Names: []string{"/other_web_1"}, State: "exited", Labels: map[string]string{projectLabel: "other"}},
+	}, nil)
+	apiClient.On("NetworkList", mock.Anything, mock.Anything).Return([]moby.NetworkResource{
+		{ID: "n1", Name: "other_default", Labels: map[string]string{projectLabel: "other"}},
+	}, nil)
+	s := newMockBackend(apiClient)
+
+	err := s.Prune(context.TODO(), "myproject")
+	assert.NilError(t, err)
+	apiClient.AssertNotCalled(t, "ContainerRemove", mock.Anything, mock.Anything, mock.Anything)
+	apiClient.AssertNotCalled(t, "NetworkInspect", mock.Anything, mock.Anything, mock.Anything)
+	apiClient.AssertNotCalled(t, "NetworkRemove", mock.Anything, mock.Anything)
+}
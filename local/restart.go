@@ -0,0 +1,101 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+
+	"github.com/compose-spec/compose-go/types"
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/progress"
+)
+
+// Restart stops then starts running containers of the project, and starts stopped ones. Containers are neither
+// created nor recreated, so configuration changes are not applied
+func (s *local) Restart(ctx context.Context, projectName string, options compose.RestartOptions) error {
+	list, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
+		Filters: filters.NewArgs(
+			projectFilter(projectName),
+		),
+		All: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	selected, err := getSelectedServicesFromContainers(list, options.Services)
+	if err != nil {
+		return err
+	}
+
+	// without explicit timeout, engine applies the stop_grace_period set on containers
+	var timeout *uint32
+	if options.Timeout != nil {
+		t := uint32(options.Timeout.Seconds())
+		timeout = &t
+	}
+
+	limit, err := getParallelLimit(0)
+	if err != nil {
+		return err
+	}
+
+	w := progress.ContextWriter(ctx)
+	return visit(ctx, getServicesFromContainers(list), false, func(ctx context.Context, service types.ServiceConfig) error {
+		if !selected[service.Name] {
+			return nil
+		}
+		eg, errCtx := newLimitedGroup(ctx, limit)
+		for _, c := range list {
+			if c.Labels[serviceLabel] != service.Name {
+				continue
+			}
+			container := c
+			eg.Go(func() error {
+				w.Event(progress.Event{
+					ID:     getContainerName(container),
+					Text:   "Restarting",
+					Status: progress.Working,
+					Done:   false,
+				})
+				if container.State == "running" {
+					err := s.containerService.Stop(errCtx, container.ID, timeout)
+					if err != nil {
+						return err
+					}
+				}
+				err := s.containerService.Start(errCtx, container.ID)
+				if err != nil {
+					return err
+				}
+				w.Event(progress.Event{
+					ID:     getContainerName(container),
+					Text:   "Restarted",
+					Status: progress.Done,
+					Done:   true,
+				})
+				return nil
+			})
+		}
+		return eg.Wait()
+	})
+}
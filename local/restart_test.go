@@ -0,0 +1,76 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"testing"
+
+	moby "github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/mock"
+	"gotest.tools/v3/assert"
+
+	"github.com/docker/compose-cli/api/compose"
+)
+
+func TestRestart(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{
+		{
+			ID:     "web1",
+			Names:  []string{"/myproject_web_1"},
+			State:  "running",
+			Labels: map[string]string{serviceLabel: "web", dependenciesLabel: "db"},
+		},
+		{
+			ID:     "db1",
+			Names:  []string{"/myproject_db_1"},
+			State:  "exited",
+			Labels: map[string]string{serviceLabel: "db"},
+		},
+	}, nil)
+	apiClient.On("ContainerStop", mock.Anything, "web1", mock.Anything).Return(nil).Once()
+	apiClient.On("ContainerStart", mock.Anything, "web1", moby.ContainerStartOptions{}).Return(nil).Once()
+	apiClient.On("ContainerStart", mock.Anything, "db1", moby.ContainerStartOptions{}).Return(nil).Once()
+	s := newMockBackend(apiClient)
+
+	err := s.Restart(context.TODO(), "myproject", compose.RestartOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	apiClient.AssertNotCalled(t, "ContainerStop", mock.Anything, "db1", mock.Anything)
+	apiClient.AssertNotCalled(t, "ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRestartSelectedServices(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{
+		{ID: "web1", Names: []string{"/myproject_web_1"}, State: "running", Labels: map[string]string{serviceLabel: "web"}},
+		{ID: "db1", Names: []string{"/myproject_db_1"}, State: "running", Labels: map[string]string{serviceLabel: "db"}},
+	}, nil)
+	apiClient.On("ContainerStop", mock.Anything, "db1", mock.Anything).Return(nil).Once()
+	apiClient.On("ContainerStart", mock.Anything, "db1", moby.ContainerStartOptions{}).Return(nil).Once()
+	s := newMockBackend(apiClient)
+
+	err := s.Restart(context.TODO(), "myproject", compose.RestartOptions{Services: []string{"db"}})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+
+	err = s.Restart(context.TODO(), "myproject", compose.RestartOptions{Services: []string{"cache"}})
+	assert.Error(t, err, `no container found for service "cache"`)
+}
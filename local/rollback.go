@@ -0,0 +1,175 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"sync"
+
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/progress"
+)
+
+// createdContainers tracks containers created during an up invocation, so they can be removed on rollback, and the
+// containers replaced by recreated ones. As containers get created by concurrent goroutines, access is guarded by a mutex.
+type createdContainers struct {
+	mtx      sync.Mutex
+	ids      []string
+	names    map[string]string
+	replaced map[string]moby.Container
+}
+
+func (c *createdContainers) add(id string, name string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.ids = append(c.ids, id)
+	if c.names == nil {
+		c.names = map[string]string{}
+	}
+	c.names[id] = name
+}
+
+func (c *createdContainers) list() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return append([]string{}, c.ids...)
+}
+
+// replace records the container recreated as name replaced container, which is kept until up completes when rolling
+// back all changes
+func (c *createdContainers) replace(name string, container moby.Container) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.replaced == nil {
+		c.replaced = map[string]moby.Container{}
+	}
+	c.replaced[name] = container
+}
+
+// replacedBy returns the name of the created container with id, and the container it replaced if any
+func (c *createdContainers) replacedBy(id string) (string, moby.Container, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	name := c.names[id]
+	replaced, ok := c.replaced[name]
+	return name, replaced, ok
+}
+
+func (c *createdContainers) listReplaced() map[string]string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	replaced := map[string]string{}
+	for name, container := range c.replaced {
+		replaced[name] = container.ID
+	}
+	return replaced
+}
+
+type createdContainersKey struct{}
+
+func withCreatedContainers(ctx context.Context, created *createdContainers) context.Context {
+	return context.WithValue(ctx, createdContainersKey{}, created)
+}
+
+// trackCreatedContainer records a container created by the current up invocation, if tracking is enabled
+func trackCreatedContainer(ctx context.Context, id string, name string) {
+	if created, ok := ctx.Value(createdContainersKey{}).(*createdContainers); ok {
+		created.add(id, name)
+	}
+}
+
+// trackReplacedContainer records a container replaced by a recreated one, if tracking is enabled, and tells if it must
+// be kept so it can be restored on rollback
+func trackReplacedContainer(ctx context.Context, name string, container moby.Container, options compose.UpOptions) bool {
+	created, ok := ctx.Value(createdContainersKey{}).(*createdContainers)
+	if !ok {
+		return false
+	}
+	created.replace(name, container)
+	return keepReplacedContainers(options)
+}
+
+func keepReplacedContainers(options compose.UpOptions) bool {
+	return options.Rollback && options.RollbackScope == compose.RollbackAll
+}
+
+// rollback removes containers created by an up invocation. Recreated containers are kept as services were running
+// before, unless scope is RollbackAll, which restores the containers they replaced
+func (s *local) rollback(ctx context.Context, created *createdContainers, scope string) error {
+	w := progress.ContextWriter(ctx)
+	// up context has been cancelled, we still need to remove containers
+	ctx = context.Background()
+	for _, id := range created.list() {
+		name, replaced, recreated := created.replacedBy(id)
+		if recreated && scope != compose.RollbackAll {
+			continue
+		}
+		w.Event(progress.Event{
+			ID:         id,
+			Status:     progress.Working,
+			StatusText: "Rollback",
+			Done:       false,
+		})
+		var err error
+		if recreated {
+			err = s.restoreContainer(replaced, name)
+		} else {
+			err = s.containerService.apiClient.ContainerRemove(ctx, id, moby.ContainerRemoveOptions{Force: true})
+		}
+		if err != nil && !errdefs.IsNotFound(err) {
+			w.Event(progress.Event{
+				ID:         id,
+				Status:     progress.Error,
+				StatusText: "Error",
+				Done:       true,
+			})
+			return err
+		}
+		status := "Removed"
+		if recreated {
+			status = "Restored"
+		}
+		w.Event(progress.Event{
+			ID:         id,
+			Status:     progress.Done,
+			StatusText: status,
+			Done:       true,
+		})
+	}
+	return nil
+}
+
+// removeReplacedContainers removes the containers kept to be restored on rollback, once up succeeded
+func (s *local) removeReplacedContainers(ctx context.Context, created *createdContainers, options compose.UpOptions) error {
+	if !keepReplacedContainers(options) {
+		return nil
+	}
+	for _, id := range created.listReplaced() {
+		err := s.containerService.apiClient.ContainerRemove(ctx, id, moby.ContainerRemoveOptions{
+			RemoveVolumes: options.RenewAnonVolumes,
+		})
+		if err != nil && !errdefs.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
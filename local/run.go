@@ -0,0 +1,97 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/compose-spec/compose-go/types"
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stringid"
+
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/api/containers"
+)
+
+// RunOneOffContainer runs a container for a service, labelled as oneoff so that convergence doesn't consider it as
+// one of the service replicas. Dependencies of the service are expected to be running already
+func (s *local) RunOneOffContainer(ctx context.Context, project *types.Project, options compose.RunOptions) (string, error) {
+	service, err := project.GetService(options.Service)
+	if err != nil {
+		return "", err
+	}
+	setResourceNames(project)
+
+	containerConfig, hostConfig, networkingConfig, err := getContainerCreateOptions(project, service, 1, nil)
+	if err != nil {
+		return "", err
+	}
+	containerConfig.Labels[oneoffLabel] = "True"
+	delete(containerConfig.Labels, containerNumberLabel)
+	delete(containerConfig.Labels, replicaLabel)
+	if len(options.Command) > 0 {
+		containerConfig.Cmd = options.Command
+	}
+	hostConfig.AutoRemove = options.AutoRemove
+
+	name := getOneOffContainerName(project.Name, service.Name)
+	id, err := s.createServiceContainer(ctx, project, service, name, containerConfig, hostConfig, networkingConfig)
+	if err != nil {
+		return "", err
+	}
+
+	if options.Detach {
+		err = s.containerService.apiClient.ContainerStart(ctx, id, moby.ContainerStartOptions{})
+		return name, classifyEngineError(err)
+	}
+
+	// wait registers before container is started, so a short-lived container can't exit unnoticed
+	condition := container.WaitConditionNextExit
+	if options.AutoRemove {
+		condition = container.WaitConditionRemoved
+	}
+	statusC, errC := s.containerService.apiClient.ContainerWait(ctx, id, condition)
+	err = s.containerService.apiClient.ContainerStart(ctx, id, moby.ContainerStartOptions{})
+	if err != nil {
+		return name, classifyEngineError(err)
+	}
+	err = s.containerService.Logs(ctx, id, containers.LogsRequest{
+		Follow: true,
+		Writer: options.Writer,
+	})
+	if err != nil {
+		return name, err
+	}
+	select {
+	case status := <-statusC:
+		if status.StatusCode != 0 {
+			return name, fmt.Errorf("container %s exited with code %d", name, status.StatusCode)
+		}
+		return name, nil
+	case err := <-errC:
+		return name, err
+	}
+}
+
+// getOneOffContainerName returns a unique name for a oneoff container, which doesn't collide with replicas names
+func getOneOffContainerName(projectName string, service string) string {
+	return fmt.Sprintf("%s_%s_run_%s", projectName, service, stringid.TruncateID(stringid.GenerateRandomID()))
+}
@@ -0,0 +1,93 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/types"
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/mock"
+	"gotest.tools/v3/assert"
+
+	"github.com/docker/compose-cli/api/compose"
+)
+
+func TestOneOffContainersIgnoredByConvergence(t *testing.T) {
+	service := types.ServiceConfig{Name: "web", Image: "nginx"}
+	hash, err := jsonHash(service)
+	assert.NilError(t, err)
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(moby.ImageInspect{}, nil)
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{
+		{
+			ID:     "c1",
+			Image:  "nginx",
+			State:  "running",
+			Labels: map[string]string{containerNumberLabel: "1", configHashLabel: hash, oneoffLabel: "False"},
+		},
+		{
+			ID:     "run1",
+			Names:  []string{"/myproject_web_run_0123456789ab"},
+			Image:  "nginx",
+			State:  "running",
+			Labels: map[string]string{configHashLabel: "outdated", oneoffLabel: "True"},
+		},
+	}, nil)
+	s := newMockBackend(apiClient)
+
+	// oneoff container is neither removed to match scale, nor recreated as diverged
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{service}}
+	err = s.ensureService(context.TODO(), project, service, compose.UpOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertNotCalled(t, "ContainerStop", mock.Anything, mock.Anything, mock.Anything)
+	apiClient.AssertNotCalled(t, "ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRunOneOffContainer(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerCreate", mock.Anything, mock.MatchedBy(func(config *container.Config) bool {
+		_, numbered := config.Labels[containerNumberLabel]
+		return config.Labels[oneoffLabel] == "True" && !numbered && config.Cmd[0] == "migrate"
+	}), mock.MatchedBy(func(hostConfig *container.HostConfig) bool {
+		return hostConfig.AutoRemove
+	}), mock.Anything, mock.MatchedBy(func(name string) bool {
+		return strings.HasPrefix(name, "myproject_web_run_")
+	})).Return(container.ContainerCreateCreatedBody{ID: "run1"}, nil)
+	apiClient.On("ContainerStart", mock.Anything, "run1", moby.ContainerStartOptions{}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	project := &types.Project{Name: "myproject", Services: []types.ServiceConfig{{Name: "web", Image: "myapp"}}}
+	name, err := s.RunOneOffContainer(context.TODO(), project, compose.RunOptions{
+		Service:    "web",
+		Command:    []string{"migrate"},
+		Detach:     true,
+		AutoRemove: true,
+	})
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasPrefix(name, "myproject_web_run_"))
+	apiClient.AssertExpectations(t)
+
+	_, err = s.RunOneOffContainer(context.TODO(), project, compose.RunOptions{Service: "db"})
+	assert.ErrorContains(t, err, "db")
+}
@@ -0,0 +1,229 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/compose-spec/compose-go/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+const (
+	secretsDir        = "/run/secrets"
+	defaultSecretMode = 0444
+)
+
+// buildContainerSecretMounts materializes secrets and configs used by service as files on the host, as the local
+// engine has no secret store, and returns the read-only bind mounts to expose them inside service containers
+func buildContainerSecretMounts(p *types.Project, s types.ServiceConfig) ([]mount.Mount, error) {
+	var mounts []mount.Mount
+	for _, secret := range s.Secrets {
+		definition, ok := p.Secrets[secret.Source]
+		if !ok {
+			return nil, fmt.Errorf("service %q refers to undefined secret %q", s.Name, secret.Source)
+		}
+		if definition.External.External {
+			return nil, fmt.Errorf("unsupported external secret %q: only file based secrets can be used by local backend", secret.Source)
+		}
+		target := secret.Target
+		if target == "" {
+			target = secret.Source
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(secretsDir, target)
+		}
+		m, err := materializeFileReference(p, s, "secrets", types.FileReferenceConfig(secret), types.FileObjectConfig(definition), target)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, m)
+	}
+
+	for _, config := range s.Configs {
+		definition, ok := p.Configs[config.Source]
+		if !ok {
+			return nil, fmt.Errorf("service %q refers to undefined config %q", s.Name, config.Source)
+		}
+		if definition.External.External {
+			return nil, fmt.Errorf("unsupported external config %q: only file based configs can be used by local backend", config.Source)
+		}
+		target := config.Target
+		if target == "" {
+			target = path.Join("/", config.Source)
+		}
+		m, err := materializeFileReference(p, s, "configs", types.FileReferenceConfig(config), types.FileObjectConfig(definition), target)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, nil
+}
+
+// materializeFileReference copies the file a secret or config is defined by into a location private to service,
+// so ownership and mode declared by service can be applied without altering the original file
+func materializeFileReference(p *types.Project, s types.ServiceConfig, kind string, ref types.FileReferenceConfig, definition types.FileObjectConfig, target string) (mount.Mount, error) {
+	if definition.File == "" {
+		return mount.Mount{}, fmt.Errorf("%s %q has no file defined, which is required by local backend", kind, ref.Source)
+	}
+	content, err := ioutil.ReadFile(getFileObjectPath(p, definition))
+	if err != nil {
+		return mount.Mount{}, errors.Wrapf(err, "failed to read %s %q", kind, ref.Source)
+	}
+
+	dir, err := ensureFileReferencesDir(p.Name)
+	if err != nil {
+		return mount.Mount{}, err
+	}
+	// the same secret or config can be mounted to distinct targets with distinct modes, so copies are set by target
+	dir = filepath.Join(dir, s.Name, kind)
+	source := filepath.Join(dir, filepath.FromSlash(target))
+	if !strings.HasPrefix(source, dir+string(filepath.Separator)) {
+		return mount.Mount{}, fmt.Errorf("invalid target %q for %s %q: must be a file path", target, kind, ref.Source)
+	}
+	err = os.MkdirAll(filepath.Dir(source), 0700)
+	if err != nil {
+		return mount.Mount{}, err
+	}
+	// previous copy might be read-only
+	err = os.Remove(source)
+	if err != nil && !os.IsNotExist(err) {
+		return mount.Mount{}, err
+	}
+	err = ioutil.WriteFile(source, content, 0600)
+	if err != nil {
+		return mount.Mount{}, err
+	}
+
+	uid, err := toOwnerID(ref.UID)
+	if err != nil {
+		return mount.Mount{}, errors.Wrapf(err, "invalid uid for %s %q", kind, ref.Source)
+	}
+	gid, err := toOwnerID(ref.GID)
+	if err != nil {
+		return mount.Mount{}, errors.Wrapf(err, "invalid gid for %s %q", kind, ref.Source)
+	}
+	if uid != -1 || gid != -1 {
+		err = os.Chown(source, uid, gid)
+		if err != nil {
+			return mount.Mount{}, errors.Wrapf(err, "failed to set ownership of %s %q", kind, ref.Source)
+		}
+	}
+
+	mode := os.FileMode(defaultSecretMode)
+	if ref.Mode != nil {
+		mode = os.FileMode(*ref.Mode)
+	}
+	err = os.Chmod(source, mode)
+	if err != nil {
+		return mount.Mount{}, err
+	}
+
+	return mount.Mount{
+		Type:     mount.TypeBind,
+		Source:   source,
+		Target:   target,
+		ReadOnly: true,
+	}, nil
+}
+
+// getFileReferencesDir returns the directory secrets and configs of project services are materialized in, within a
+// directory private to current user as those are copied in plain text
+func getFileReferencesDir(projectName string) string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("compose-%d", os.Getuid()), projectName)
+}
+
+// ensureFileReferencesDir creates the directory secrets and configs of project services are materialized in, checking
+// the user directory it belongs to wasn't created by someone else
+func ensureFileReferencesDir(projectName string) (string, error) {
+	dir := getFileReferencesDir(projectName)
+	userDir := filepath.Dir(dir)
+	err := os.Mkdir(userDir, 0700)
+	if err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	if err := checkPrivateDir(userDir); err != nil {
+		return "", errors.Wrap(err, "can't materialize secrets and configs")
+	}
+	return dir, nil
+}
+
+// removeFileReferences removes secrets and configs materialized for project services
+func removeFileReferences(projectName string) error {
+	return os.RemoveAll(getFileReferencesDir(projectName))
+}
+
+func getFileObjectPath(p *types.Project, definition types.FileObjectConfig) string {
+	if filepath.IsAbs(definition.File) {
+		return definition.File
+	}
+	return filepath.Join(p.WorkingDir, definition.File)
+}
+
+// getFileReferencesHash hashes the content of the files defining secrets and configs used by service, so containers
+// can be recreated when those files change although service configuration doesn't. Empty when service uses none
+func getFileReferencesHash(p *types.Project, s types.ServiceConfig) (string, error) {
+	contents := map[string]string{}
+	for _, secret := range s.Secrets {
+		err := hashFileObject(p, "secrets", secret.Source, types.FileObjectConfig(p.Secrets[secret.Source]), contents)
+		if err != nil {
+			return "", err
+		}
+	}
+	for _, config := range s.Configs {
+		err := hashFileObject(p, "configs", config.Source, types.FileObjectConfig(p.Configs[config.Source]), contents)
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(contents) == 0 {
+		return "", nil
+	}
+	return jsonHash(contents)
+}
+
+func hashFileObject(p *types.Project, kind string, name string, definition types.FileObjectConfig, contents map[string]string) error {
+	if definition.File == "" {
+		// undefined or external, which is reported when mounting it
+		return nil
+	}
+	content, err := ioutil.ReadFile(getFileObjectPath(p, definition))
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s %q", kind, name)
+	}
+	contents[kind+"/"+name] = digest.SHA256.FromBytes(content).String()
+	return nil
+}
+
+// toOwnerID parses a uid or gid, returning -1 when unset so ownership is left unchanged
+func toOwnerID(id string) (int, error) {
+	if id == "" {
+		return -1, nil
+	}
+	return strconv.Atoi(id)
+}
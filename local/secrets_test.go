@@ -0,0 +1,142 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/compose-spec/compose-go/types"
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/mock"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/docker/compose-cli/api/compose"
+)
+
+func TestFileSecretMount(t *testing.T) {
+	dir := fs.NewDir(t, "secrets", fs.WithFile("password.txt", "s3cr3t"))
+	defer dir.Remove()
+
+	mode := uint32(0400)
+	service := types.ServiceConfig{
+		Name: "db",
+		Secrets: []types.ServiceSecretConfig{
+			{Source: "password", Mode: &mode},
+			{Source: "password", Target: "/etc/db/password"},
+		},
+	}
+	project := &types.Project{
+		Name:       "myproject",
+		WorkingDir: dir.Path(),
+		Services:   []types.ServiceConfig{service},
+		Secrets: map[string]types.SecretConfig{
+			"password": {File: "password.txt"},
+		},
+	}
+	defer removeFileReferences("myproject") //nolint:errcheck
+
+	mounts, err := buildContainerSecretMounts(project, service)
+	assert.NilError(t, err)
+	assert.Equal(t, len(mounts), 2)
+	assert.Equal(t, mounts[0].Type, mount.TypeBind)
+	assert.Equal(t, mounts[0].Target, "/run/secrets/password")
+	assert.Assert(t, mounts[0].ReadOnly)
+	assert.Equal(t, mounts[1].Target, "/etc/db/password")
+
+	info, err := os.Stat(mounts[0].Source)
+	assert.NilError(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0400))
+	info, err = os.Stat(mounts[1].Source)
+	assert.NilError(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0444))
+	content, err := ioutil.ReadFile(mounts[0].Source)
+	assert.NilError(t, err)
+	assert.Equal(t, string(content), "s3cr3t")
+}
+
+func TestSecretTargetMustStayInSecretsDir(t *testing.T) {
+	dir := fs.NewDir(t, "configs", fs.WithFile("app.conf", "debug=1"))
+	defer dir.Remove()
+	defer removeFileReferences("myproject") //nolint:errcheck
+
+	service := types.ServiceConfig{
+		Name:    "web",
+		Configs: []types.ServiceConfigObjConfig{{Source: "app", Target: "/../../../../etc/app.conf"}},
+	}
+	project := &types.Project{
+		Name:       "myproject",
+		WorkingDir: dir.Path(),
+		Services:   []types.ServiceConfig{service},
+		Configs:    map[string]types.ConfigObjConfig{"app": {File: "app.conf"}},
+	}
+	_, err := buildContainerSecretMounts(project, service)
+	assert.Error(t, err, `invalid target "/../../../../etc/app.conf" for configs "app": must be a file path`)
+}
+
+func TestDownRemovesMaterializedSecrets(t *testing.T) {
+	dir := fs.NewDir(t, "secrets", fs.WithFile("password.txt", "s3cr3t"))
+	defer dir.Remove()
+
+	service := types.ServiceConfig{
+		Name:    "db",
+		Secrets: []types.ServiceSecretConfig{{Source: "password"}},
+	}
+	project := &types.Project{
+		Name:       "myproject",
+		WorkingDir: dir.Path(),
+		Services:   []types.ServiceConfig{service},
+		Secrets:    map[string]types.SecretConfig{"password": {File: "password.txt"}},
+	}
+	mounts, err := buildContainerSecretMounts(project, service)
+	assert.NilError(t, err)
+	info, err := os.Stat(filepath.Dir(getFileReferencesDir("myproject")))
+	assert.NilError(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0700))
+
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{}, nil)
+	apiClient.On("NetworkList", mock.Anything, mock.Anything).Return([]moby.NetworkResource{}, nil)
+	s := newMockBackend(apiClient)
+	err = s.Down(context.TODO(), "myproject", compose.DownOptions{})
+	assert.NilError(t, err)
+	_, err = os.Stat(mounts[0].Source)
+	assert.Assert(t, os.IsNotExist(err))
+}
+
+func TestExternalSecretUnsupported(t *testing.T) {
+	service := types.ServiceConfig{
+		Name:    "db",
+		Secrets: []types.ServiceSecretConfig{{Source: "password"}},
+	}
+	project := &types.Project{
+		Name:     "myproject",
+		Services: []types.ServiceConfig{service},
+		Secrets: map[string]types.SecretConfig{
+			"password": {External: types.External{External: true}},
+		},
+	}
+	_, err := buildContainerSecretMounts(project, service)
+	assert.ErrorContains(t, err, `unsupported external secret "password"`)
+}
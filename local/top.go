@@ -0,0 +1,87 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"sort"
+
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/docker/compose-cli/api/compose"
+)
+
+// Top lists processes of the running containers of the project
+func (s *local) Top(ctx context.Context, projectName string, options compose.TopOptions) ([]compose.ContainerProcSummary, error) {
+	list, err := s.containerService.apiClient.ContainerList(ctx, moby.ContainerListOptions{
+		Filters: filters.NewArgs(
+			projectFilter(projectName),
+		),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	selected, err := getSelectedServicesFromContainers(list, options.Services)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := getParallelLimit(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []compose.ContainerProcSummary
+	for _, c := range list {
+		if selected[c.Labels[serviceLabel]] && c.State == "running" {
+			summaries = append(summaries, compose.ContainerProcSummary{
+				ID:      c.ID,
+				Name:    getContainerName(c),
+				Service: c.Labels[serviceLabel],
+			})
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Service != summaries[j].Service {
+			return summaries[i].Service < summaries[j].Service
+		}
+		return summaries[i].Name < summaries[j].Name
+	})
+
+	eg, ctx := newLimitedGroup(ctx, limit)
+	for i := range summaries {
+		summary := &summaries[i]
+		eg.Go(func() error {
+			top, err := s.containerService.apiClient.ContainerTop(ctx, summary.ID, []string{})
+			if err != nil {
+				return classifyEngineError(err)
+			}
+			summary.Titles = top.Titles
+			summary.Processes = top.Processes
+			return nil
+		})
+	}
+	err = eg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
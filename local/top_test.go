@@ -0,0 +1,65 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/mock"
+	"gotest.tools/v3/assert"
+
+	"github.com/docker/compose-cli/api/compose"
+)
+
+func TestTop(t *testing.T) {
+	top := func(cmd string) container.ContainerTopOKBody {
+		return container.ContainerTopOKBody{
+			Titles:    []string{"PID", "CMD"},
+			Processes: [][]string{{"42", cmd}},
+		}
+	}
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return(projectContainers(), nil)
+	apiClient.On("ContainerTop", mock.Anything, "web1", []string{}).Return(top("nginx"), nil).Once()
+	apiClient.On("ContainerTop", mock.Anything, "db1", []string{}).Return(top("postgres"), nil).Once()
+	s := newMockBackend(apiClient)
+
+	summaries, err := s.Top(context.TODO(), "myproject", compose.TopOptions{})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	assert.DeepEqual(t, summaries, []compose.ContainerProcSummary{
+		{ID: "db1", Name: "myproject_db_1", Service: "db", Titles: []string{"PID", "CMD"}, Processes: [][]string{{"42", "postgres"}}},
+		{ID: "web1", Name: "myproject_web_1", Service: "web", Titles: []string{"PID", "CMD"}, Processes: [][]string{{"42", "nginx"}}},
+	})
+}
+
+func TestTopSelectedServices(t *testing.T) {
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return(projectContainers(), nil)
+	apiClient.On("ContainerTop", mock.Anything, "db1", []string{}).Return(container.ContainerTopOKBody{}, nil).Once()
+	s := newMockBackend(apiClient)
+
+	summaries, err := s.Top(context.TODO(), "myproject", compose.TopOptions{Services: []string{"db"}})
+	assert.NilError(t, err)
+	apiClient.AssertExpectations(t)
+	assert.Equal(t, len(summaries), 1)
+	assert.Equal(t, summaries[0].Service, "db")
+}
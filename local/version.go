@@ -0,0 +1,42 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/versions"
+)
+
+// minimumAPIVersion is the oldest engine API version convergence relies on: container healthcheck, init and
+// stop timeout were introduced by API 1.25 (Docker Engine 1.13)
+const minimumAPIVersion = "1.25"
+
+// checkAPIVersion fails early if the engine is too old for convergence, rather than on some unsupported API call
+func (s *local) checkAPIVersion(ctx context.Context) error {
+	version, err := s.containerService.apiClient.ServerVersion(ctx)
+	if err != nil {
+		return classifyEngineError(err)
+	}
+	if versions.LessThan(version.APIVersion, minimumAPIVersion) {
+		return fmt.Errorf("engine API version %s is too old: API version %s or later (Docker Engine 1.13+) is required, please upgrade Docker Engine", version.APIVersion, minimumAPIVersion)
+	}
+	return nil
+}
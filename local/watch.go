@@ -0,0 +1,225 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/compose-spec/compose-go/types"
+	"github.com/pkg/errors"
+
+	"github.com/docker/compose-cli/api/compose"
+	"github.com/docker/compose-cli/progress"
+)
+
+const (
+	// extDevelop is the x-develop service extension, declaring paths to watch for changes:
+	//
+	//	x-develop:
+	//	  watch:
+	//	    - path: ./src
+	extDevelop = "x-develop"
+
+	watchPollInterval = 500 * time.Millisecond
+	// watchDebounce is the delay without any change after which changed services are recreated, so that a burst of
+	// changes, e.g. on checkout, triggers a single recreate
+	watchDebounce = time.Second
+)
+
+type develop struct {
+	Watch []watchRule `json:"watch,omitempty"`
+}
+
+type watchRule struct {
+	Path string `json:"path"`
+}
+
+// Watch polls paths declared by services x-develop extension, and rebuilds and recreates services which paths changed
+func (s *local) Watch(ctx context.Context, project *types.Project, options compose.WatchOptions) error {
+	watched, err := getWatchedPaths(project, options.Services)
+	if err != nil {
+		return err
+	}
+	if len(watched) == 0 {
+		return fmt.Errorf("no service declares paths to watch, set %s.watch", extDevelop)
+	}
+	setResourceNames(project)
+	changes := make(chan string)
+	go pollChanges(ctx, watched, watchPollInterval, changes)
+	return s.recreateOnChange(ctx, project, changes, watchDebounce)
+}
+
+// getWatchedPaths returns the absolute paths to watch, by name of selected services
+func getWatchedPaths(project *types.Project, services []string) (map[string][]string, error) {
+	watched := map[string][]string{}
+	for _, service := range project.Services {
+		if len(services) > 0 && !contains(services, service.Name) {
+			continue
+		}
+		config, ok := service.Extensions[extDevelop]
+		if !ok {
+			continue
+		}
+		var d develop
+		marshalled, err := json.Marshal(config)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(marshalled, &d)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s for service %q", extDevelop, service.Name)
+		}
+		for _, rule := range d.Watch {
+			if rule.Path == "" {
+				return nil, fmt.Errorf("invalid %s for service %q: watch rule without a path", extDevelop, service.Name)
+			}
+			path := rule.Path
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(project.WorkingDir, path)
+			}
+			watched[service.Name] = append(watched[service.Name], path)
+		}
+	}
+	return watched, nil
+}
+
+// pollChanges sends the name of services to changes when files under their watched paths get created, modified or
+// removed, until context is cancelled
+func pollChanges(ctx context.Context, watched map[string][]string, interval time.Duration, changes chan<- string) {
+	snapshots := map[string]map[string]time.Time{}
+	for service, paths := range watched {
+		snapshots[service] = snapshotFiles(paths)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for service, paths := range watched {
+			snapshot := snapshotFiles(paths)
+			if equalSnapshots(snapshot, snapshots[service]) {
+				continue
+			}
+			snapshots[service] = snapshot
+			select {
+			case changes <- service:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// snapshotFiles returns modification times of the files under paths. Paths which can't be read are ignored, so that
+// watching a path which doesn't exist yet catches its creation
+func snapshotFiles(paths []string) map[string]time.Time {
+	snapshot := map[string]time.Time{}
+	for _, root := range paths {
+		_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			snapshot[path] = info.ModTime()
+			return nil
+		})
+	}
+	return snapshot
+}
+
+func equalSnapshots(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, modTime := range a {
+		if other, ok := b[path]; !ok || !other.Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// recreateOnChange recreates services received from changes, once no change was received for debounce duration
+func (s *local) recreateOnChange(ctx context.Context, project *types.Project, changes <-chan string, debounce time.Duration) error {
+	w := progress.ContextWriter(ctx)
+	pending := map[string]bool{}
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case service := <-changes:
+			if !pending[service] {
+				w.Event(progress.Event{
+					ID:         fmt.Sprintf("Service %q", service),
+					Status:     progress.Working,
+					StatusText: "Changed",
+				})
+			}
+			pending[service] = true
+			timer = time.After(debounce)
+		case <-timer:
+			for _, service := range project.Services {
+				if !pending[service.Name] {
+					continue
+				}
+				// a failure is reported but doesn't stop watching, as next change may fix it
+				err := s.rebuildAndRecreate(ctx, project, service)
+				if err != nil {
+					w.Event(progress.Event{
+						ID:         fmt.Sprintf("Service %q", service.Name),
+						Status:     progress.Error,
+						StatusText: err.Error(),
+						Done:       true,
+					})
+				}
+			}
+			pending = map[string]bool{}
+			timer = nil
+		}
+	}
+}
+
+// rebuildAndRecreate rebuilds service image if it has a build section, then recreates its containers
+func (s *local) rebuildAndRecreate(ctx context.Context, project *types.Project, service types.ServiceConfig) error {
+	if service.Build != nil {
+		err := s.buildImage(ctx, project, service, getImageName(project, service))
+		if err != nil {
+			return err
+		}
+	}
+	containers, err := s.getServiceContainers(ctx, project, service)
+	if err != nil {
+		return err
+	}
+	for _, container := range containers {
+		err := s.recreateContainer(ctx, project, service, container, compose.UpOptions{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// +build local
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package local
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/compose-spec/compose-go/types"
+	moby "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/mock"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/docker/compose-cli/progress"
+)
+
+func TestWatchedPaths(t *testing.T) {
+	project := &types.Project{
+		WorkingDir: "/project",
+		Services: []types.ServiceConfig{
+			{
+				Name: "web",
+				Extensions: map[string]interface{}{extDevelop: map[string]interface{}{
+					"watch": []interface{}{map[string]interface{}{"path": "./src"}, map[string]interface{}{"path": "/shared"}},
+				}},
+			},
+			{Name: "db"},
+		},
+	}
+	watched, err := getWatchedPaths(project, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, watched, map[string][]string{"web": {"/project/src", "/shared"}})
+
+	dir := fs.NewDir(t, "watch", fs.WithFile("main.go", "package main\n"))
+	defer dir.Remove()
+	before := snapshotFiles([]string{dir.Path()})
+	assert.Assert(t, equalSnapshots(before, snapshotFiles([]string{dir.Path()})))
+	fs.Apply(t, dir, fs.WithFile("util.go", "package main\n"))
+	assert.Assert(t, !equalSnapshots(before, snapshotFiles([]string{dir.Path()})))
+}
+
+func TestWatchRecreatesChangedService(t *testing.T) {
+	project := &types.Project{
+		Name: "myproject",
+		Services: []types.ServiceConfig{
+			{Name: "web", Image: "nginx"},
+			{Name: "db", Image: "postgres"},
+		},
+	}
+
+	recreated := make(chan string, 10)
+	apiClient := &mockAPIClient{}
+	apiClient.On("ContainerList", mock.Anything, mock.Anything).Return([]moby.Container{{
+		ID:     "123456789012345",
+		Names:  []string{"/myproject_web_1"},
+		Image:  "nginx",
+		Labels: map[string]string{serviceLabel: "web", containerNumberLabel: "1"},
+	}}, nil)
+	apiClient.On("ContainerStop", mock.Anything, "123456789012345", mock.Anything).Return(nil)
+	apiClient.On("ContainerRename", mock.Anything, "123456789012345", "123456789012_myproject_web_1").Return(nil)
+	apiClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "myproject_web_1").
+		Run(func(args mock.Arguments) {
+			recreated <- args.String(4)
+		}).Return(container.ContainerCreateCreatedBody{ID: "abc"}, nil)
+	apiClient.On("ContainerStart", mock.Anything, "abc", moby.ContainerStartOptions{}).Return(nil)
+	apiClient.On("ContainerRemove", mock.Anything, "123456789012345", moby.ContainerRemoveOptions{}).Return(nil)
+	s := newMockBackend(apiClient)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	changes := make(chan string)
+	w := &recordingWriter{}
+	done := make(chan error)
+	go func() {
+		done <- s.recreateOnChange(progress.WithContextWriter(ctx, w), project, changes, 50*time.Millisecond)
+	}()
+
+	// a burst of changes only triggers one recreate
+	changes <- "web"
+	changes <- "web"
+	assert.Equal(t, <-recreated, "myproject_web_1")
+	cancel()
+	assert.NilError(t, <-done)
+	assert.Equal(t, len(recreated), 0)
+	apiClient.AssertNumberOfCalls(t, "ContainerCreate", 1)
+	assert.DeepEqual(t, w.statuses(`Service "web"`), []string{"Changed", "Recreate", "Recreated"})
+	assert.Equal(t, len(w.statuses(`Service "db"`)), 0)
+}
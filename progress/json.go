@@ -0,0 +1,74 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+type jsonWriter struct {
+	out  io.Writer
+	done chan bool
+	mtx  *sync.Mutex
+}
+
+// jsonEvent is the serialized form of an Event, written as newline-delimited JSON
+type jsonEvent struct {
+	ID         string    `json:"id"`
+	Text       string    `json:"text,omitempty"`
+	Status     string    `json:"status"`
+	StatusText string    `json:"status_text,omitempty"`
+	Done       bool      `json:"done"`
+	Time       time.Time `json:"time"`
+}
+
+var statusNames = map[EventStatus]string{
+	Working: "working",
+	Done:    "done",
+	Error:   "error",
+	Warning: "warning",
+}
+
+func (p *jsonWriter) Start(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.done:
+		return nil
+	}
+}
+
+func (p *jsonWriter) Event(e Event) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	_ = json.NewEncoder(p.out).Encode(jsonEvent{
+		ID:         e.ID,
+		Text:       e.Text,
+		Status:     statusNames[e.Status],
+		StatusText: e.StatusText,
+		Done:       e.Done,
+		Time:       time.Now(),
+	})
+}
+
+func (p *jsonWriter) Stop() {
+	p.done <- true
+}
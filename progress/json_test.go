@@ -0,0 +1,68 @@
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestJSONWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := NewJSONWriter(out)
+	w.Event(Event{
+		ID:         `Service "web"`,
+		Status:     Working,
+		StatusText: "Create",
+	})
+	w.Event(Event{
+		ID:         `Service "web"`,
+		Status:     Done,
+		StatusText: "Created",
+		Done:       true,
+	})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, len(lines), 2)
+
+	var events []map[string]interface{}
+	for _, line := range lines {
+		var event map[string]interface{}
+		assert.NilError(t, json.Unmarshal([]byte(line), &event))
+		assert.Assert(t, event["time"] != "")
+		delete(event, "time")
+		events = append(events, event)
+	}
+	assert.DeepEqual(t, events, []map[string]interface{}{
+		{
+			"id":          `Service "web"`,
+			"status":      "working",
+			"status_text": "Create",
+			"done":        false,
+		},
+		{
+			"id":          `Service "web"`,
+			"status":      "done",
+			"status_text": "Created",
+			"done":        true,
+		},
+	})
+}
@@ -39,6 +39,8 @@ type ttyWriter struct {
 	numLines int
 	done     chan bool
 	mtx      *sync.RWMutex
+	// collapse hides events done for longer than this delay, disabled when 0
+	collapse time.Duration
 }
 
 func (w *ttyWriter) Start(ctx context.Context) error {
@@ -71,7 +73,7 @@ func (w *ttyWriter) Event(e Event) {
 	if _, ok := w.events[e.ID]; ok {
 		last := w.events[e.ID]
 		switch e.Status {
-		case Done, Error:
+		case Done, Error, Warning:
 			if last.Status != e.Status {
 				last.stop()
 			}
@@ -83,6 +85,9 @@ func (w *ttyWriter) Event(e Event) {
 	} else {
 		e.startTime = time.Now()
 		e.spinner = newSpinner()
+		if e.Status != Working {
+			e.stop()
+		}
 		w.events[e.ID] = e
 	}
 }
@@ -108,14 +113,22 @@ func (w *ttyWriter) print() {
 	fmt.Fprint(w.out, aec.Hide)
 	defer fmt.Fprint(w.out, aec.Show)
 
-	firstLine := fmt.Sprintf("[+] Running %d/%d", numDone(w.events), w.numLines)
-	if w.numLines != 0 && numDone(w.events) == w.numLines {
+	visible, collapsed := w.visibleEventIDs()
+	total := w.numLines
+	if w.collapse > 0 {
+		total = len(w.events)
+	}
+	firstLine := fmt.Sprintf("[+] Running %d/%d", numDone(w.events), total)
+	if collapsed > 0 {
+		firstLine += fmt.Sprintf(" (%d completed)", collapsed)
+	}
+	if total != 0 && numDone(w.events) == total {
 		firstLine = aec.Apply(firstLine, aec.BlueF)
 	}
 	fmt.Fprintln(w.out, firstLine)
 
 	var statusPadding int
-	for _, v := range w.eventIDs {
+	for _, v := range visible {
 		l := len(fmt.Sprintf("%s %s", w.events[v].ID, w.events[v].Text))
 		if statusPadding < l {
 			statusPadding = l
@@ -123,16 +136,39 @@ func (w *ttyWriter) print() {
 	}
 
 	numLines := 0
-	for _, v := range w.eventIDs {
+	for _, v := range visible {
 		line := lineText(w.events[v], terminalWidth, statusPadding, runtime.GOOS != "windows")
 		// nolint: errcheck
 		fmt.Fprint(w.out, line)
 		numLines++
 	}
+	// erase lines left over from previous print by collapsed events, so that cursor moves back to the same position
+	for ; numLines < w.numLines; numLines++ {
+		fmt.Fprintln(w.out, aec.EraseLine(aec.EraseModes.All))
+	}
 
 	w.numLines = numLines
 }
 
+// visibleEventIDs returns the events to be printed, and the number of events hidden as done for longer than the
+// collapse delay. Failed events and warnings are never collapsed
+func (w *ttyWriter) visibleEventIDs() ([]string, int) {
+	if w.collapse <= 0 {
+		return w.eventIDs, 0
+	}
+	var visible []string
+	collapsed := 0
+	for _, id := range w.eventIDs {
+		e := w.events[id]
+		if e.Status == Done && time.Since(e.endTime) >= w.collapse {
+			collapsed++
+			continue
+		}
+		visible = append(visible, id)
+	}
+	return visible, collapsed
+}
+
 func lineText(event Event, terminalWidth, statusPadding int, color bool) string {
 	endTime := time.Now()
 	if event.Status != Working {
@@ -171,6 +207,9 @@ func lineText(event Event, terminalWidth, statusPadding int, color bool) string
 		if event.Status == Error {
 			color = aec.RedF
 		}
+		if event.Status == Warning {
+			color = aec.YellowF
+		}
 		return aec.Apply(o, color)
 	}
 
@@ -180,7 +219,7 @@ func lineText(event Event, terminalWidth, statusPadding int, color bool) string
 func numDone(events map[string]Event) int {
 	i := 0
 	for _, e := range events {
-		if e.Status == Done {
+		if e.Status == Done || e.Status == Warning {
 			i++
 		}
 	}
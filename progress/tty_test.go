@@ -54,6 +54,10 @@ func TestLineText(t *testing.T) {
 	ev.Status = Error
 	out = lineText(ev, 50, lineWidth, true)
 	assert.Equal(t, out, "\x1b[31m . id Text Status                            0.0s\n\x1b[0m")
+
+	ev.Status = Warning
+	out = lineText(ev, 50, lineWidth, true)
+	assert.Equal(t, out, "\x1b[33m . id Text Status                            0.0s\n\x1b[0m")
 }
 
 func TestErrorEvent(t *testing.T) {
@@ -84,3 +88,31 @@ func TestErrorEvent(t *testing.T) {
 	assert.Assert(t, ok)
 	assert.Assert(t, event.endTime.After(time.Now().Add(-10*time.Second)))
 }
+
+func TestCollapseDoneEvents(t *testing.T) {
+	w := &ttyWriter{
+		events:   map[string]Event{},
+		mtx:      &sync.RWMutex{},
+		collapse: time.Millisecond,
+	}
+	w.Event(Event{ID: "done", Status: Working})
+	w.Event(Event{ID: "failed", Status: Working})
+	w.Event(Event{ID: "working", Status: Working})
+	w.Event(Event{ID: "done", Status: Done})
+	w.Event(Event{ID: "failed", Status: Error})
+
+	// done event is listed until delay expires
+	visible, collapsed := w.visibleEventIDs()
+	assert.DeepEqual(t, visible, []string{"done", "failed", "working"})
+	assert.Equal(t, collapsed, 0)
+
+	time.Sleep(10 * time.Millisecond)
+	visible, collapsed = w.visibleEventIDs()
+	assert.DeepEqual(t, visible, []string{"failed", "working"})
+	assert.Equal(t, collapsed, 1)
+
+	w.collapse = 0
+	visible, collapsed = w.visibleEventIDs()
+	assert.DeepEqual(t, visible, []string{"done", "failed", "working"})
+	assert.Equal(t, collapsed, 0)
+}
@@ -18,6 +18,7 @@ package progress
 
 import (
 	"context"
+	"io"
 	"os"
 	"sync"
 	"time"
@@ -37,6 +38,8 @@ const (
 	Done
 	// Error means that the current task has errored
 	Error
+	// Warning means that the current task is done, but reported something the user should be aware of
+	Warning
 )
 
 // Event represents a progress event.
@@ -64,6 +67,24 @@ type Writer interface {
 	Event(Event)
 }
 
+const (
+	// ModeAuto renders progress for humans, using terminal capabilities when available
+	ModeAuto = "auto"
+	// ModeJSON writes progress events to stderr as newline-delimited JSON, for machine consumption, so they don't mix
+	// with command output such as attached services logs
+	ModeJSON = "json"
+)
+
+// Mode defines how progress is rendered by Run, either ModeAuto or ModeJSON
+var Mode = ModeAuto
+
+// CollapseDelay, when set, makes the terminal progress output hide events done for longer than the delay, only keeping
+// in-progress and failed ones listed along with the number of completed events
+var CollapseDelay time.Duration
+
+// NoColor makes progress output plain text, without colors nor cursor moves, even when written to a terminal
+var NoColor bool
+
 type writerKey struct{}
 
 // WithContextWriter adds the writer to the context
@@ -86,8 +107,16 @@ type progressFunc func(context.Context) (string, error)
 // in parallel
 func Run(ctx context.Context, pf progressFunc) (string, error) {
 	eg, _ := errgroup.WithContext(ctx)
-	w, err := NewWriter(os.Stderr)
-	var result string
+	var (
+		w      Writer
+		err    error
+		result string
+	)
+	if Mode == ModeJSON {
+		w = NewJSONWriter(os.Stderr)
+	} else {
+		w, err = NewWriter(os.Stderr)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -110,11 +139,12 @@ func Run(ctx context.Context, pf progressFunc) (string, error) {
 	return result, err
 }
 
-// NewWriter returns a new multi-progress writer
+// NewWriter returns a new multi-progress writer. Progress is written as plain text, without ANSI escape sequences,
+// when out is not a terminal or NoColor is set
 func NewWriter(out console.File) (Writer, error) {
 	_, isTerminal := term.GetFdInfo(out)
 
-	if isTerminal {
+	if isTerminal && !NoColor {
 		con, err := console.ConsoleFromFile(out)
 		if err != nil {
 			return nil, err
@@ -127,6 +157,7 @@ func NewWriter(out console.File) (Writer, error) {
 			repeated: false,
 			done:     make(chan bool),
 			mtx:      &sync.RWMutex{},
+			collapse: CollapseDelay,
 		}, nil
 	}
 
@@ -135,3 +166,12 @@ func NewWriter(out console.File) (Writer, error) {
 		done: make(chan bool),
 	}, nil
 }
+
+// NewJSONWriter returns a new writer serializing progress events as newline-delimited JSON
+func NewJSONWriter(out io.Writer) Writer {
+	return &jsonWriter{
+		out:  out,
+		done: make(chan bool),
+		mtx:  &sync.Mutex{},
+	}
+}
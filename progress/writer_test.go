@@ -18,6 +18,9 @@ package progress
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
@@ -29,3 +32,29 @@ func TestNoopWriter(t *testing.T) {
 
 	assert.Equal(t, writer, &noopWriter{})
 }
+
+func TestPlainOutputWhenNotTerminal(t *testing.T) {
+	f, err := ioutil.TempFile("", "progress")
+	assert.NilError(t, err)
+	defer os.Remove(f.Name()) //nolint:errcheck
+	defer f.Close()           //nolint:errcheck
+
+	w, err := NewWriter(f)
+	assert.NilError(t, err)
+	_, ok := w.(*plainWriter)
+	assert.Assert(t, ok)
+
+	started := make(chan error)
+	go func() {
+		started <- w.Start(context.TODO())
+	}()
+	w.Event(Event{ID: "Container myproject_web_1", Text: "Started", Status: Done})
+	w.Event(Event{ID: "Container myproject_db_1", Text: "Error", Status: Error, StatusText: "failed"})
+	w.Stop()
+	assert.NilError(t, <-started)
+
+	out, err := ioutil.ReadFile(f.Name())
+	assert.NilError(t, err)
+	assert.Equal(t, string(out), "Container myproject_web_1 Started \nContainer myproject_db_1 Error failed\n")
+	assert.Assert(t, !strings.Contains(string(out), "\x1b"))
+}
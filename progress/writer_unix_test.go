@@ -0,0 +1,51 @@
+// +build !windows
+
+/*
+   Copyright 2020 Docker Compose CLI authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package progress
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/containerd/console"
+	"gotest.tools/v3/assert"
+)
+
+func TestNoColorOnTerminal(t *testing.T) {
+	pty, slavePath, err := console.NewPty()
+	if err != nil {
+		t.Skipf("pseudo terminal not available: %v", err)
+	}
+	defer pty.Close() //nolint:errcheck
+	slave, err := os.OpenFile(slavePath, os.O_RDWR|syscall.O_NOCTTY, 0)
+	assert.NilError(t, err)
+	defer slave.Close() //nolint:errcheck
+
+	w, err := NewWriter(slave)
+	assert.NilError(t, err)
+	_, ok := w.(*ttyWriter)
+	assert.Assert(t, ok)
+
+	NoColor = true
+	defer func() { NoColor = false }()
+	w, err = NewWriter(slave)
+	assert.NilError(t, err)
+	_, ok = w.(*plainWriter)
+	assert.Assert(t, ok)
+}